@@ -15,6 +15,10 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "rules" {
+		os.Exit(runRules(os.Args[2:]))
+	}
+
 	if os.Geteuid() != 0 {
 		fmt.Println("This program must be run as root!")
 		os.Exit(1)
@@ -30,13 +34,27 @@ func main() {
 		fmt.Println("[option 4: memory event monitoring]")
 		fmt.Println("[option 5: network event monitoring]")
 		fmt.Println("[option 6: open event monitoring]")
+		fmt.Println("Rule tools: ./HActiV rules <bench|coverage|import|lint|test> [rule file or directory...]")
 		fmt.Println("------------------------------")
 		return
 	}
 
 	configs.HActiVSetting()
+	utils.RuleUpdateHandler = configs.ApplyRuleUpdate
 	utils.DataSendSetting()
 	configs.FirstRules()
+	if err := configs.LoadSequenceRules(); err != nil {
+		fmt.Println("순서 규칙을 불러오지 못했습니다:", err)
+	}
+	if err := configs.LoadAllowlist(); err != nil {
+		fmt.Println("allowlist를 불러오지 못했습니다:", err)
+	}
+	if err := configs.LoadHooks(); err != nil {
+		fmt.Println("actions.json을 불러오지 못했습니다:", err)
+	}
+	if err := configs.WatchRuleLocation(); err != nil {
+		fmt.Println("규칙 디렉터리를 감시하지 못했습니다. SIGHUP으로 규칙을 다시 불러올 수 있습니다:", err)
+	}
 
 	fmt.Println("초기 규칙 파일 설정이 완료되었습니다.")
 
@@ -82,9 +100,20 @@ func main() {
 	go func() {
 		<-sigChan
 		fmt.Println("\nReceived interrupt signal. Shutting down...")
+		utils.CloseTransport(5 * time.Second)
 		os.Exit(0)
 	}()
 
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+
+	go func() {
+		for range hupChan {
+			fmt.Println("Received SIGHUP. Reloading rules...")
+			configs.ReloadRules()
+		}
+	}()
+
 	wg.Wait()
 	fmt.Println("All functions completed")
 }
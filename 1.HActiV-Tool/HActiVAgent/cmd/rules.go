@@ -0,0 +1,260 @@
+// Copyright Authors of HActiV
+package main
+
+import (
+	"HActiV/configs"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// rules 하위 명령은 모니터링을 시작하지 않으므로 root 권한 없이 실행할 수 있다.
+func runRules(args []string) int {
+	if len(args) < 1 {
+		rulesUsage()
+		return 2
+	}
+
+	switch args[0] {
+	case "bench":
+		return rulesBench(args[1:])
+	case "coverage":
+		return rulesCoverage(args[1:])
+	case "import":
+		return rulesImport(args[1:])
+	case "lint":
+		return rulesLint(args[1:])
+	case "test":
+		return rulesTest(args[1:])
+	default:
+		rulesUsage()
+		return 2
+	}
+}
+
+func rulesUsage() {
+	fmt.Println("------------------------------")
+	fmt.Println("Usage: ./HActiV rules <command> [options] [rule file or directory...]")
+	fmt.Println("[bench: measure events/sec of compiled rules against sample events]")
+	fmt.Println("  -duration  time spent on each rule file (default 1s)")
+	fmt.Println("  -all       include rules with usage false")
+	fmt.Println("[coverage: list MITRE ATT&CK techniques covered by the rule packs]")
+	fmt.Println("  -all       include rules with usage false")
+	fmt.Println("[import: convert Sigma and Falco rule files into HActiV YAML rule files]")
+	fmt.Println("  -o         directory to write <tool>rule.yaml files to (default: print to stdout)")
+	fmt.Println("  -force     overwrite existing rule files in the -o directory")
+	fmt.Println("[lint: validate rule files and allowlist.json without modifying them, exit 1 on errors]")
+	fmt.Println("[test: run *.test.ndjson event fixtures against the rule file next to them]")
+	fmt.Println("  -v         print every case")
+	fmt.Println("Without a path, RuleLocation from /etc/HActiV/Setting.json is used.")
+	fmt.Println("------------------------------")
+}
+
+func rulePaths(args []string) []string {
+	if len(args) == 0 {
+		return []string{configs.ReadRuleLocation()}
+	}
+	return args
+}
+
+func rulesBench(args []string) int {
+	flags := flag.NewFlagSet("rules bench", flag.ContinueOnError)
+	duration := flags.Duration("duration", time.Second, "time spent on each rule file")
+	all := flags.Bool("all", false, "include rules with usage false")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	files, err := configs.FindRuleFiles(rulePaths(flags.Args()))
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	status := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RULE FILE\tPOLICIES\tEVENTS\tMATCHED\tEVENTS/SEC\t")
+	for _, file := range files {
+		if configs.IsSequenceRuleFile(file) {
+			continue
+		}
+		result, err := configs.BenchRuleFile(file, *duration, *all)
+		if err != nil {
+			fmt.Fprintf(w, "%s\t%s\t\t\t\t\n", file, err)
+			status = 1
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%.0f\t\n", result.File, result.Policies, result.Events, result.Matched, result.EventsPerSecond())
+	}
+	w.Flush()
+	return status
+}
+
+func rulesCoverage(args []string) int {
+	flags := flag.NewFlagSet("rules coverage", flag.ContinueOnError)
+	all := flags.Bool("all", false, "include rules with usage false")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	files, err := configs.FindRuleFiles(rulePaths(flags.Args()))
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	coverage, err := configs.RuleCoverage(files, *all)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	fmt.Println("Tactics")
+	for _, tactic := range coverage.SortedTactics() {
+		fmt.Printf("  %-10s %-28s %d rules\n", tactic, configs.TacticName(tactic), len(coverage.Tactics[tactic]))
+	}
+	fmt.Println("Techniques")
+	for _, technique := range coverage.SortedTechniques() {
+		fmt.Printf("  %-10s %s\n", technique, configs.TechniqueName(technique))
+		for _, rule := range coverage.Techniques[technique] {
+			fmt.Printf("      %s\n", rule)
+		}
+	}
+	fmt.Printf("\n%d techniques, %d of %d rules mapped\n", len(coverage.Techniques), coverage.Mapped, coverage.Rules)
+	if len(coverage.Unmapped) > 0 {
+		fmt.Println("Alerting rules without MITRE mapping:")
+		for _, rule := range coverage.Unmapped {
+			fmt.Printf("  %s\n", rule)
+		}
+	}
+	return 0
+}
+
+// 변환한 YAML은 표준 출력으로, 변환하지 못한 규칙과 요약은 표준 오류로 출력한다.
+func rulesImport(args []string) int {
+	flags := flag.NewFlagSet("rules import", flag.ContinueOnError)
+	outDir := flags.String("o", "", "directory to write <tool>rule.yaml files to")
+	force := flags.Bool("force", false, "overwrite existing rule files")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: ./HActiV rules import [-o dir] [-force] <Sigma or Falco rule file...>")
+		return 2
+	}
+
+	result, err := configs.ImportRuleFiles(flags.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	for _, issue := range result.Issues {
+		fmt.Fprintln(os.Stderr, issue.String())
+	}
+
+	if *outDir != "" && !*force {
+		for _, tool := range result.Tools() {
+			filename := filepath.Join(*outDir, tool+"rule.yaml")
+			if _, err := os.Stat(filename); err == nil {
+				fmt.Fprintf(os.Stderr, "%s already exists, use -force to overwrite\n", filename)
+				return 1
+			}
+		}
+	}
+	for _, tool := range result.Tools() {
+		data, err := result.YAML(tool)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if *outDir == "" {
+			fmt.Printf("# %srule.yaml\n%s", tool, data)
+			continue
+		}
+		filename := filepath.Join(*outDir, tool+"rule.yaml")
+		if err := os.WriteFile(filename, data, 0644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Fprintf(os.Stderr, "wrote %s (%d rules)\n", filename, result.Rules(tool))
+	}
+	fmt.Fprintf(os.Stderr, "%d rules imported, %d skipped\n", result.Imported(), result.Skipped)
+	return 0
+}
+
+func rulesLint(args []string) int {
+	flags := flag.NewFlagSet("rules lint", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	files, err := configs.FindLintFiles(rulePaths(flags.Args()))
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	issues := 0
+	for _, file := range files {
+		for _, issue := range configs.LintFile(file) {
+			fmt.Println(strings.ReplaceAll(issue.String(), "\n", "\n    "))
+			issues++
+		}
+	}
+	fmt.Printf("%d files checked, %d errors\n", len(files), issues)
+	if issues > 0 {
+		return 1
+	}
+	return 0
+}
+
+func rulesTest(args []string) int {
+	flags := flag.NewFlagSet("rules test", flag.ContinueOnError)
+	verbose := flags.Bool("v", false, "print every case")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	fixtures, err := configs.FindFixtureFiles(rulePaths(flags.Args()))
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	status := 0
+	cases, failed := 0, 0
+	for _, fixture := range fixtures {
+		result, err := configs.RunRuleTest(fixture)
+		if err != nil {
+			fmt.Printf("ERROR %s\n    %s\n", fixture, strings.ReplaceAll(err.Error(), "\n", "\n    "))
+			status = 1
+			continue
+		}
+		cases += len(result.Cases)
+		failed += result.Failed()
+		if result.Failed() > 0 {
+			fmt.Printf("FAIL  %s (%d/%d)\n", fixture, result.Failed(), len(result.Cases))
+			status = 1
+		} else {
+			fmt.Printf("ok    %s (%d)\n", fixture, len(result.Cases))
+		}
+		for _, c := range result.Cases {
+			if c.Passed() && !*verbose {
+				continue
+			}
+			mark := "ok"
+			if !c.Passed() {
+				mark = "FAIL"
+			}
+			fmt.Printf("    %-4s %s:%d %s\n", mark, fixture, c.Line, c.Name)
+			if !c.Passed() {
+				fmt.Printf("         expected [%s]\n         got      [%s]\n", strings.Join(c.Expected, ", "), strings.Join(c.Got, ", "))
+			}
+		}
+	}
+	fmt.Printf("%d fixtures, %d cases, %d failed\n", len(fixtures), cases, failed)
+	return status
+}
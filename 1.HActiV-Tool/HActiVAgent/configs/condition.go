@@ -0,0 +1,436 @@
+// Copyright Authors of HActiV
+
+// configs package for Setting and detection rules
+package configs
+
+import (
+	"HActiV/pkg/utils"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// 조건식은 LoadRules 시점에 한 번 파싱되어 필드 접근자를 쓰는 AST로 컴파일된다.
+//
+//	expr    := or
+//	or      := and { "or" and }
+//	and     := unary { "and" unary }
+//	unary   := "not" unary | "(" expr ")" | macro | compare
+//	compare := operand operator value
+//	operand := %Field% | function "(" operand ")"
+//	value   := scalar | "[" scalar { "," scalar } "]"
+//	scalar  := word | "..." | '...'
+//
+// 공백이나 짝이 맞지 않는 ')'가 들어가는 값은 따옴표로 감싼다.
+// macro와 목록 이름은 YAML 규칙 파일(env가 있는 경우)에서만 쓸 수 있다.
+type conditionNode interface {
+	evaluate(event *utils.Event) bool
+}
+
+type andNode struct {
+	left, right conditionNode
+}
+
+func (n *andNode) evaluate(event *utils.Event) bool {
+	return n.left.evaluate(event) && n.right.evaluate(event)
+}
+
+type orNode struct {
+	left, right conditionNode
+}
+
+func (n *orNode) evaluate(event *utils.Event) bool {
+	return n.left.evaluate(event) || n.right.evaluate(event)
+}
+
+type notNode struct {
+	operand conditionNode
+}
+
+func (n *notNode) evaluate(event *utils.Event) bool {
+	return !n.operand.evaluate(event)
+}
+
+// 단일 비교는 필드 접근자와 미리 준비한 값으로 만든 함수로 컴파일된다.
+type predicateNode func(event *utils.Event) bool
+
+func (n predicateNode) evaluate(event *utils.Event) bool {
+	return n(event)
+}
+
+// 조건식 오류. pos는 조건 문자열에서 오류가 난 위치(1부터 시작하는 문자 단위)이다.
+type conditionError struct {
+	pos int
+	msg string
+}
+
+func (e *conditionError) Error() string {
+	return fmt.Sprintf("%d번째 문자: %s", e.pos, e.msg)
+}
+
+// 조건 아래에 오류 위치를 '^'로 표시한 두 줄을 만든다.
+func (e *conditionError) marker(condition string) string {
+	return "  조건: " + condition + "\n        " + strings.Repeat(" ", e.pos-1) + "^"
+}
+
+// 비교식의 값. 목록([a, b])이면 list가 true이고 items에 항목이 들어간다.
+type conditionValue struct {
+	text  string
+	items []string
+	list  bool
+}
+
+// 조건 문자열을 한 글자씩 읽는 파서. 토큰 종류는 읽는 위치(필드, 연산자, 값)에 따라 달라진다.
+type conditionParser struct {
+	src        string
+	pos        int
+	depth      int
+	fieldTypes map[string]string
+	env        *conditionEnv
+}
+
+// env는 YAML 규칙 파일의 lists와 macros이며, JSON 규칙과 순서 규칙은 nil을 넘긴다.
+func parseCondition(condition string, fieldTypes map[string]string, env *conditionEnv) (conditionNode, error) {
+	p := &conditionParser{
+		src:        condition,
+		fieldTypes: fieldTypes,
+		env:        env,
+	}
+	p.skipSpace()
+	if p.atEnd() {
+		return nil, fmt.Errorf("조건이 비어 있습니다.")
+	}
+
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if !p.atEnd() {
+		if p.src[p.pos] == ')' {
+			return nil, p.errorf(p.pos, "짝이 맞지 않는 ')'가 있습니다.")
+		}
+		return nil, p.errorf(p.pos, "'%s' 위치에서 and/or가 필요합니다.", p.peekWord())
+	}
+	return node, nil
+}
+
+func (p *conditionParser) errorf(pos int, format string, args ...interface{}) error {
+	return &conditionError{
+		pos: utf8.RuneCountInString(p.src[:pos]) + 1,
+		msg: fmt.Sprintf(format, args...),
+	}
+}
+
+func (p *conditionParser) atEnd() bool {
+	return p.pos >= len(p.src)
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func (p *conditionParser) skipSpace() {
+	for !p.atEnd() && isSpace(p.src[p.pos]) {
+		p.pos++
+	}
+}
+
+// 필드명과 and/or/not은 공백이나 괄호에서 끝난다.
+func (p *conditionParser) peekWord() string {
+	end := p.pos
+	for end < len(p.src) && !isSpace(p.src[end]) && p.src[end] != '(' && p.src[end] != ')' {
+		end++
+	}
+	return p.src[p.pos:end]
+}
+
+func (p *conditionParser) keyword(word string) bool {
+	p.skipSpace()
+	return strings.EqualFold(p.peekWord(), word)
+}
+
+func (p *conditionParser) parseOr() (conditionNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		p.pos += len("or")
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &orNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *conditionParser) parseAnd() (conditionNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		p.pos += len("and")
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &andNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *conditionParser) parseUnary() (conditionNode, error) {
+	p.skipSpace()
+	if p.atEnd() {
+		return nil, p.errorf(p.pos, "조건식이 완성되지 않았습니다.")
+	}
+
+	if p.keyword("not") {
+		p.pos += len("not")
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand: operand}, nil
+	}
+
+	if p.src[p.pos] == '(' {
+		open := p.pos
+		p.pos++
+		p.depth++
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if p.atEnd() || p.src[p.pos] != ')' {
+			return nil, p.errorf(open, "'('에 대응하는 ')'가 없습니다.")
+		}
+		p.pos++
+		p.depth--
+		return node, nil
+	}
+
+	if word := p.peekWord(); p.env != nil && !strings.HasPrefix(word, "%") && !p.functionCall() {
+		return p.parseMacro(word)
+	}
+	return p.parseCompare()
+}
+
+func (p *conditionParser) parseMacro(name string) (conditionNode, error) {
+	start := p.pos
+	if _, ok := p.env.macros[name]; !ok {
+		if _, ok := p.env.lists[name]; ok {
+			return nil, p.errorf(start, "목록 '%s'은(는) in, !in, cidr, !cidr 연산자의 값으로만 쓸 수 있습니다.", name)
+		}
+		return nil, p.errorf(start, "'%s'은(는) %%Field%% 형식의 필드명도, 정의된 매크로도 아닙니다.", name)
+	}
+	node, err := p.env.macro(name, p.fieldTypes)
+	if err != nil {
+		return nil, p.errorf(start, "매크로 '%s': %v", name, err)
+	}
+	p.pos += len(name)
+	return node, nil
+}
+
+func (p *conditionParser) parseCompare() (conditionNode, error) {
+	if p.functionCall() {
+		return p.parseFunctionCompare()
+	}
+	start := p.pos
+	fieldNameWithPercent := p.peekWord()
+	p.pos += len(fieldNameWithPercent)
+	if !strings.HasPrefix(fieldNameWithPercent, "%") || !strings.HasSuffix(fieldNameWithPercent, "%") || len(fieldNameWithPercent) < 3 {
+		return nil, p.errorf(start, "필드명 '%s'은(는) %%Field%% 형식이어야 합니다.", fieldNameWithPercent)
+	}
+	fieldName := strings.Trim(fieldNameWithPercent, "%")
+
+	operator, valuePos, value, err := p.parseOperatorValue(fieldNameWithPercent)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok, errMsg := evaluateCondition(fieldName, operator, value, p.fieldTypes); !ok {
+		return nil, p.errorf(start, "%s", errMsg)
+	}
+	node, err := newCompareNode(fieldName, operator, value)
+	if err != nil {
+		return nil, p.errorf(valuePos, "%s %s %s 조건에서 %s", fieldNameWithPercent, operator, value.text, err)
+	}
+	return node, nil
+}
+
+// 함수 호출을 비교하는 조건. 필드 형식 대신 함수 결과의 형식으로 연산자와 값을 확인한다.
+func (p *conditionParser) parseFunctionCompare() (conditionNode, error) {
+	start := p.pos
+	field, operand, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	operator, valuePos, value, err := p.parseOperatorValue(operand)
+	if err != nil {
+		return nil, err
+	}
+
+	part := fmt.Sprintf("%s %s %s", operand, operator, value.text)
+	if ok, errMsg := evaluateOperand(part, operand, field.kind, operator, value); !ok {
+		return nil, p.errorf(start, "%s", errMsg)
+	}
+	node, err := newFieldCompareNode(field, operator, value)
+	if err != nil {
+		return nil, p.errorf(valuePos, "%s 조건에서 %s", part, err)
+	}
+	return node, nil
+}
+
+// 연산자는 (), i() 처럼 괄호를 포함하므로 공백까지 읽는다.
+func (p *conditionParser) parseOperatorValue(operand string) (string, int, conditionValue, error) {
+	p.skipSpace()
+	operatorPos := p.pos
+	for !p.atEnd() && !isSpace(p.src[p.pos]) {
+		p.pos++
+	}
+	operator := p.src[operatorPos:p.pos]
+	if operator == "" {
+		return "", 0, conditionValue{}, p.errorf(operatorPos, "조건 '%s' 뒤에 연산자가 없습니다.", operand)
+	}
+
+	p.skipSpace()
+	valuePos := p.pos
+	value, err := p.parseValue(operator)
+	return operator, valuePos, value, err
+}
+
+func (p *conditionParser) parseValue(operator string) (conditionValue, error) {
+	start := p.pos
+	if p.atEnd() {
+		return conditionValue{}, p.errorf(start, "연산자 '%s' 뒤에 값이 없습니다.", operator)
+	}
+
+	listOperator := operator == "in" || operator == "!in" || operator == "cidr" || operator == "!cidr"
+	if listOperator && p.src[p.pos] == '[' {
+		items, err := p.parseList()
+		if err != nil {
+			return conditionValue{}, err
+		}
+		return conditionValue{text: p.src[start:p.pos], items: items, list: true}, nil
+	}
+	if listOperator {
+		if items, ok := p.listNamed(p.peekWord()); ok {
+			p.pos += len(p.peekWord())
+			return conditionValue{text: p.src[start:p.pos], items: items, list: true}, nil
+		}
+	}
+	if operator == "in" || operator == "!in" {
+		return conditionValue{}, p.errorf(start, "연산자 '%s'의 값은 [a, b, c] 형식의 목록이어야 합니다.", operator)
+	}
+
+	text, err := p.parseScalar("")
+	if err != nil {
+		return conditionValue{}, err
+	}
+	return conditionValue{text: text}, nil
+}
+
+func (p *conditionParser) listNamed(name string) ([]string, bool) {
+	if p.env == nil {
+		return nil, false
+	}
+	items, ok := p.env.lists[name]
+	return items, ok
+}
+
+func (p *conditionParser) parseList() ([]string, error) {
+	open := p.pos
+	p.pos++
+	var items []string
+	for {
+		p.skipSpace()
+		if p.atEnd() {
+			return nil, p.errorf(open, "목록이 ']'로 닫히지 않았습니다.")
+		}
+		itemPos := p.pos
+		item, err := p.parseScalar(",]")
+		if err != nil {
+			return nil, err
+		}
+		quoted := p.src[itemPos] == '"' || p.src[itemPos] == '\''
+		if item == "" && !quoted {
+			return nil, p.errorf(itemPos, "목록에 빈 항목이 있습니다.")
+		}
+		// 따옴표가 없는 항목이 목록 이름이면 그 목록의 항목으로 펼친다.
+		if list, ok := p.listNamed(item); ok && !quoted {
+			items = append(items, list...)
+		} else {
+			items = append(items, item)
+		}
+
+		p.skipSpace()
+		if p.atEnd() {
+			return nil, p.errorf(open, "목록이 ']'로 닫히지 않았습니다.")
+		}
+		switch p.src[p.pos] {
+		case ',':
+			p.pos++
+		case ']':
+			p.pos++
+			return items, nil
+		default:
+			return nil, p.errorf(p.pos, "목록 항목 사이에는 ','가 필요합니다.")
+		}
+	}
+}
+
+// 따옴표로 감싼 값 또는 공백 전까지의 값을 읽는다.
+// 따옴표가 없는 값에서 짝이 없는 ')'는 열린 괄호를 닫는 것으로 본다. stops의 문자에서도 값이 끝난다.
+func (p *conditionParser) parseScalar(stops string) (string, error) {
+	if c := p.src[p.pos]; c == '"' || c == '\'' {
+		return p.parseQuoted(c)
+	}
+
+	start := p.pos
+	nested := 0
+	for !p.atEnd() {
+		c := p.src[p.pos]
+		if isSpace(c) || strings.IndexByte(stops, c) >= 0 {
+			break
+		}
+		if c == '(' {
+			nested++
+		} else if c == ')' {
+			if nested == 0 && p.depth > 0 && p.pos > start {
+				break
+			}
+			if nested > 0 {
+				nested--
+			}
+		}
+		p.pos++
+	}
+	return p.src[start:p.pos], nil
+}
+
+// \\, \", \' 만 이스케이프로 처리하고 나머지 '\'는 정규식에서 쓸 수 있도록 그대로 둔다.
+func (p *conditionParser) parseQuoted(quote byte) (string, error) {
+	open := p.pos
+	p.pos++
+	var b strings.Builder
+	for !p.atEnd() {
+		c := p.src[p.pos]
+		switch {
+		case c == quote:
+			p.pos++
+			return b.String(), nil
+		case c == '\\' && p.pos+1 < len(p.src) && strings.IndexByte(`\"'`, p.src[p.pos+1]) >= 0:
+			b.WriteByte(p.src[p.pos+1])
+			p.pos += 2
+		default:
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+	return "", p.errorf(open, "따옴표(%c)가 닫히지 않았습니다.", quote)
+}
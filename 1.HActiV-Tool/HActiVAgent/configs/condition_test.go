@@ -0,0 +1,78 @@
+// Copyright Authors of HActiV
+
+package configs
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseConditionErrorPosition(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition string
+		pos       int
+		msg       string
+	}{
+		{"unknown field", "%Uid% == 0 and %Nope% == 1", 16, "필드명 'Nope'"},
+		{"field without percent", "Uid == 0", 1, "%Field% 형식"},
+		{"unmatched close paren", "%Uid% == 0 )", 12, "짝이 맞지 않는 ')'"},
+		{"unclosed open paren", "not (%Uid% == 0", 5, "'('에 대응하는 ')'가 없습니다"},
+		{"missing and/or", "%Uid% == 0 %Gid% == 0", 12, "and/or가 필요합니다"},
+		{"dangling and", "%Uid% == 0 and", 15, "완성되지 않았습니다"},
+		{"missing operator", "%Uid%", 6, "연산자가 없습니다"},
+		{"missing value", "%Uid% ==", 9, "값이 없습니다"},
+		{"invalid operator", "%Uid% =! 0", 1, "연산자 '=!'가 올바르지 않습니다"},
+		{"unclosed quote", `%Filename% == "/etc/passwd`, 15, "따옴표(\")가 닫히지 않았습니다"},
+		{"unclosed list", "%Filename% in [a, b", 15, "']'로 닫히지 않았습니다"},
+		{"list without comma", "%Filename% in [a b]", 18, "','가 필요합니다"},
+		{"empty list item", "%Filename% in [a, , b]", 19, "빈 항목"},
+		{"invalid regexp", "%Filename% =~ [a", 15, "%Filename% =~ [a 조건에서"},
+		{"multibyte offset", "%Filename% == 한글 and %Nope% == 1", 22, "필드명 'Nope'"},
+	}
+	fieldTypes := getFieldTypes()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseCondition(tt.condition, fieldTypes, nil)
+			var condErr *conditionError
+			if !errors.As(err, &condErr) {
+				t.Fatalf("parseCondition(%q) error = %v, want a conditionError", tt.condition, err)
+			}
+			if condErr.pos != tt.pos {
+				t.Errorf("parseCondition(%q) pos = %d, want %d (%v)", tt.condition, condErr.pos, tt.pos, err)
+			}
+			if !strings.Contains(condErr.msg, tt.msg) {
+				t.Errorf("parseCondition(%q) msg = %q, want it to contain %q", tt.condition, condErr.msg, tt.msg)
+			}
+		})
+	}
+}
+
+func TestParseConditionEmpty(t *testing.T) {
+	for _, condition := range []string{"", "   "} {
+		if _, err := parseCondition(condition, getFieldTypes(), nil); err == nil {
+			t.Errorf("parseCondition(%q) succeeded, want an error", condition)
+		}
+	}
+}
+
+func TestConditionErrorMarker(t *testing.T) {
+	tests := []struct {
+		condition string
+		want      string
+	}{
+		{"%Uid% == 0 and %Nope% == 1", "  조건: %Uid% == 0 and %Nope% == 1\n                       ^"},
+		{"%Uid% == 0 )", "  조건: %Uid% == 0 )\n                   ^"},
+	}
+	for _, tt := range tests {
+		_, err := parseCondition(tt.condition, getFieldTypes(), nil)
+		var condErr *conditionError
+		if !errors.As(err, &condErr) {
+			t.Fatalf("parseCondition(%q) error = %v, want a conditionError", tt.condition, err)
+		}
+		if got := condErr.marker(tt.condition); got != tt.want {
+			t.Errorf("marker(%q) =\n%s\nwant\n%s", tt.condition, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright Authors of HActiV
+
+// configs package for Setting and detection rules
+package configs
+
+import (
+	"HActiV/pkg/utils"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 중복 알림 억제 설정. 같은 키의 알림은 처음 한 번만 액션을 실행하고, 이후에는 window마다
+// 그동안 억제한 건수를 마지막 이벤트와 함께 한 번 요약해 보낸다. 한 window 동안 알림이 없으면 억제를 끝낸다.
+//
+//	"dedup": {"fields": ["%ContainerName%", "%ProcessName%"], "window": "1m"}
+//
+// fields를 비우면 정책 전체를 하나의 키로 본다.
+type Dedup struct {
+	Fields []string `json:"fields"`
+	Window string   `json:"window"`
+}
+
+const maxSuppressedKeys = 4096
+
+type suppressor struct {
+	window    time.Duration
+	keyNames  []string
+	keyFields []func(event *utils.Event) string
+
+	mu      sync.Mutex
+	entries map[string]*suppression
+}
+
+// 억제 중인 키의 상태. 마지막으로 억제한 이벤트와 메시지를 요약에 사용한다.
+type suppression struct {
+	count   int
+	event   utils.Event
+	message string
+}
+
+func newSuppressor(dedup *Dedup) (*suppressor, error) {
+	window, err := time.ParseDuration(dedup.Window)
+	if err != nil || window <= 0 {
+		return nil, fmt.Errorf("dedup.window '%s'이(가) 올바르지 않습니다. 예시: 30s, 5m", dedup.Window)
+	}
+
+	s := &suppressor{
+		window:  window,
+		entries: make(map[string]*suppression),
+	}
+	for _, fieldNameWithPercent := range dedup.Fields {
+		fieldName := strings.Trim(fieldNameWithPercent, "%")
+		field, ok := eventFields[fieldName]
+		if !ok {
+			return nil, fmt.Errorf("dedup.fields의 필드 '%s'이(가) 올바르지 않습니다.", fieldNameWithPercent)
+		}
+		s.keyNames = append(s.keyNames, fieldName)
+		s.keyFields = append(s.keyFields, field.text)
+	}
+	return s, nil
+}
+
+func (s *suppressor) key(event *utils.Event) string {
+	values := make([]string, len(s.keyFields))
+	for i, get := range s.keyFields {
+		values[i] = s.keyNames[i] + "=" + get(event)
+	}
+	return strings.Join(values, ", ")
+}
+
+// 알림을 내보내도 되면 true를 돌려준다. 억제 window가 새로 시작되면 window가 끝날 때
+// 억제 건수를 요약해 보내도록 타이머를 건다. 키가 너무 많으면 억제하지 않고 그대로 내보낸다.
+func (s *suppressor) admit(policy *Policy, event *utils.Event, message string) bool {
+	key := s.key(event)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, ok := s.entries[key]; ok {
+		entry.count++
+		entry.event = *event
+		entry.message = message
+		return false
+	}
+	if len(s.entries) >= maxSuppressedKeys {
+		return true
+	}
+	s.entries[key] = &suppression{}
+	time.AfterFunc(s.window, func() { s.flush(policy, key) })
+	return true
+}
+
+// window가 끝날 때마다 호출된다. 억제한 알림이 있었으면 요약 메시지로 액션을 한 번 실행하고
+// 다음 window 동안 계속 억제한다. 억제한 알림이 없던 키는 지운다.
+func (s *suppressor) flush(policy *Policy, key string) {
+	s.mu.Lock()
+	entry, ok := s.entries[key]
+	if !ok {
+		s.mu.Unlock()
+		return
+	}
+	if entry.count == 0 {
+		delete(s.entries, key)
+		s.mu.Unlock()
+		return
+	}
+	suppressed := *entry
+	*entry = suppression{}
+	time.AfterFunc(s.window, func() { s.flush(policy, key) })
+	s.mu.Unlock()
+
+	summary := fmt.Sprintf("%s 동안 유사 알림 %d건 억제됨", s.window, suppressed.count)
+	if key != "" {
+		summary += " | " + key
+	}
+	runActions(policy, suppressed.event, suppressed.message+" | "+summary, false)
+}
@@ -0,0 +1,212 @@
+// Copyright Authors of HActiV
+
+// configs package for Setting and detection rules
+package configs
+
+import (
+	"HActiV/pkg/utils"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// 규칙의 예외 목록. 항목 중 하나라도 이벤트와 일치하면 규칙이 일치해도 액션을 실행하지 않는다.
+// 같은 형식의 allowlist.json은 모든 규칙과 순서 규칙에 공통으로 적용된다.
+//
+//	"exceptions": {
+//	  "fields": [{"field": "%ProcessName%", "values": ["python3", "pip"]}],
+//	  "containers": ["hactiv*"],
+//	  "images": ["docker.io/library/postgres:*"],
+//	  "processes": ["/usr/local/bin/**", "node"]
+//	}
+type Exceptions struct {
+	Fields     []FieldException `json:"fields,omitempty"`
+	Containers []string         `json:"containers,omitempty"`
+	Images     []string         `json:"images,omitempty"`
+	Processes  []string         `json:"processes,omitempty"`
+}
+
+// 필드 예외. operator를 비우면 values 중 하나와 같은지(in) 비교한다.
+type FieldException struct {
+	Field    string   `json:"field"`
+	Operator string   `json:"operator,omitempty"`
+	Values   []string `json:"values"`
+}
+
+var globalAllowlist atomic.Pointer[exceptionSet]
+
+// 컴파일된 예외 목록. 각 항목은 예외 하나에 해당하는 조건이다.
+type exceptionSet struct {
+	matchers []conditionNode
+}
+
+func (s *exceptionSet) match(event *utils.Event) bool {
+	if s == nil {
+		return false
+	}
+	for _, matcher := range s.matchers {
+		if matcher.evaluate(event) {
+			return true
+		}
+	}
+	return false
+}
+
+func compileExceptions(e *Exceptions) (*exceptionSet, error) {
+	if e == nil {
+		return nil, nil
+	}
+	set := &exceptionSet{}
+	for _, fe := range e.Fields {
+		nodes, err := compileFieldException(fe)
+		if err != nil {
+			return nil, err
+		}
+		set.matchers = append(set.matchers, nodes...)
+	}
+	for _, pattern := range e.Containers {
+		node, err := newCompareNode("ContainerName", "glob", conditionValue{text: pattern})
+		if err != nil {
+			return nil, fmt.Errorf("exceptions.containers: %v", err)
+		}
+		set.matchers = append(set.matchers, node)
+	}
+	for _, pattern := range e.Images {
+		node, err := newCompareNode("ContainerImage", "glob", conditionValue{text: pattern})
+		if err != nil {
+			return nil, fmt.Errorf("exceptions.images: %v", err)
+		}
+		set.matchers = append(set.matchers, node)
+	}
+	for _, process := range e.Processes {
+		node, err := processException(process)
+		if err != nil {
+			return nil, fmt.Errorf("exceptions.processes: %v", err)
+		}
+		set.matchers = append(set.matchers, node)
+	}
+	if len(set.matchers) == 0 {
+		return nil, nil
+	}
+	return set, nil
+}
+
+// in, cidr은 목록 하나로, 그 밖의 연산자는 값마다 비교 하나로 컴파일한다.
+func compileFieldException(fe FieldException) ([]conditionNode, error) {
+	fieldName := strings.Trim(fe.Field, "%")
+	operator := fe.Operator
+	if operator == "" {
+		operator = "in"
+	}
+	if _, negated := negatedOperators[operator]; negated {
+		return nil, fmt.Errorf("exceptions.fields의 '%s'에는 부정 연산자 '%s'를 사용할 수 없습니다.", fe.Field, operator)
+	}
+	if len(fe.Values) == 0 {
+		return nil, fmt.Errorf("exceptions.fields의 '%s'에 values가 없습니다.", fe.Field)
+	}
+	if operator == "in" || operator == "cidr" {
+		node, err := newCompareNode(fieldName, operator, conditionValue{items: fe.Values, list: true})
+		if err != nil {
+			return nil, fmt.Errorf("exceptions.fields의 '%s': %v", fe.Field, err)
+		}
+		return []conditionNode{node}, nil
+	}
+	var nodes []conditionNode
+	for _, value := range fe.Values {
+		node, err := newCompareNode(fieldName, operator, conditionValue{text: value})
+		if err != nil {
+			return nil, fmt.Errorf("exceptions.fields의 '%s': %v", fe.Field, err)
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// '/'가 들어간 항목은 실행 파일 경로 glob으로, 그 밖의 항목은 프로세스 이름으로 비교한다.
+func processException(process string) (conditionNode, error) {
+	if !strings.Contains(process, "/") {
+		return newCompareNode("ProcessName", "==", conditionValue{text: process})
+	}
+	pattern, err := globToRegexp(process)
+	if err != nil {
+		return nil, err
+	}
+	return predicateNode(func(event *utils.Event) bool {
+		path := processPath(event)
+		return path != "" && pattern.MatchString(path)
+	}), nil
+}
+
+// 실행 파일 경로. execve 이벤트는 실행한 파일 이름을 쓰고, 그 밖의 이벤트는 /proc에서 읽는다.
+func processPath(event *utils.Event) string {
+	if event.Tool == "Systemcall" && event.Filename != "" {
+		return event.Filename
+	}
+	if event.Pid == 0 {
+		return ""
+	}
+	path, err := os.Readlink("/proc/" + strconv.FormatUint(uint64(event.Pid), 10) + "/exe")
+	if err != nil {
+		return ""
+	}
+	return path
+}
+
+// 규칙 디렉터리의 allowlist.json을 불러와 모든 규칙에 적용한다. 파일이 없거나 비어 있으면 allowlist를 비운다.
+func LoadAllowlist() error {
+	allowlist, set, err := readAllowlist(RuleLocation + allowlistFileName)
+	if err != nil {
+		return err
+	}
+	globalAllowlist.Store(set)
+	if set != nil {
+		fmt.Printf("allowlist: %s\n", describeExceptions(allowlist))
+	}
+	return nil
+}
+
+func readAllowlist(filename string) (*Exceptions, *exceptionSet, error) {
+	data, err := os.ReadFile(filename)
+	if os.IsNotExist(err) || (err == nil && len(strings.TrimSpace(string(data))) == 0) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	var allowlist Exceptions
+	if err := json.Unmarshal(data, &allowlist); err != nil {
+		return nil, nil, err
+	}
+	set, err := compileExceptions(&allowlist)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &allowlist, set, nil
+}
+
+func describeExceptions(e *Exceptions) string {
+	var parts []string
+	for _, fe := range e.Fields {
+		operator := fe.Operator
+		if operator == "" {
+			operator = "in"
+		}
+		parts = append(parts, fmt.Sprintf("%s %s [%s]", fe.Field, operator, strings.Join(fe.Values, ", ")))
+	}
+	if len(e.Containers) > 0 {
+		parts = append(parts, "컨테이너 ["+strings.Join(e.Containers, ", ")+"]")
+	}
+	if len(e.Images) > 0 {
+		parts = append(parts, "이미지 ["+strings.Join(e.Images, ", ")+"]")
+	}
+	if len(e.Processes) > 0 {
+		parts = append(parts, "프로세스 ["+strings.Join(e.Processes, ", ")+"]")
+	}
+	if len(parts) == 0 {
+		return "없음"
+	}
+	return strings.Join(parts, " | ")
+}
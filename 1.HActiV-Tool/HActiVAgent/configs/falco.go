@@ -0,0 +1,818 @@
+// Copyright Authors of HActiV
+
+// configs package for Setting and detection rules
+package configs
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Falco 규칙 변환. 매크로는 규칙마다 펼친 뒤 evt.type 비교로 어느 모니터의 규칙인지 정하고,
+// list는 YAML 규칙 파일의 lists로 옮겨 조건에서 이름으로 참조한다.
+
+// evt.type 값별 모니터. 모니터가 보는 시스템 콜이면 evt.type 비교는 참으로 본다(memory는 Syscall 필드로 비교한다).
+var falcoSyscallTools = map[string]string{
+	"execve": "execve", "execveat": "execve",
+	"open": "open", "openat": "open", "openat2": "open", "creat": "open",
+	"unlink": "delete", "unlinkat": "delete", "rmdir": "delete",
+	"mmap": "memory", "mprotect": "memory",
+	"connect": "network", "accept": "network", "accept4": "network", "listen": "network",
+	"sendto": "network", "recvfrom": "network", "sendmsg": "network", "recvmsg": "network",
+}
+
+// Falco 필드별 HActiV 필드. 모든 모니터에 공통인 필드는 ""에 둔다.
+var falcoFields = map[string]map[string]string{
+	"": {
+		"proc.name":       "ProcessName",
+		"proc.pid":        "Pid",
+		"proc.ppid":       "Ppid",
+		"user.uid":        "Uid",
+		"group.gid":       "Gid",
+		"container.name":  "ContainerName",
+		"container.image": "ContainerImage",
+	},
+	"execve": {
+		"proc.cmdline": "Args",
+		"proc.exe":     "Filename",
+		"proc.exepath": "Filename",
+	},
+	"open": {
+		"fd.name":    "Filename",
+		"evt.rawres": "ReturnValue",
+	},
+	"delete": {
+		"fd.name":          "Filename",
+		"evt.arg.name":     "Filename",
+		"evt.arg.path":     "Filename",
+		"evt.arg.pathname": "Filename",
+	},
+	"network": {
+		"fd.sip":     "DstIp",
+		"fd.sport":   "DstPort",
+		"fd.cip":     "SrcIp",
+		"fd.cport":   "SrcPort",
+		"fd.l4proto": "Protocol",
+	},
+}
+
+// evt.type 비교가 없는 규칙은 쓰는 필드로 모니터를 정한다.
+var falcoNetworkFields = []string{"fd.sip", "fd.sport", "fd.cip", "fd.cport", "fd.l4proto", "fd.rip", "fd.rport", "fd.lip", "fd.lport"}
+
+var falcoPriorities = map[string]string{
+	"emergency":     "critical",
+	"alert":         "critical",
+	"critical":      "critical",
+	"error":         "high",
+	"warning":       "medium",
+	"notice":        "low",
+	"informational": "info",
+	"info":          "info",
+	"debug":         "info",
+}
+
+// falco_rules.yaml 없이 로컬 규칙만 변환해도 자주 쓰는 매크로는 알아볼 수 있도록 기본 정의를 둔다.
+// 변환하는 파일에 같은 이름의 매크로가 있으면 그 정의를 쓴다.
+var falcoDefaultMacros = map[string]string{
+	"container":       "container.id != host",
+	"spawned_process": "evt.type in (execve, execveat) and evt.dir=<",
+}
+
+type falcoImporter struct {
+	result *ImportResult
+	lists  map[string][]string
+	macros map[string]string
+}
+
+func newFalcoImporter(result *ImportResult) *falcoImporter {
+	return &falcoImporter{
+		result: result,
+		lists:  make(map[string][]string),
+		macros: mergeDefinitions(nil, falcoDefaultMacros),
+	}
+}
+
+type falcoItem struct {
+	Rule       string           `yaml:"rule"`
+	Desc       string           `yaml:"desc"`
+	Condition  string           `yaml:"condition"`
+	Output     string           `yaml:"output"`
+	Priority   string           `yaml:"priority"`
+	Tags       []string         `yaml:"tags"`
+	Enabled    *bool            `yaml:"enabled"`
+	Append     bool             `yaml:"append"`
+	Override   map[string]any   `yaml:"override"`
+	Exceptions []falcoException `yaml:"exceptions"`
+	List       string           `yaml:"list"`
+	Items      []string         `yaml:"items"`
+	Macro      string           `yaml:"macro"`
+}
+
+// Falco 예외. fields가 하나이면 values는 값 목록이고, 여럿이면 필드 순서대로 값을 나열한 묶음의 목록이다.
+type falcoException struct {
+	Name   string    `yaml:"name"`
+	Fields yaml.Node `yaml:"fields"`
+	Comps  yaml.Node `yaml:"comps"`
+	Values yaml.Node `yaml:"values"`
+}
+
+// Falco 규칙 파일 하나를 변환한다. list와 macro를 먼저 모두 읽은 뒤 규칙을 변환한다.
+func (f *falcoImporter) importFile(file string, doc *yaml.Node) {
+	type entry struct {
+		item falcoItem
+		line int
+	}
+	var rules []entry
+	for _, node := range doc.Content {
+		var item falcoItem
+		if err := node.Decode(&item); err != nil {
+			f.result.skip(file, node.Line, "", "%v", err)
+			continue
+		}
+		switch {
+		case item.List != "":
+			if item.Append {
+				f.lists[item.List] = append(f.lists[item.List], item.Items...)
+			} else {
+				f.lists[item.List] = item.Items
+			}
+		case item.Macro != "":
+			if previous, ok := f.macros[item.Macro]; ok && item.Append {
+				f.macros[item.Macro] = previous + " " + item.Condition
+			} else {
+				f.macros[item.Macro] = item.Condition
+			}
+		case item.Rule != "":
+			rules = append(rules, entry{item: item, line: node.Line})
+		case yamlMapValue(node, "required_engine_version") != nil || yamlMapValue(node, "required_plugin_versions") != nil:
+			// 엔진, 플러그인 버전 요구 사항은 변환과 관계없다.
+		default:
+			f.result.skip(file, node.Line, "", "rule, list, macro가 아닌 항목입니다.")
+		}
+	}
+	for _, r := range rules {
+		f.importRule(file, r.line, r.item)
+	}
+}
+
+func (f *falcoImporter) importRule(file string, line int, item falcoItem) {
+	switch {
+	case item.Append || item.Override != nil:
+		f.result.skip(file, line, item.Rule, "기존 규칙을 고치는 append, override는 지원하지 않습니다. 고친 결과를 규칙 하나로 작성하세요.")
+		return
+	case strings.TrimSpace(item.Condition) == "":
+		f.result.skip(file, line, item.Rule, "condition이 없습니다.")
+		return
+	}
+
+	tree, err := parseFalcoCondition(item.Condition)
+	if err == nil {
+		tree, err = f.expand(tree, nil)
+	}
+	if err == nil && len(item.Exceptions) > 0 {
+		var exceptions *falcoNode
+		exceptions, err = f.exceptions(item.Exceptions)
+		if exceptions != nil {
+			tree = &falcoNode{kind: "and", children: []*falcoNode{tree, {kind: "not", children: []*falcoNode{exceptions}}}}
+		}
+	}
+	if err != nil {
+		f.result.skip(file, line, item.Rule, "%v", err)
+		return
+	}
+
+	severity := ""
+	if item.Priority != "" {
+		var ok bool
+		if severity, ok = falcoPriorities[strings.ToLower(item.Priority)]; !ok {
+			f.result.issue(file, line, item.Rule, "priority '%s'을(를) 알 수 없어 severity를 비워 둡니다.", item.Priority)
+		}
+	}
+	mitre, tags := mitreFromTags(item.Tags, "mitre_")
+
+	// handled는 모니터 하나 이상에서 변환했거나 변환하지 못한 이유를 남겼는지이다.
+	tools := f.tools(tree)
+	handled := false
+	for _, tool := range tools {
+		specialized := specializeFalco(tree, tool, f.lists)
+		if specialized.kind == "const" {
+			if specialized.value {
+				f.result.skip(file, line, item.Rule, "%s 모니터에서 조건이 모든 이벤트와 일치합니다.", tool)
+				handled = true
+			}
+			continue
+		}
+		r := &falcoRenderer{importer: f, tool: tool, used: make(map[string][]string)}
+		condition, err := r.render(specialized)
+		if err != nil {
+			f.result.skip(file, line, item.Rule, "%s 모니터: %v", tool, err)
+			handled = true
+			continue
+		}
+
+		rule := importedRule(tool, item.Rule, strings.TrimSpace(item.Desc), severity, tags, mitre, condition)
+		if item.Enabled != nil {
+			rule.Usage = *item.Enabled
+		}
+		if output := strings.TrimSpace(item.Output); output != "" {
+			format, unmapped := r.output(output)
+			if len(unmapped) > 0 {
+				f.result.issue(file, line, item.Rule, "출력 필드 %s은(는) 대응하는 필드가 없어 '-'로 바꿨습니다.", strings.Join(unmapped, ", "))
+			}
+			rule.PrintFormat = "%Time% | " + format
+		}
+		f.result.add(file, line, tool, rule, r.used)
+		handled = true
+	}
+	if !handled {
+		f.result.skip(file, line, item.Rule, "조건이 어떤 모니터의 이벤트와도 일치할 수 없습니다.")
+	}
+}
+
+// 규칙을 만들 모니터. 조건에서 evt.type으로 고른 시스템 콜의 모니터이며, evt.type 비교가 없으면 쓰는 필드로 정한다.
+func (f *falcoImporter) tools(tree *falcoNode) []string {
+	var tools, fields []string
+	tree.walk(func(n *falcoNode) {
+		if n.kind != "check" {
+			return
+		}
+		fields = append(fields, n.field)
+		if n.field == "evt.type" && (n.operator == "=" || n.operator == "==" || n.operator == "in") {
+			for _, value := range expandFalcoItems(n.values, f.lists) {
+				if tool, ok := falcoSyscallTools[value]; ok {
+					tools = append(tools, tool)
+				}
+			}
+		}
+	})
+	if len(tools) > 0 {
+		return uniqueSorted(tools)
+	}
+	for _, field := range fields {
+		if contains(falcoNetworkFields, field) {
+			return []string{"network"}
+		}
+	}
+	for _, field := range fields {
+		if strings.HasPrefix(field, "fd.") {
+			return []string{"open"}
+		}
+	}
+	return []string{"execve"}
+}
+
+// 매크로를 펼친다. seen은 펼치는 중인 매크로로 순환 참조를 찾는 데 쓴다.
+func (f *falcoImporter) expand(n *falcoNode, seen []string) (*falcoNode, error) {
+	switch n.kind {
+	case "macro":
+		condition, ok := f.macros[n.name]
+		if !ok {
+			return nil, fmt.Errorf("정의되지 않은 매크로 '%s'", n.name)
+		}
+		if contains(seen, n.name) {
+			return nil, fmt.Errorf("매크로가 서로를 참조합니다: %s", strings.Join(append(seen, n.name), " -> "))
+		}
+		tree, err := parseFalcoCondition(condition)
+		if err != nil {
+			return nil, fmt.Errorf("매크로 '%s': %v", n.name, err)
+		}
+		return f.expand(tree, append(seen, n.name))
+	case "and", "or", "not":
+		expanded := &falcoNode{kind: n.kind}
+		for _, child := range n.children {
+			c, err := f.expand(child, seen)
+			if err != nil {
+				return nil, err
+			}
+			expanded.children = append(expanded.children, c)
+		}
+		return expanded, nil
+	}
+	return n, nil
+}
+
+// 예외 목록을 'or'로 묶은 조건. 예외 하나는 필드별 비교를 모두 만족하는 값 묶음 중 하나와 일치한다.
+func (f *falcoImporter) exceptions(exceptions []falcoException) (*falcoNode, error) {
+	var matches []*falcoNode
+	for _, e := range exceptions {
+		if e.Values.Kind == 0 || len(e.Values.Content) == 0 {
+			continue
+		}
+		if e.Fields.Kind == yaml.ScalarNode {
+			comp := "in"
+			if e.Comps.Kind == yaml.ScalarNode {
+				comp = e.Comps.Value
+			}
+			var values []string
+			if err := e.Values.Decode(&values); err != nil {
+				return nil, fmt.Errorf("예외 '%s'의 values: %v", e.Name, err)
+			}
+			matches = append(matches, falcoCheck(e.Fields.Value, comp, values))
+			continue
+		}
+
+		var fields, comps []string
+		if err := e.Fields.Decode(&fields); err != nil {
+			return nil, fmt.Errorf("예외 '%s'의 fields: %v", e.Name, err)
+		}
+		if e.Comps.Kind != 0 {
+			if err := e.Comps.Decode(&comps); err != nil {
+				return nil, fmt.Errorf("예외 '%s'의 comps: %v", e.Name, err)
+			}
+		}
+		for len(comps) < len(fields) {
+			comps = append(comps, "=")
+		}
+		for _, tuple := range e.Values.Content {
+			if tuple.Kind != yaml.SequenceNode || len(tuple.Content) != len(fields) {
+				return nil, fmt.Errorf("예외 '%s'의 값 묶음은 fields와 같은 수의 값을 가져야 합니다.", e.Name)
+			}
+			var checks []*falcoNode
+			for i, value := range tuple.Content {
+				var values []string
+				if value.Kind == yaml.SequenceNode {
+					if err := value.Decode(&values); err != nil {
+						return nil, fmt.Errorf("예외 '%s'의 values: %v", e.Name, err)
+					}
+				} else {
+					values = []string{value.Value}
+				}
+				checks = append(checks, falcoCheck(fields[i], comps[i], values))
+			}
+			matches = append(matches, falcoJoin("and", checks))
+		}
+	}
+	if len(matches) == 0 {
+		return nil, nil
+	}
+	return falcoJoin("or", matches), nil
+}
+
+// Falco 조건식의 AST. kind는 and, or, not, check(필드 비교), macro(매크로 참조), const(펼친 뒤 정해진 참, 거짓)이다.
+type falcoNode struct {
+	kind     string
+	children []*falcoNode
+	field    string
+	operator string
+	values   []string
+	name     string
+	value    bool
+}
+
+func falcoCheck(field, operator string, values []string) *falcoNode {
+	return &falcoNode{kind: "check", field: field, operator: operator, values: values}
+}
+
+func falcoJoin(kind string, nodes []*falcoNode) *falcoNode {
+	if len(nodes) == 1 {
+		return nodes[0]
+	}
+	return &falcoNode{kind: kind, children: nodes}
+}
+
+func falcoConst(value bool) *falcoNode {
+	return &falcoNode{kind: "const", value: value}
+}
+
+func (n *falcoNode) walk(visit func(n *falcoNode)) {
+	visit(n)
+	for _, child := range n.children {
+		child.walk(visit)
+	}
+}
+
+var (
+	falcoSymbolOperators = []string{"==", "!=", "<=", ">=", "=", "<", ">"}
+	falcoWordOperators   = []string{"contains", "icontains", "bcontains", "startswith", "bstartswith", "endswith",
+		"glob", "iglob", "pmatch", "in", "intersects", "exists", "regex"}
+	falcoListOperators = []string{"in", "intersects", "pmatch"}
+)
+
+// Falco 조건 파서.
+//
+//	expr  := and { "or" and }
+//	and   := not { "and" not }
+//	not   := "not" not | "(" expr ")" | field operator [value] | macro
+//	value := word | "..." | '...' | "(" value { "," value } ")"
+type falcoParser struct {
+	src string
+	pos int
+}
+
+func parseFalcoCondition(condition string) (*falcoNode, error) {
+	p := &falcoParser{src: condition}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.src) {
+		return nil, p.errorf("'%s' 위치에서 and/or가 필요합니다.", p.src[p.pos:])
+	}
+	return node, nil
+}
+
+func (p *falcoParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("condition %d번째 문자: %s", p.pos+1, fmt.Sprintf(format, args...))
+}
+
+func (p *falcoParser) skipSpace() {
+	for p.pos < len(p.src) && isSpace(p.src[p.pos]) {
+		p.pos++
+	}
+}
+
+// stops의 문자나 공백 전까지 읽는다.
+func (p *falcoParser) word(stops string) string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.src) && !isSpace(p.src[p.pos]) && strings.IndexByte(stops, p.src[p.pos]) < 0 {
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+func (p *falcoParser) keyword(word string) bool {
+	p.skipSpace()
+	rest := p.src[p.pos:]
+	if !strings.HasPrefix(rest, word) {
+		return false
+	}
+	if len(rest) > len(word) && !isSpace(rest[len(word)]) && rest[len(word)] != '(' {
+		return false
+	}
+	p.pos += len(word)
+	return true
+}
+
+func (p *falcoParser) parseOr() (*falcoNode, error) {
+	return p.parseSeries("or", p.parseAnd)
+}
+
+func (p *falcoParser) parseAnd() (*falcoNode, error) {
+	return p.parseSeries("and", p.parseNot)
+}
+
+func (p *falcoParser) parseSeries(kind string, next func() (*falcoNode, error)) (*falcoNode, error) {
+	first, err := next()
+	if err != nil {
+		return nil, err
+	}
+	nodes := []*falcoNode{first}
+	for p.keyword(kind) {
+		node, err := next()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	return falcoJoin(kind, nodes), nil
+}
+
+func (p *falcoParser) parseNot() (*falcoNode, error) {
+	if p.keyword("not") {
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &falcoNode{kind: "not", children: []*falcoNode{operand}}, nil
+	}
+	p.skipSpace()
+	if p.pos >= len(p.src) {
+		return nil, p.errorf("조건식이 완성되지 않았습니다.")
+	}
+	if p.src[p.pos] == '(' {
+		p.pos++
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if p.pos >= len(p.src) || p.src[p.pos] != ')' {
+			return nil, p.errorf("')'가 필요합니다.")
+		}
+		p.pos++
+		return node, nil
+	}
+	return p.parseCheck()
+}
+
+func (p *falcoParser) parseCheck() (*falcoNode, error) {
+	field := p.word("()=!<>,")
+	if field == "" {
+		return nil, p.errorf("필드명이나 매크로 이름이 필요합니다.")
+	}
+
+	p.skipSpace()
+	operator := ""
+	for _, symbol := range falcoSymbolOperators {
+		if strings.HasPrefix(p.src[p.pos:], symbol) {
+			operator = symbol
+			p.pos += len(symbol)
+			break
+		}
+	}
+	if operator == "" {
+		for _, word := range falcoWordOperators {
+			if p.keyword(word) {
+				operator = word
+				break
+			}
+		}
+	}
+	if operator == "" {
+		return &falcoNode{kind: "macro", name: field}, nil
+	}
+	if operator == "exists" {
+		return falcoCheck(field, operator, nil), nil
+	}
+
+	p.skipSpace()
+	if contains(falcoListOperators, operator) {
+		if p.pos >= len(p.src) || p.src[p.pos] != '(' {
+			return nil, p.errorf("연산자 '%s' 뒤에는 (a, b) 형식의 목록이 필요합니다.", operator)
+		}
+		p.pos++
+		var values []string
+		for {
+			p.skipSpace()
+			if p.pos < len(p.src) && p.src[p.pos] == ')' {
+				p.pos++
+				return falcoCheck(field, operator, values), nil
+			}
+			value, err := p.value(",)")
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+			p.skipSpace()
+			if p.pos < len(p.src) && p.src[p.pos] == ',' {
+				p.pos++
+			} else if p.pos >= len(p.src) || p.src[p.pos] != ')' {
+				return nil, p.errorf("목록이 ')'로 닫히지 않았습니다.")
+			}
+		}
+	}
+	value, err := p.value(")")
+	if err != nil {
+		return nil, err
+	}
+	return falcoCheck(field, operator, []string{value}), nil
+}
+
+func (p *falcoParser) value(stops string) (string, error) {
+	p.skipSpace()
+	if p.pos < len(p.src) && (p.src[p.pos] == '"' || p.src[p.pos] == '\'') {
+		quote := p.src[p.pos]
+		end := strings.IndexByte(p.src[p.pos+1:], quote)
+		if end < 0 {
+			return "", p.errorf("따옴표(%c)가 닫히지 않았습니다.", quote)
+		}
+		value := p.src[p.pos+1 : p.pos+1+end]
+		p.pos += end + 2
+		return value, nil
+	}
+	value := p.word(stops)
+	if value == "" {
+		return "", p.errorf("값이 필요합니다.")
+	}
+	return value, nil
+}
+
+// 목록 이름인 항목을 목록의 항목으로 펼친다.
+func expandFalcoItems(items []string, lists map[string][]string) []string {
+	var expanded []string
+	var expand func(items []string, seen []string)
+	expand = func(items []string, seen []string) {
+		for _, item := range items {
+			if nested, ok := lists[item]; ok && !contains(seen, item) {
+				expand(nested, append(seen, item))
+				continue
+			}
+			expanded = append(expanded, item)
+		}
+	}
+	expand(items, nil)
+	return expanded
+}
+
+// 모니터가 정해졌을 때 값이 정해지는 비교(evt.type, evt.dir, container.id)를 참, 거짓으로 바꾸고 정리한다.
+// HActiV는 컨테이너 안의 이벤트만 보므로 container.id != host는 항상 참이다.
+func specializeFalco(n *falcoNode, tool string, lists map[string][]string) *falcoNode {
+	switch n.kind {
+	case "and", "or":
+		var children []*falcoNode
+		for _, child := range n.children {
+			c := specializeFalco(child, tool, lists)
+			if c.kind == "const" {
+				// and에서 참, or에서 거짓은 결과에 영향이 없고, 그 반대는 결과를 정한다.
+				if c.value == (n.kind == "and") {
+					continue
+				}
+				return c
+			}
+			children = append(children, c)
+		}
+		if len(children) == 0 {
+			return falcoConst(n.kind == "and")
+		}
+		return falcoJoin(n.kind, children)
+	case "not":
+		c := specializeFalco(n.children[0], tool, lists)
+		if c.kind == "const" {
+			return falcoConst(!c.value)
+		}
+		return &falcoNode{kind: "not", children: []*falcoNode{c}}
+	case "check":
+		return specializeFalcoCheck(n, tool, lists)
+	}
+	return n
+}
+
+func specializeFalcoCheck(n *falcoNode, tool string, lists map[string][]string) *falcoNode {
+	negated := n.operator == "!="
+	switch {
+	case n.field == "evt.dir":
+		return falcoConst(true)
+	case n.field == "container.id" && len(n.values) == 1 && n.values[0] == "host" && (n.operator == "=" || n.operator == "==" || negated):
+		return falcoConst(negated)
+	case n.field == "evt.type" && (n.operator == "=" || n.operator == "==" || n.operator == "in" || negated):
+		var matched []string
+		for _, value := range expandFalcoItems(n.values, lists) {
+			if falcoSyscallTools[value] == tool {
+				matched = append(matched, value)
+			}
+		}
+		if len(matched) == 0 {
+			return falcoConst(negated)
+		}
+		if tool != "memory" {
+			return falcoConst(!negated)
+		}
+		check := falcoCheck("evt.type", "in", uniqueSorted(matched))
+		if negated {
+			return &falcoNode{kind: "not", children: []*falcoNode{check}}
+		}
+		return check
+	}
+	return n
+}
+
+// 정리한 AST를 HActiV 조건식으로 쓴다. used에는 조건이 참조한 list를 모은다.
+type falcoRenderer struct {
+	importer *falcoImporter
+	tool     string
+	used     map[string][]string
+}
+
+func (r *falcoRenderer) field(falcoField string) (string, bool) {
+	if r.tool == "memory" && falcoField == "evt.type" {
+		return "Syscall", true
+	}
+	if field, ok := falcoFields[""][falcoField]; ok {
+		return field, true
+	}
+	field, ok := falcoFields[r.tool][falcoField]
+	return field, ok
+}
+
+func (r *falcoRenderer) render(n *falcoNode) (string, error) {
+	switch n.kind {
+	case "and", "or":
+		var parts []string
+		for _, child := range n.children {
+			part, err := r.render(child)
+			if err != nil {
+				return "", err
+			}
+			if child.kind == "and" || child.kind == "or" {
+				part = "(" + part + ")"
+			}
+			parts = append(parts, part)
+		}
+		return strings.Join(parts, " "+n.kind+" "), nil
+	case "not":
+		operand, err := r.render(n.children[0])
+		if err != nil {
+			return "", err
+		}
+		if kind := n.children[0].kind; kind == "and" || kind == "or" {
+			operand = "(" + operand + ")"
+		}
+		return "not " + operand, nil
+	case "check":
+		return r.check(n)
+	}
+	return "", fmt.Errorf("변환할 수 없는 조건입니다.")
+}
+
+func (r *falcoRenderer) check(n *falcoNode) (string, error) {
+	field, ok := r.field(n.field)
+	if !ok {
+		return "", fmt.Errorf("필드 '%s'에 대응하는 HActiV 필드가 없습니다.", n.field)
+	}
+	fieldName := "%" + field + "%"
+	values := n.values
+	if field == "Protocol" {
+		values = make([]string, len(n.values))
+		for i, value := range n.values {
+			values[i] = strings.ToUpper(value)
+		}
+	}
+
+	switch n.operator {
+	case "=", "==", "!=", "<", "<=", ">", ">=", "contains", "icontains", "glob", "regex", "startswith", "endswith":
+		value := values[0]
+		if _, isList := r.importer.lists[value]; isList {
+			return "", fmt.Errorf("%s %s의 값에 list '%s'를 쓸 수 없습니다.", n.field, n.operator, value)
+		}
+		switch n.operator {
+		case "=":
+			return fieldName + " == " + quoteConditionValue(value), nil
+		case "contains":
+			return fieldName + " () " + quoteConditionValue(value), nil
+		case "icontains":
+			return fieldName + " i() " + quoteConditionValue(value), nil
+		case "startswith":
+			return fieldName + " =~ " + quoteConditionValue("^"+regexp.QuoteMeta(value)), nil
+		case "endswith":
+			return fieldName + " =~ " + quoteConditionValue(regexp.QuoteMeta(value)+"$"), nil
+		case "glob":
+			// Falco의 *는 '/'도 포함해 일치한다.
+			return fieldName + " glob " + quoteConditionValue(falcoGlobStar.ReplaceAllString(value, "**")), nil
+		case "regex":
+			return fieldName + " =~ " + quoteConditionValue(value), nil
+		}
+		return fieldName + " " + n.operator + " " + quoteConditionValue(value), nil
+	case "in":
+		return fieldName + " in " + conditionList(r.listItems(values)), nil
+	case "pmatch":
+		var prefixes []string
+		for _, item := range expandFalcoItems(values, r.importer.lists) {
+			prefixes = append(prefixes, regexp.QuoteMeta(strings.TrimSuffix(item, "/")))
+		}
+		if len(prefixes) == 0 {
+			return "", fmt.Errorf("%s pmatch의 목록이 비어 있습니다.", n.field)
+		}
+		return fieldName + " =~ " + quoteConditionValue("^("+strings.Join(prefixes, "|")+")(/|$)"), nil
+	}
+	return "", fmt.Errorf("연산자 '%s'는 지원하지 않습니다.", n.operator)
+}
+
+var falcoGlobStar = regexp.MustCompile(`\*+`)
+
+// in 목록의 항목. HActiV에서 쓸 수 있는 이름의 list는 이름으로 참조하고, 그 밖의 list는 펼친다.
+func (r *falcoRenderer) listItems(values []string) []string {
+	var items []string
+	for _, value := range values {
+		nested, isList := r.importer.lists[value]
+		switch {
+		case isList && definitionName.MatchString(value):
+			r.use(value)
+			items = append(items, value)
+		case isList:
+			items = append(items, r.listItems(nested)...)
+		default:
+			items = append(items, quoteConditionValue(value))
+		}
+	}
+	return items
+}
+
+// 참조한 list와 그 list가 참조하는 list를 기록한다.
+func (r *falcoRenderer) use(name string) {
+	if _, ok := r.used[name]; ok {
+		return
+	}
+	items := r.importer.lists[name]
+	r.used[name] = items
+	for _, item := range items {
+		if _, ok := r.importer.lists[item]; ok {
+			r.use(item)
+		}
+	}
+}
+
+var falcoOutputField = regexp.MustCompile(`%[A-Za-z0-9_]+(\.[A-Za-z0-9_]+)+(\[[^\]]*\])?`)
+
+// Falco output의 %필드를 HActiV의 %필드%로 바꾼다. 대응하는 필드가 없으면 '-'로 바꾸고 이름을 돌려준다.
+func (r *falcoRenderer) output(output string) (string, []string) {
+	var unmapped []string
+	format := falcoOutputField.ReplaceAllStringFunc(output, func(token string) string {
+		name := token[1:]
+		if name == "evt.time" {
+			return "%Time%"
+		}
+		if field, ok := r.field(name); ok {
+			return "%" + field + "%"
+		}
+		if !contains(unmapped, name) {
+			unmapped = append(unmapped, name)
+		}
+		return "-"
+	})
+	return format, unmapped
+}
@@ -0,0 +1,176 @@
+// Copyright Authors of HActiV
+
+// configs package for Setting and detection rules
+package configs
+
+import (
+	"HActiV/pkg/utils"
+	"fmt"
+	"strconv"
+)
+
+// 규칙에서 사용할 수 있는 Event 필드와 그 접근자.
+// 조건과 출력 형식은 LoadRules 시점에 이 접근자로 컴파일되므로 이벤트마다 reflection을 쓰지 않는다.
+type eventField struct {
+	kind     string
+	text     func(event *utils.Event) string
+	number   func(event *utils.Event) int64
+	unsigned func(event *utils.Event) uint64 // int64로 바꾸면 2^63 이상의 값이 음수가 되는 uint64 필드
+	float    func(event *utils.Event) float64
+}
+
+func stringField(get func(event *utils.Event) string) eventField {
+	return eventField{kind: "string", text: get}
+}
+
+func ipField(get func(event *utils.Event) string) eventField {
+	return eventField{kind: "ip", text: get}
+}
+
+func numberField(kind string, get func(event *utils.Event) int64) eventField {
+	return eventField{
+		kind:   kind,
+		number: get,
+		text: func(event *utils.Event) string {
+			return strconv.FormatInt(get(event), 10)
+		},
+	}
+}
+
+func unsignedField(get func(event *utils.Event) uint64) eventField {
+	return eventField{
+		kind:     "uint64",
+		unsigned: get,
+		text: func(event *utils.Event) string {
+			return strconv.FormatUint(get(event), 10)
+		},
+	}
+}
+
+// StartAddr/EndAddr는 기존 출력과 같이 16진수로 표시한다.
+func addressField(get func(event *utils.Event) uint64) eventField {
+	field := unsignedField(get)
+	field.text = func(event *utils.Event) string {
+		return fmt.Sprintf("0x%X", get(event))
+	}
+	return field
+}
+
+var eventFields = map[string]eventField{
+	"Tool":           stringField(func(e *utils.Event) string { return e.Tool }),
+	"Time":           stringField(func(e *utils.Event) string { return e.Time }),
+	"ContainerName":  stringField(func(e *utils.Event) string { return e.ContainerName }),
+	"ContainerImage": stringField(func(e *utils.Event) string { return e.ContainerImage }),
+	"Uid":            numberField("uint32", func(e *utils.Event) int64 { return int64(e.Uid) }),
+	"Gid":            numberField("uint32", func(e *utils.Event) int64 { return int64(e.Gid) }),
+	"Pid":            numberField("uint32", func(e *utils.Event) int64 { return int64(e.Pid) }),
+	"Ppid":           numberField("uint32", func(e *utils.Event) int64 { return int64(e.Ppid) }),
+	"Puid":           numberField("uint32", func(e *utils.Event) int64 { return int64(e.Puid) }),
+	"Pgid":           numberField("uint32", func(e *utils.Event) int64 { return int64(e.Pgid) }),
+	"ProcessName":    stringField(func(e *utils.Event) string { return e.ProcessName }),
+	"Filename":       stringField(func(e *utils.Event) string { return e.Filename }),
+	"Args":           stringField(func(e *utils.Event) string { return e.Args }),
+	"SrcIp":          ipField(func(e *utils.Event) string { return e.SrcIp }),
+	"SrcIpLabel":     stringField(func(e *utils.Event) string { return e.SrcIpLabel }),
+	"DstIp":          ipField(func(e *utils.Event) string { return e.DstIp }),
+	"DstIpLabel":     stringField(func(e *utils.Event) string { return e.DstIpLabel }),
+	"Direction":      stringField(func(e *utils.Event) string { return e.Direction }),
+	"Protocol":       stringField(func(e *utils.Event) string { return e.Protocol }),
+	"Syscall":        stringField(func(e *utils.Event) string { return e.Syscall }),
+	"StartAddr":      addressField(func(e *utils.Event) uint64 { return e.StartAddr }),
+	"EndAddr":        addressField(func(e *utils.Event) uint64 { return e.EndAddr }),
+	"Size":           unsignedField(func(e *utils.Event) uint64 { return e.Size }),
+	"Prottemp":       numberField("uint32", func(e *utils.Event) int64 { return int64(e.Prottemp) }),
+	"Prot":           stringField(func(e *utils.Event) string { return e.Prot }),
+	"MappingType":    stringField(func(e *utils.Event) string { return e.MappingType }),
+	"SrcPort":        numberField("uint16", func(e *utils.Event) int64 { return int64(e.SrcPort) }),
+	"DstPort":        numberField("uint16", func(e *utils.Event) int64 { return int64(e.DstPort) }),
+	"PacketSize":     numberField("int", func(e *utils.Event) int64 { return int64(e.PacketSize) }),
+	"TotalSize":      numberField("int", func(e *utils.Event) int64 { return int64(e.TotalSize) }),
+	"PacketCount":    numberField("int", func(e *utils.Event) int64 { return int64(e.PacketCount) }),
+	"PathJson":       stringField(func(e *utils.Event) string { return e.PathJson }),
+	"ReturnValue":    numberField("int32", func(e *utils.Event) int64 { return int64(e.ReturnValue) }),
+	"Method":         stringField(func(e *utils.Event) string { return e.Method }),
+	"Host":           stringField(func(e *utils.Event) string { return e.Host }),
+	"URL":            stringField(func(e *utils.Event) string { return e.URL }),
+	"Parameters":     stringField(func(e *utils.Event) string { return e.Parameters }),
+}
+
+// print_format을 리터럴과 필드 조각으로 미리 나눠 둔 것.
+type printFormat []formatSegment
+
+type formatSegment struct {
+	literal string
+	field   func(event *utils.Event) string
+}
+
+// %Field%와 basename(%Filename%) 같은 함수 호출 중 올바른 것만 치환하고 나머지는 그대로 둔다.
+func compilePrintFormat(format string) printFormat {
+	var segments printFormat
+	literalStart := 0
+	for i := 0; i < len(format); i++ {
+		var field eventField
+		var end int
+		switch {
+		case format[i] == '%':
+			end = i + 1
+			for end < len(format) && format[end] != '%' {
+				end++
+			}
+			if end >= len(format) {
+				i = end
+				continue
+			}
+			var ok bool
+			if field, ok = eventFields[format[i+1:end]]; !ok {
+				continue
+			}
+			end++
+		case isFunctionStart(format, i):
+			p := &conditionParser{src: format, pos: i}
+			if !p.functionCall() {
+				continue
+			}
+			var err error
+			if field, _, err = p.parseOperand(); err != nil {
+				continue
+			}
+			end = p.pos
+		default:
+			continue
+		}
+		if literalStart < i {
+			segments = append(segments, formatSegment{literal: format[literalStart:i]})
+		}
+		segments = append(segments, formatSegment{field: field.text})
+		literalStart = end
+		i = end - 1
+	}
+	if literalStart < len(format) {
+		segments = append(segments, formatSegment{literal: format[literalStart:]})
+	}
+	return segments
+}
+
+// 함수 이름이 될 수 있는 단어의 시작. 'xbasename(' 같은 단어 중간은 함수로 보지 않는다.
+func isFunctionStart(format string, i int) bool {
+	isWordChar := func(c byte) bool {
+		return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_'
+	}
+	return format[i] >= 'a' && format[i] <= 'z' && (i == 0 || !isWordChar(format[i-1]))
+}
+
+func (f printFormat) render(event *utils.Event) string {
+	if len(f) == 1 && f[0].field == nil {
+		return f[0].literal
+	}
+	var b []byte
+	for _, segment := range f {
+		if segment.field != nil {
+			b = append(b, segment.field(event)...)
+		} else {
+			b = append(b, segment.literal...)
+		}
+	}
+	return string(b)
+}
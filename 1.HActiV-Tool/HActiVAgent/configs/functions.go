@@ -0,0 +1,146 @@
+// Copyright Authors of HActiV
+
+// configs package for Setting and detection rules
+package configs
+
+import (
+	"HActiV/pkg/utils"
+	"encoding/base64"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// 조건과 print_format에서 필드 값에 적용하는 함수. 인자는 %Field% 또는 다른 함수 호출 하나이며
+// 정수 필드는 출력과 같은 문자열로 바꿔 넘긴다.
+//
+//	basename(%Filename%) in [sh, bash]
+//	entropy(base64decode(%Args%)) > 4.5
+//	print_format: "실행 파일: basename(%Filename%)"
+type fieldFunction func(arg eventField) eventField
+
+var fieldFunctions = map[string]fieldFunction{
+	"basename":     textFunction(basename),
+	"dirname":      textFunction(dirname),
+	"lower":        textFunction(strings.ToLower),
+	"base64decode": textFunction(base64Decode),
+	"len": func(arg eventField) eventField {
+		return numberField("int", func(e *utils.Event) int64 { return int64(utf8.RuneCountInString(arg.text(e))) })
+	},
+	"entropy": func(arg eventField) eventField {
+		return floatField(func(e *utils.Event) float64 { return shannonEntropy(arg.text(e)) })
+	},
+}
+
+func textFunction(apply func(s string) string) fieldFunction {
+	return func(arg eventField) eventField {
+		return stringField(func(e *utils.Event) string { return apply(arg.text(e)) })
+	}
+}
+
+// 실수 값. 출력에는 소수점 아래 두 자리까지 쓴다.
+func floatField(get func(event *utils.Event) float64) eventField {
+	return eventField{
+		kind:  "float",
+		float: get,
+		text: func(event *utils.Event) string {
+			return strconv.FormatFloat(get(event), 'f', 2, 64)
+		},
+	}
+}
+
+func functionNames() string {
+	return strings.Join(sortedNames(fieldFunctions), ", ")
+}
+
+// 경로의 마지막 요소. 빈 값은 그대로 둔다.
+func basename(s string) string {
+	if s == "" {
+		return ""
+	}
+	return path.Base(s)
+}
+
+func dirname(s string) string {
+	if s == "" {
+		return ""
+	}
+	return path.Dir(s)
+}
+
+// 패딩 유무와 URL 안전 문자를 모두 받아들인다. base64가 아니면 빈 문자열을 돌려준다.
+func base64Decode(s string) string {
+	s = strings.TrimSpace(s)
+	for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if decoded, err := encoding.DecodeString(s); err == nil {
+			return string(decoded)
+		}
+	}
+	return ""
+}
+
+// 바이트 단위 Shannon 엔트로피(비트). 난독화되거나 인코딩된 문자열일수록 값이 크다.
+func shannonEntropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	var counts [256]int
+	for i := 0; i < len(s); i++ {
+		counts[s[i]]++
+	}
+	entropy := 0.0
+	total := float64(len(s))
+	for _, count := range counts {
+		if count == 0 {
+			continue
+		}
+		p := float64(count) / total
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// 현재 위치가 함수 호출(이름 바로 뒤에 '(')인지 확인한다.
+func (p *conditionParser) functionCall() bool {
+	word := p.peekWord()
+	end := p.pos + len(word)
+	return word != "" && !strings.HasPrefix(word, "%") && end < len(p.src) && p.src[end] == '('
+}
+
+// operand := %Field% | 함수 "(" operand ")"
+// 필드 접근자에 함수를 적용한 접근자와 조건식에 쓰인 그대로의 문자열을 돌려준다.
+func (p *conditionParser) parseOperand() (eventField, string, error) {
+	p.skipSpace()
+	start := p.pos
+	if !p.functionCall() {
+		word := p.peekWord()
+		p.pos += len(word)
+		if !strings.HasPrefix(word, "%") || !strings.HasSuffix(word, "%") || len(word) < 3 {
+			return eventField{}, "", p.errorf(start, "필드명 '%s'은(는) %%Field%% 형식이어야 합니다.", word)
+		}
+		field, ok := eventFields[strings.Trim(word, "%")]
+		if !ok || field.kind == "" {
+			return eventField{}, "", p.errorf(start, "필드명 '%s'이(가) 올바르지 않습니다.", word)
+		}
+		return field, word, nil
+	}
+
+	name := p.peekWord()
+	function, ok := fieldFunctions[name]
+	if !ok {
+		return eventField{}, "", p.errorf(start, "함수 '%s'은(는) 없습니다. %s 중에서 사용하세요.", name, functionNames())
+	}
+	p.pos += len(name) + 1
+	arg, _, err := p.parseOperand()
+	if err != nil {
+		return eventField{}, "", err
+	}
+	p.skipSpace()
+	if p.atEnd() || p.src[p.pos] != ')' {
+		return eventField{}, "", p.errorf(start, "함수 '%s'의 '('에 대응하는 ')'가 없습니다.", name)
+	}
+	p.pos++
+	return function(arg), p.src[start:p.pos], nil
+}
@@ -0,0 +1,389 @@
+// Copyright Authors of HActiV
+
+// configs package for Setting and detection rules
+package configs
+
+import (
+	"HActiV/pkg/utils"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// exec:<스크립트>, webhook:<이름> 액션의 설정 파일. 없으면 기본값을 쓰며 webhook은 정의한 것만 쓸 수 있다.
+//
+//	{
+//	  "max_concurrent": 4,
+//	  "exec": {
+//	    "default": {"timeout": "10s"},
+//	    "isolate.sh": {"timeout": "30s", "retries": 2, "retry_delay": "2s"}
+//	  },
+//	  "webhooks": {
+//	    "soar": {"url": "https://soar.example.com/hooks/hactiv", "headers": {"Authorization": "Bearer ..."}, "retries": 3}
+//	  }
+//	}
+const hooksFileName = "actions.json"
+
+type HookSettings struct {
+	MaxConcurrent int                   `json:"max_concurrent,omitempty"`
+	Exec          map[string]HookPolicy `json:"exec,omitempty"`
+	Webhooks      map[string]Webhook    `json:"webhooks,omitempty"`
+}
+
+// 실행 한 번의 제한 시간과 실패했을 때 다시 시도할 횟수. 다시 시도할 때마다 retry_delay를 두 배로 늘린다.
+type HookPolicy struct {
+	Timeout    string `json:"timeout,omitempty"`
+	Retries    int    `json:"retries,omitempty"`
+	RetryDelay string `json:"retry_delay,omitempty"`
+}
+
+type Webhook struct {
+	URL     string            `json:"url"`
+	Method  string            `json:"method,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	HookPolicy
+}
+
+const (
+	defaultHookTimeout       = 10 * time.Second
+	defaultHookRetryDelay    = time.Second
+	defaultHookMaxConcurrent = 4
+	// 동시에 실행 중인 것을 포함해 max_concurrent의 이 배수까지 대기시키고, 넘으면 실행하지 않는다.
+	hookQueueFactor = 16
+)
+
+type hookPolicy struct {
+	timeout    time.Duration
+	retries    int
+	retryDelay time.Duration
+}
+
+type webhook struct {
+	url     string
+	method  string
+	headers map[string]string
+	policy  hookPolicy
+}
+
+// 컴파일된 actions.json과 실행 슬롯. 다시 불러오면 통째로 바꾸며 실행 중인 것은 이전 슬롯을 그대로 쓴다.
+type hookRunner struct {
+	exec     map[string]hookPolicy
+	webhooks map[string]webhook
+	slots    chan struct{}
+	pending  atomic.Int32
+}
+
+var hooks atomic.Pointer[hookRunner]
+
+// 규칙 디렉터리의 actions.json을 불러온다. 파일이 없으면 기본값을 쓴다.
+func LoadHooks() error {
+	settings, err := readHookSettings(RuleLocation + hooksFileName)
+	if err != nil {
+		return err
+	}
+	runner, err := compileHookSettings(settings)
+	if err != nil {
+		return err
+	}
+	hooks.Store(runner)
+	if len(runner.webhooks) > 0 {
+		fmt.Printf("webhook: %s\n", strings.Join(sortedNames(runner.webhooks), ", "))
+	}
+	return nil
+}
+
+func readHookSettings(filename string) (*HookSettings, error) {
+	data, err := os.ReadFile(filename)
+	if os.IsNotExist(err) || (err == nil && len(bytes.TrimSpace(data)) == 0) {
+		return &HookSettings{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var settings HookSettings
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+func compileHookSettings(settings *HookSettings) (*hookRunner, error) {
+	maxConcurrent := settings.MaxConcurrent
+	if maxConcurrent < 0 {
+		return nil, fmt.Errorf("max_concurrent는 0 이상이어야 합니다.")
+	}
+	if maxConcurrent == 0 {
+		maxConcurrent = defaultHookMaxConcurrent
+	}
+	runner := &hookRunner{
+		exec:     make(map[string]hookPolicy),
+		webhooks: make(map[string]webhook),
+		slots:    make(chan struct{}, maxConcurrent),
+	}
+
+	defaultPolicy := hookPolicy{timeout: defaultHookTimeout, retryDelay: defaultHookRetryDelay}
+	if p, ok := settings.Exec["default"]; ok {
+		var err error
+		if defaultPolicy, err = compileHookPolicy(p, defaultPolicy); err != nil {
+			return nil, fmt.Errorf("exec.default: %v", err)
+		}
+	}
+	runner.exec["default"] = defaultPolicy
+	for _, script := range sortedNames(settings.Exec) {
+		if script == "default" {
+			continue
+		}
+		if _, err := scriptPath(script); err != nil {
+			return nil, fmt.Errorf("exec.%s: %v", script, err)
+		}
+		p, err := compileHookPolicy(settings.Exec[script], defaultPolicy)
+		if err != nil {
+			return nil, fmt.Errorf("exec.%s: %v", script, err)
+		}
+		runner.exec[script] = p
+	}
+
+	for _, name := range sortedNames(settings.Webhooks) {
+		w := settings.Webhooks[name]
+		if !definitionName.MatchString(name) {
+			return nil, fmt.Errorf("webhooks.%s: 이름은 영문자, 숫자, '_'만 쓸 수 있고 숫자로 시작할 수 없습니다.", name)
+		}
+		u, err := url.Parse(w.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("webhooks.%s: url '%s'은(는) http 또는 https 주소여야 합니다.", name, w.URL)
+		}
+		method := strings.ToUpper(w.Method)
+		if method == "" {
+			method = http.MethodPost
+		}
+		if method != http.MethodPost && method != http.MethodPut {
+			return nil, fmt.Errorf("webhooks.%s: method는 POST 또는 PUT이어야 합니다.", name)
+		}
+		p, err := compileHookPolicy(w.HookPolicy, hookPolicy{timeout: defaultHookTimeout, retryDelay: defaultHookRetryDelay})
+		if err != nil {
+			return nil, fmt.Errorf("webhooks.%s: %v", name, err)
+		}
+		runner.webhooks[name] = webhook{url: w.URL, method: method, headers: w.Headers, policy: p}
+	}
+	return runner, nil
+}
+
+// 지정하지 않은 항목은 base의 값을 쓴다.
+func compileHookPolicy(p HookPolicy, base hookPolicy) (hookPolicy, error) {
+	compiled := base
+	if p.Timeout != "" {
+		timeout, err := time.ParseDuration(p.Timeout)
+		if err != nil || timeout <= 0 {
+			return compiled, fmt.Errorf("timeout '%s'이(가) 올바르지 않습니다. 예시: 5s, 1m", p.Timeout)
+		}
+		compiled.timeout = timeout
+	}
+	if p.Retries < 0 || p.Retries > 10 {
+		return compiled, fmt.Errorf("retries는 0부터 10까지 쓸 수 있습니다.")
+	}
+	if p.Retries > 0 {
+		compiled.retries = p.Retries
+	}
+	if p.RetryDelay != "" {
+		delay, err := time.ParseDuration(p.RetryDelay)
+		if err != nil || delay <= 0 {
+			return compiled, fmt.Errorf("retry_delay '%s'이(가) 올바르지 않습니다. 예시: 1s, 500ms", p.RetryDelay)
+		}
+		compiled.retryDelay = delay
+	}
+	return compiled, nil
+}
+
+// exec:, webhook: 액션인지 확인한다.
+func isHookAction(action string) bool {
+	return strings.HasPrefix(action, "exec:") || strings.HasPrefix(action, "webhook:")
+}
+
+// 규칙의 액션 하나를 검사한다. webhook 이름이 actions.json에 있는지는 실행할 때 확인한다.
+func checkAction(action string) error {
+	switch {
+	case strings.HasPrefix(action, "exec:"):
+		if _, err := scriptPath(strings.TrimPrefix(action, "exec:")); err != nil {
+			return fmt.Errorf("액션 '%s': %v", action, err)
+		}
+	case strings.HasPrefix(action, "webhook:"):
+		if name := strings.TrimPrefix(action, "webhook:"); !definitionName.MatchString(name) {
+			return fmt.Errorf("액션 '%s'의 webhook 이름이 올바르지 않습니다.", action)
+		}
+	case !contains(knownActions, action):
+		return fmt.Errorf("액션 '%s'이(가) 올바르지 않습니다. %s, exec:<스크립트>, webhook:<이름> 중에서 사용하세요.", action, strings.Join(knownActions, ", "))
+	}
+	return nil
+}
+
+// 스크립트 경로. 규칙 디렉터리의 scripts/ 아래에서만 찾으며, 절대 경로나 그 밖을 가리키는 경로는 쓸 수 없다.
+// 이벤트 JSON을 표준 입력으로 넘기므로 셸 같은 임의의 프로그램을 실행하지 못하게 한다.
+func scriptPath(script string) (string, error) {
+	if script == "" {
+		return "", fmt.Errorf("스크립트가 없습니다.")
+	}
+	if filepath.IsAbs(script) {
+		return "", fmt.Errorf("스크립트 '%s': 절대 경로는 쓸 수 없습니다. 규칙 디렉터리의 scripts/ 아래에 두세요.", script)
+	}
+	cleaned := filepath.Clean(script)
+	if cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("스크립트 '%s'은(는) scripts/ 디렉터리 밖을 가리킵니다.", script)
+	}
+	return RuleLocation + "scripts/" + cleaned, nil
+}
+
+// 스크립트의 표준 입력과 webhook 요청 본문
+type hookPayload struct {
+	Policy  *Policy     `json:"policy"`
+	Message string      `json:"message"`
+	Event   utils.Event `json:"event"`
+}
+
+// exec:, webhook: 액션을 실행 슬롯에 넣는다. 모니터를 막지 않도록 따로 실행하고 결과는 감사 로그에 남긴다.
+func runHook(policy *Policy, event utils.Event, message, action string) {
+	runner := hooks.Load()
+	if runner == nil {
+		runner, _ = compileHookSettings(&HookSettings{})
+		hooks.CompareAndSwap(nil, runner)
+		runner = hooks.Load()
+	}
+	audit := responseAudit{
+		EventTime: event.Time,
+		Policy:    policy.PolicyName,
+		Action:    action,
+		Container: event.ContainerName,
+		DryRun:    ResponseDryRun,
+	}
+
+	run, err := runner.prepare(action)
+	if err != nil {
+		finishResponse(&audit, err)
+		return
+	}
+	if ResponseDryRun {
+		audit.Result = "dry-run"
+		writeResponseAudit(audit)
+		return
+	}
+	payload, err := json.Marshal(hookPayload{Policy: policy, Message: message, Event: event})
+	if err != nil {
+		finishResponse(&audit, err)
+		return
+	}
+	if int(runner.pending.Add(1)) > cap(runner.slots)*hookQueueFactor {
+		runner.pending.Add(-1)
+		audit.Result = "skipped"
+		audit.Error = "대기 중인 실행이 너무 많습니다."
+		writeResponseAudit(audit)
+		return
+	}
+
+	go func() {
+		defer runner.pending.Add(-1)
+		runner.slots <- struct{}{}
+		defer func() { <-runner.slots }()
+		audit.Attempts, err = run(payload)
+		finishResponse(&audit, err)
+	}()
+}
+
+// 액션에 맞는 실행 함수. 실행 함수는 시도한 횟수와 마지막 오류를 돌려준다.
+func (r *hookRunner) prepare(action string) (func(payload []byte) (int, error), error) {
+	if strings.HasPrefix(action, "webhook:") {
+		name := strings.TrimPrefix(action, "webhook:")
+		w, ok := r.webhooks[name]
+		if !ok {
+			return nil, fmt.Errorf("%s에 webhook '%s'이(가) 없습니다.", hooksFileName, name)
+		}
+		return func(payload []byte) (int, error) {
+			return retryHook(w.policy, func(ctx context.Context) (bool, error) { return w.send(ctx, payload) })
+		}, nil
+	}
+
+	script := strings.TrimPrefix(action, "exec:")
+	path, err := scriptPath(script)
+	if err != nil {
+		return nil, err
+	}
+	p, ok := r.exec[script]
+	if !ok {
+		p = r.exec["default"]
+	}
+	return func(payload []byte) (int, error) {
+		return retryHook(p, func(ctx context.Context) (bool, error) { return true, runScript(ctx, path, payload) })
+	}, nil
+}
+
+// 실패하면 retries번까지 다시 시도한다. attempt가 다시 시도해도 소용없는 실패(false)를 돌려주면 멈춘다.
+func retryHook(p hookPolicy, attempt func(ctx context.Context) (bool, error)) (int, error) {
+	delay := p.retryDelay
+	var err error
+	for i := 0; i <= p.retries; i++ {
+		if i > 0 {
+			time.Sleep(delay)
+			delay *= 2
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+		var retryable bool
+		retryable, err = attempt(ctx)
+		cancel()
+		if err == nil || !retryable {
+			return i + 1, err
+		}
+	}
+	return p.retries + 1, err
+}
+
+// 스크립트의 출력은 실패했을 때만 오류 메시지에 앞부분을 붙인다.
+func runScript(ctx context.Context, path string, payload []byte) error {
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.WaitDelay = time.Second
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("제한 시간을 넘었습니다.")
+	}
+	if err != nil {
+		if out := strings.TrimSpace(string(output)); out != "" {
+			if len(out) > 200 {
+				out = out[:200] + "..."
+			}
+			return fmt.Errorf("%v: %s", err, out)
+		}
+		return err
+	}
+	return nil
+}
+
+// 2xx 응답이면 성공이다. 연결 오류, 429, 5xx만 다시 시도한다.
+func (w webhook) send(ctx context.Context, payload []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, w.method, w.url, bytes.NewReader(payload))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for _, key := range sortedNames(w.headers) {
+		req.Header.Set(key, w.headers[key])
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retryable := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retryable, fmt.Errorf("응답 상태 %s", resp.Status)
+}
@@ -0,0 +1,326 @@
+// Copyright Authors of HActiV
+
+// configs package for Setting and detection rules
+package configs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Sigma, Falco 규칙을 변환한 결과. 도구별로 YAML 규칙 파일(<도구>rule.yaml) 하나씩을 만든다.
+// 변환할 수 없는 규칙은 건너뛰고 Issues에 이유를 남긴다. 출력 형식만 바꿀 수 있는 경우에도 Issues에 남기고 규칙은 변환한다.
+type ImportResult struct {
+	Issues   []LintIssue
+	Skipped  int
+	files    map[string]*importedFile
+	imported int
+}
+
+// 도구 하나의 YAML 규칙 파일 내용
+type importedFile struct {
+	lists map[string][]string
+	rules []Rule
+}
+
+func (r *ImportResult) Imported() int {
+	return r.imported
+}
+
+// 변환된 규칙이 있는 도구 이름
+func (r *ImportResult) Tools() []string {
+	return sortedNames(r.files)
+}
+
+func (r *ImportResult) Rules(tool string) int {
+	if file, ok := r.files[tool]; ok {
+		return len(file.rules)
+	}
+	return 0
+}
+
+func (r *ImportResult) issue(file string, line int, rule, format string, args ...interface{}) {
+	r.Issues = append(r.Issues, LintIssue{File: file, Line: line, Rule: rule, Message: fmt.Sprintf(format, args...)})
+}
+
+func (r *ImportResult) skip(file string, line int, rule, format string, args ...interface{}) {
+	r.issue(file, line, rule, "변환하지 않았습니다: "+format, args...)
+	r.Skipped++
+}
+
+// 규칙이 참조하는 목록과 함께 규칙을 도구의 파일에 더한다. 에이전트와 같은 방법으로 컴파일해 보고 실패하면 건너뛴다.
+func (r *ImportResult) add(file string, line int, tool string, rule Rule, lists map[string][]string) {
+	env, errs := newConditionEnv(lists, nil)
+	if len(errs) > 0 {
+		r.skip(file, line, rule.EventName, "%v", errs[0])
+		return
+	}
+	if _, errs := compileRule(rule, getFieldTypes(), env); len(errs) > 0 {
+		r.skip(file, line, rule.EventName, "%s", joinRuleErrors(errs))
+		return
+	}
+
+	if r.files == nil {
+		r.files = make(map[string]*importedFile)
+	}
+	out, ok := r.files[tool]
+	if !ok {
+		out = &importedFile{lists: make(map[string][]string)}
+		r.files[tool] = out
+	}
+	for name, items := range lists {
+		out.lists[name] = items
+	}
+	out.rules = append(out.rules, rule)
+	r.imported++
+}
+
+// Sigma 규칙(문서마다 규칙 하나)과 Falco 규칙 파일(rule, list, macro 항목의 목록)을 형식을 알아내 변환한다.
+// Falco의 list와 macro는 Falco와 같이 파일 순서대로 쌓이므로 기본 규칙 파일을 먼저 넘긴다.
+func ImportRuleFiles(filenames []string) (*ImportResult, error) {
+	result := &ImportResult{}
+	falco := newFalcoImporter(result)
+	for _, filename := range filenames {
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		docs, err := decodeYAMLDocuments(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", filename, err)
+		}
+		for _, doc := range docs {
+			switch {
+			case doc.Kind == yaml.SequenceNode:
+				falco.importFile(filename, doc)
+			case yamlMapValue(doc, "detection") != nil:
+				importSigmaRule(result, filename, doc)
+			default:
+				result.skip(filename, doc.Line, "", "Sigma 규칙(detection이 있는 객체)이나 Falco 규칙 파일(rule, list, macro 항목의 목록)이 아닙니다.")
+			}
+		}
+	}
+	return result, nil
+}
+
+// 객체에서 키의 값. 객체가 아니거나 키가 없으면 nil이다.
+func yamlMapValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func decodeYAMLDocuments(data []byte) ([]*yaml.Node, error) {
+	var docs []*yaml.Node
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc yaml.Node
+		if err := dec.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				return docs, nil
+			}
+			return nil, err
+		}
+		if len(doc.Content) > 0 {
+			docs = append(docs, doc.Content[0])
+		}
+	}
+}
+
+// 도구의 YAML 규칙 파일 내용. 목록 다음에 규칙을 원래 순서대로 쓴다.
+func (r *ImportResult) YAML(tool string) ([]byte, error) {
+	file, ok := r.files[tool]
+	if !ok {
+		return nil, fmt.Errorf("변환된 %s 규칙이 없습니다.", tool)
+	}
+
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	if len(file.lists) > 0 {
+		lists := &yaml.Node{Kind: yaml.MappingNode}
+		for _, name := range sortedNames(file.lists) {
+			items := &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+			for _, item := range file.lists[name] {
+				items.Content = append(items.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: item})
+			}
+			lists.Content = append(lists.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: name}, items)
+		}
+		root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: "lists"}, lists)
+	}
+
+	rules := &yaml.Node{Kind: yaml.SequenceNode}
+	for _, rule := range file.rules {
+		// JSON은 YAML이므로 json 태그의 키 순서를 그대로 쓰고 블록 형식으로만 바꾼다.
+		data, err := json.Marshal(rule)
+		if err != nil {
+			return nil, err
+		}
+		var doc yaml.Node
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, err
+		}
+		rules.Content = append(rules.Content, blockStyle(doc.Content[0]))
+	}
+	root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: "rules"}, rules)
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(root); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// 값이 null인 항목을 빼고 블록 형식으로 바꾼다. 문자열만 있는 목록은 한 줄로 쓴다.
+func blockStyle(node *yaml.Node) *yaml.Node {
+	node.Style = 0
+	switch node.Kind {
+	case yaml.MappingNode:
+		var content []*yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i+1].Tag == "!!null" {
+				continue
+			}
+			content = append(content, blockStyle(node.Content[i]), blockStyle(node.Content[i+1]))
+		}
+		node.Content = content
+	case yaml.SequenceNode:
+		flow := true
+		for _, item := range node.Content {
+			blockStyle(item)
+			flow = flow && item.Kind == yaml.ScalarNode
+		}
+		if flow {
+			node.Style = yaml.FlowStyle
+		}
+	}
+	return node
+}
+
+// 변환한 규칙의 출력 형식. 원본에 출력 형식이 없으면 도구별 기본 형식을 쓴다.
+var importPrintFormats = map[string]string{
+	"execve":  "Uid: %Uid% | Pid: %Pid% | PPid: %Ppid% | ProcessName: %ProcessName% | Filename: %Filename% | Args: %Args%",
+	"open":    "Uid: %Uid% | Pid: %Pid% | ProcessName: %ProcessName% | Filename: %Filename%",
+	"delete":  "Uid: %Uid% | Pid: %Pid% | ProcessName: %ProcessName% | Filename: %Filename%",
+	"memory":  "Pid: %Pid% | ProcessName: %ProcessName% | Syscall: %Syscall% | Prot: %Prot%",
+	"network": "ProcessName: %ProcessName% | %SrcIp%:%SrcPort% -> %DstIp%:%DstPort% | Protocol: %Protocol%",
+}
+
+func importedRule(tool, name, description, severity string, tags []string, mitre *Mitre, condition string) Rule {
+	if closing := matchingParen(condition); closing == len(condition)-1 {
+		condition = condition[1:closing]
+	}
+	return Rule{
+		EventName:   strings.Join(strings.Fields(name), "_"),
+		Description: description,
+		Usage:       true,
+		Severity:    severity,
+		Tags:        tags,
+		Mitre:       mitre,
+		Condition:   condition,
+		Action:      "print alert",
+		PrintFormat: "%Time% | %ContainerName% | " + importPrintFormats[tool],
+	}
+}
+
+// 'attack.t1059.004', 'attack.execution', 'mitre_execution', 'T1059' 같은 태그에서 MITRE ATT&CK ID를 찾고
+// 나머지 태그는 그대로 돌려준다. 전술 이름은 tacticPrefix로 시작하는 태그에서만 찾는다.
+func mitreFromTags(tags []string, tacticPrefix string) (*Mitre, []string) {
+	mitre := &Mitre{}
+	var rest []string
+	for _, tag := range tags {
+		lower := strings.ToLower(tag)
+		id := strings.ToUpper(strings.TrimPrefix(lower, "attack."))
+		switch {
+		case techniquePattern.MatchString(id):
+			mitre.Techniques = append(mitre.Techniques, id)
+		case tacticPattern.MatchString(id):
+			mitre.Tactics = append(mitre.Tactics, id)
+		case strings.HasPrefix(lower, tacticPrefix) && tacticID(strings.TrimPrefix(lower, tacticPrefix)) != "":
+			mitre.Tactics = append(mitre.Tactics, tacticID(strings.TrimPrefix(lower, tacticPrefix)))
+		default:
+			rest = append(rest, tag)
+		}
+	}
+	if len(mitre.Tactics) == 0 && len(mitre.Techniques) == 0 {
+		return nil, rest
+	}
+	return mitre, rest
+}
+
+// 'privilege_escalation', 'privilege-escalation' 같은 전술 이름의 ID
+func tacticID(name string) string {
+	name = strings.NewReplacer("_", " ", "-", " ").Replace(name)
+	for id, tacticName := range tacticNames {
+		if strings.EqualFold(tacticName, name) {
+			return id
+		}
+	}
+	return ""
+}
+
+var plainConditionValue = regexp.MustCompile(`^[^\s,\[\]()'"]+$`)
+
+// 조건식의 값으로 쓸 수 있도록 필요하면 따옴표로 감싼다.
+func quoteConditionValue(value string) string {
+	if plainConditionValue.MatchString(value) {
+		return value
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+func conditionList(items []string) string {
+	return "[" + strings.Join(items, ", ") + "]"
+}
+
+// 조건식이 '('로 시작하면 대응하는 ')'의 위치. 따옴표 안의 괄호는 세지 않는다.
+func matchingParen(condition string) int {
+	if !strings.HasPrefix(condition, "(") {
+		return -1
+	}
+	depth := 0
+	var quote byte
+	for i := 0; i < len(condition); i++ {
+		c := condition[i]
+		switch {
+		case quote != 0 && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// 항목 목록을 operator(and, or)로 묶는다. 항목이 둘 이상이면 괄호로 감싼다.
+func joinConditions(parts []string, operator string) string {
+	if len(parts) == 1 {
+		return parts[0]
+	}
+	return "(" + strings.Join(parts, " "+operator+" ") + ")"
+}
@@ -0,0 +1,269 @@
+// Copyright Authors of HActiV
+
+// configs package for Setting and detection rules
+package configs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const allowlistFileName = "allowlist.json"
+
+// rules lint가 찾은 문제 하나. Line은 1부터 시작하며, 규칙을 특정할 수 없으면 Rule은 비어 있다.
+type LintIssue struct {
+	File    string
+	Line    int
+	Rule    string
+	Message string
+}
+
+func (i LintIssue) String() string {
+	location := fmt.Sprintf("%s:%d", i.File, i.Line)
+	if i.Rule != "" {
+		location += ": " + i.Rule
+	}
+	return location + ": " + i.Message
+}
+
+// 경로 목록에서 검사할 파일(*rule.json, *rule.yaml, macros.yaml, allowlist.json, actions.json)을 찾는다.
+func FindLintFiles(paths []string) ([]string, error) {
+	return findFiles(paths, isRuleLocationFile)
+}
+
+// 규칙 디렉터리에서 읽는 파일인지 확인한다. 이 파일들이 바뀌면 규칙을 다시 불러온다.
+func isRuleLocationFile(name string) bool {
+	return strings.HasSuffix(name, "rule.json") || strings.HasSuffix(name, "rule.yaml") ||
+		name == sharedMacrosFileName || name == allowlistFileName || name == hooksFileName
+}
+
+// 규칙 파일을 수정하지 않고 검사한다. usage가 false인 규칙도 켰을 때 문제가 없는지 함께 검사한다.
+// 빈 파일은 FirstRules가 만드는 기본 상태이므로 문제로 보지 않는다.
+func LintFile(filename string) []LintIssue {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return []LintIssue{{File: filename, Line: 1, Message: err.Error()}}
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+
+	l := &linter{file: filename, data: data}
+	switch {
+	case filepath.Base(filename) == allowlistFileName:
+		l.lintAllowlist()
+	case filepath.Base(filename) == hooksFileName:
+		l.lintHooks()
+	case isYAMLRuleFile(filename) && IsSequenceRuleFile(filename):
+		l.reportLine(1, "", "순서 규칙은 JSON 파일(sequencerule.json)로만 작성할 수 있습니다.")
+	case isYAMLRuleFile(filename):
+		l.lintYAML(filepath.Base(filename) == sharedMacrosFileName)
+	case IsSequenceRuleFile(filename):
+		l.lintEntries(l.lintSequenceRule)
+	default:
+		l.lintEntries(l.lintRule)
+	}
+	return l.issues
+}
+
+type linter struct {
+	file   string
+	data   []byte
+	issues []LintIssue
+}
+
+func (l *linter) lineAt(offset int) int {
+	if offset > len(l.data) {
+		offset = len(l.data)
+	}
+	return bytes.Count(l.data[:offset], []byte("\n")) + 1
+}
+
+func (l *linter) report(offset int, rule, format string, args ...interface{}) {
+	l.reportLine(l.lineAt(offset), rule, format, args...)
+}
+
+func (l *linter) reportLine(line int, rule, format string, args ...interface{}) {
+	l.issues = append(l.issues, LintIssue{File: l.file, Line: line, Rule: rule, Message: fmt.Sprintf(format, args...)})
+}
+
+// JSON 디코딩 오류를 파일 안의 위치와 함께 기록한다. base는 디코딩한 조각이 파일에서 시작하는 위치이다.
+func (l *linter) reportJSON(base int, raw []byte, rule string, err error) {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		l.report(base+int(syntaxErr.Offset), rule, "JSON 문법 오류: %v", err)
+	case errors.As(err, &typeErr):
+		l.report(base+int(typeErr.Offset), rule, "'%s'의 값 형식이 올바르지 않습니다: %s 대신 %s", typeErr.Field, typeErr.Type, typeErr.Value)
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		key := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		l.report(base+keyOffset(raw, key), rule, "알 수 없는 항목 '%s'", key)
+	default:
+		l.report(base, rule, "%v", err)
+	}
+}
+
+// 최상위 배열의 각 규칙을 파일 안의 시작 위치와 함께 lint 함수에 넘긴다.
+func (l *linter) lintEntries(lint func(offset int, raw []byte)) {
+	dec := json.NewDecoder(bytes.NewReader(l.data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('[') {
+		l.report(0, "", "규칙 파일은 규칙 객체의 배열이어야 합니다.")
+		return
+	}
+	for dec.More() {
+		offset := skipSeparators(l.data, int(dec.InputOffset()))
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			l.reportJSON(0, nil, "", err)
+			return
+		}
+		lint(offset, raw)
+	}
+	if _, err := dec.Token(); err != nil {
+		l.reportJSON(0, nil, "", err)
+	}
+}
+
+func skipSeparators(data []byte, offset int) int {
+	for offset < len(data) && (isSpace(data[offset]) || data[offset] == ',') {
+		offset++
+	}
+	return offset
+}
+
+// 규칙 객체 안에서 최상위 키가 처음 나오는 위치. 찾지 못하면 객체의 시작 위치(0)를 돌려준다.
+func keyOffset(raw []byte, key string) int {
+	if i := bytes.Index(raw, []byte(`"`+key+`"`)); i >= 0 {
+		return i
+	}
+	return 0
+}
+
+func decodeStrict(raw []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+// 알림은 정책 이름으로 구분되므로 이름이 없는 규칙은 오류로 본다.
+// 같은 이름을 여러 조건에 나눠 쓰는 것은 기존 규칙 팩에서 쓰는 방식이므로 허용한다.
+func (l *linter) checkName(offset int, name string) {
+	if name == "" {
+		l.report(offset, "", "event_name이 비어 있습니다.")
+	}
+}
+
+func (l *linter) lintRule(offset int, raw []byte) {
+	var rule Rule
+	if err := decodeStrict(raw, &rule); err != nil {
+		l.reportJSON(offset, raw, "", err)
+		return
+	}
+	l.checkName(offset, rule.EventName)
+	_, errs := compileRule(rule, getFieldTypes(), nil)
+	for _, e := range errs {
+		l.report(offset+keyOffset(raw, e.key), rule.EventName, "%s", e.msg)
+	}
+}
+
+// YAML 규칙 파일과 macros.yaml을 검사한다. 규칙 파일은 같은 디렉터리의 macros.yaml 정의와 함께 검사하며,
+// macros.yaml에 있는 정의의 오류는 macros.yaml을 검사할 때 보고한다. 참조되지 않는 매크로도 컴파일해 본다.
+func (l *linter) lintYAML(shared bool) {
+	file, issues := parseYAMLRuleFile(l.data, true, !shared)
+	for _, issue := range issues {
+		l.reportLine(issue.line, "", "%s", issue.msg)
+	}
+
+	lists, macros := file.lists, file.macros
+	if !shared {
+		sharedFile, err := readSharedMacros(filepath.Dir(l.file))
+		if err != nil {
+			l.reportLine(1, "", "%v", err)
+			sharedFile = &yamlRuleFile{}
+		}
+		lists, macros = mergeDefinitions(sharedFile.lists, lists), mergeDefinitions(sharedFile.macros, macros)
+	}
+	env, defErrs := newConditionEnv(lists, macros)
+	definitionLine := func(name string) int {
+		if line, ok := file.lines["macros."+name]; ok {
+			return line
+		}
+		return file.lines["lists."+name]
+	}
+	for _, e := range defErrs {
+		if line := definitionLine(e.name); line > 0 {
+			l.reportLine(line, "", "%v", e)
+		}
+	}
+
+	fieldTypes := getFieldTypes()
+	for _, name := range sortedNames(file.macros) {
+		if _, ok := env.macros[name]; !ok {
+			continue
+		}
+		if _, err := env.macro(name, fieldTypes); err != nil {
+			l.reportLine(file.lines["macros."+name], "", "매크로 '%s': %v", name, err)
+		}
+	}
+	for _, r := range file.rules {
+		if r.rule.EventName == "" {
+			l.reportLine(r.node.Line, "", "event_name이 비어 있습니다.")
+		}
+		_, errs := compileRule(r.rule, fieldTypes, env)
+		for _, e := range errs {
+			l.reportLine(yamlKeyLine(r.node, e.key), r.rule.EventName, "%s", e.msg)
+		}
+	}
+}
+
+func (l *linter) lintSequenceRule(offset int, raw []byte) {
+	var rule SequenceRule
+	if err := decodeStrict(raw, &rule); err != nil {
+		l.reportJSON(offset, raw, "", err)
+		return
+	}
+	l.checkName(offset, rule.EventName)
+	if _, err := compileSequence(rule, getFieldTypes()); err != nil {
+		l.report(offset, rule.EventName, "%v", err)
+	}
+}
+
+func (l *linter) lintAllowlist() {
+	var allowlist Exceptions
+	if err := decodeStrict(l.data, &allowlist); err != nil {
+		l.reportJSON(0, l.data, "", err)
+		return
+	}
+	if _, err := compileExceptions(&allowlist); err != nil {
+		offset := 0
+		for _, key := range []string{"fields", "containers", "images", "processes"} {
+			if strings.HasPrefix(err.Error(), "exceptions."+key) {
+				offset = keyOffset(l.data, key)
+			}
+		}
+		l.report(offset, "", "%v", err)
+	}
+}
+
+func (l *linter) lintHooks() {
+	var settings HookSettings
+	if err := decodeStrict(l.data, &settings); err != nil {
+		l.reportJSON(0, l.data, "", err)
+		return
+	}
+	if _, err := compileHookSettings(&settings); err != nil {
+		offset := 0
+		for _, key := range []string{"max_concurrent", "exec", "webhooks"} {
+			if strings.HasPrefix(err.Error(), key) {
+				offset = keyOffset(l.data, key)
+			}
+		}
+		l.report(offset, "", "%v", err)
+	}
+}
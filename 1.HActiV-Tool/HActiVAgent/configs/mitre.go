@@ -0,0 +1,229 @@
+// Copyright Authors of HActiV
+
+// configs package for Setting and detection rules
+package configs
+
+import (
+	"HActiV/pkg/utils"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// 규칙이 탐지하는 MITRE ATT&CK 전술(TA0002)과 기법(T1059, T1059.004) ID
+type Mitre struct {
+	Tactics    []string `json:"tactics,omitempty"`
+	Techniques []string `json:"techniques,omitempty"`
+}
+
+var (
+	severities = []string{"info", "low", "medium", "high", "critical"}
+
+	tacticPattern    = regexp.MustCompile(`^TA\d{4}$`)
+	techniquePattern = regexp.MustCompile(`^T\d{4}(\.\d{3})?$`)
+)
+
+var tacticNames = map[string]string{
+	"TA0001": "Initial Access",
+	"TA0002": "Execution",
+	"TA0003": "Persistence",
+	"TA0004": "Privilege Escalation",
+	"TA0005": "Defense Evasion",
+	"TA0006": "Credential Access",
+	"TA0007": "Discovery",
+	"TA0008": "Lateral Movement",
+	"TA0009": "Collection",
+	"TA0010": "Exfiltration",
+	"TA0011": "Command and Control",
+	"TA0040": "Impact",
+	"TA0042": "Resource Development",
+	"TA0043": "Reconnaissance",
+}
+
+// 보고서 표시용 기법 이름. 기본 규칙 팩에서 쓰는 기법 위주로 담고, 없는 ID는 이름 없이 표시한다.
+var techniqueNames = map[string]string{
+	"T1003.008": "/etc/passwd and /etc/shadow",
+	"T1021.004": "Remote Services: SSH",
+	"T1037.004": "RC Scripts",
+	"T1041":     "Exfiltration Over C2 Channel",
+	"T1046":     "Network Service Discovery",
+	"T1048":     "Exfiltration Over Alternative Protocol",
+	"T1052.001": "Exfiltration over USB",
+	"T1053.003": "Scheduled Task/Job: Cron",
+	"T1055":     "Process Injection",
+	"T1059":     "Command and Scripting Interpreter",
+	"T1059.004": "Unix Shell",
+	"T1068":     "Exploitation for Privilege Escalation",
+	"T1070.002": "Clear Linux or Mac System Logs",
+	"T1070.003": "Clear Command History",
+	"T1070.006": "Timestomp",
+	"T1071":     "Application Layer Protocol",
+	"T1082":     "System Information Discovery",
+	"T1095":     "Non-Application Layer Protocol",
+	"T1105":     "Ingress Tool Transfer",
+	"T1189":     "Drive-by Compromise",
+	"T1190":     "Exploit Public-Facing Application",
+	"T1210":     "Exploitation of Remote Services",
+	"T1222.002": "Linux File and Directory Permissions Modification",
+	"T1485":     "Data Destruction",
+	"T1486":     "Data Encrypted for Impact",
+	"T1531":     "Account Access Removal",
+	"T1548.001": "Setuid and Setgid",
+	"T1548.003": "Sudo and Sudo Caching",
+	"T1562.001": "Disable or Modify Tools",
+	"T1610":     "Deploy Container",
+	"T1611":     "Escape to Host",
+}
+
+func validateRuleMeta(severity string, mitre *Mitre) error {
+	if severity != "" && !contains(severities, strings.ToLower(severity)) {
+		return fmt.Errorf("severity '%s'이(가) 올바르지 않습니다. %s 중 하나를 사용하세요.", severity, strings.Join(severities, ", "))
+	}
+	if mitre == nil {
+		return nil
+	}
+	for _, id := range mitre.Tactics {
+		if !tacticPattern.MatchString(id) {
+			return fmt.Errorf("mitre.tactics의 '%s'은(는) 전술 ID(TA0000) 형식이 아닙니다.", id)
+		}
+	}
+	for _, id := range mitre.Techniques {
+		if !techniquePattern.MatchString(id) {
+			return fmt.Errorf("mitre.techniques의 '%s'은(는) 기법 ID(T0000 또는 T0000.000) 형식이 아닙니다.", id)
+		}
+	}
+	return nil
+}
+
+func (policy *Policy) ruleMeta() utils.RuleMeta {
+	meta := utils.RuleMeta{
+		Severity: policy.Severity,
+		Tags:     policy.Tags,
+	}
+	if policy.Mitre != nil {
+		meta.MitreTactics = policy.Mitre.Tactics
+		meta.MitreTechniques = policy.Mitre.Techniques
+	}
+	return meta
+}
+
+func (policy *Policy) classification() string {
+	var parts []string
+	if policy.Severity != "" {
+		parts = append(parts, policy.Severity)
+	}
+	if len(policy.Tags) > 0 {
+		parts = append(parts, "tags="+strings.Join(policy.Tags, ","))
+	}
+	if policy.Mitre != nil {
+		parts = append(parts, "mitre="+strings.Join(append(append([]string{}, policy.Mitre.Tactics...), policy.Mitre.Techniques...), ","))
+	}
+	return strings.Join(parts, " | ")
+}
+
+func TacticName(id string) string {
+	return tacticNames[id]
+}
+
+func TechniqueName(id string) string {
+	if name, ok := techniqueNames[id]; ok {
+		return name
+	}
+	// 하위 기법 이름이 없으면 상위 기법 이름을 쓴다.
+	if i := strings.IndexByte(id, '.'); i > 0 {
+		return techniqueNames[id[:i]]
+	}
+	return ""
+}
+
+// 규칙 팩이 다루는 ATT&CK 전술과 기법
+type Coverage struct {
+	Rules      int
+	Mapped     int
+	Tactics    map[string][]string // 전술 ID -> "파일: 규칙명"
+	Techniques map[string][]string // 기법 ID -> "파일: 규칙명"
+	Unmapped   []string            // alert 액션이 있지만 mitre가 없는 규칙
+}
+
+// 규칙 파일(일반 규칙과 sequencerule.json)을 읽어 전술과 기법별로 어떤 규칙이 있는지 모은다.
+// all이 false이면 usage가 true인 규칙만 센다.
+func RuleCoverage(files []string, all bool) (*Coverage, error) {
+	coverage := &Coverage{
+		Tactics:    make(map[string][]string),
+		Techniques: make(map[string][]string),
+	}
+
+	type ruleInfo struct {
+		EventName string `json:"event_name"`
+		Usage     bool   `json:"usage"`
+		Action    string `json:"action"`
+		Mitre     *Mitre `json:"mitre"`
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if len(strings.TrimSpace(string(data))) == 0 {
+			continue
+		}
+		var rules []ruleInfo
+		if isYAMLRuleFile(file) {
+			yamlRules, _, err := readRuleFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", file, err)
+			}
+			for _, rule := range yamlRules {
+				rules = append(rules, ruleInfo{EventName: rule.EventName, Usage: rule.Usage, Action: rule.Action, Mitre: rule.Mitre})
+			}
+		} else if err := json.Unmarshal(data, &rules); err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+
+		for _, rule := range rules {
+			if !rule.Usage && !all {
+				continue
+			}
+			coverage.Rules++
+			label := file + ": " + rule.EventName
+			if rule.Mitre == nil || len(rule.Mitre.Techniques) == 0 {
+				if hasAction(rule.Action, "alert") && !contains(coverage.Unmapped, label) {
+					coverage.Unmapped = append(coverage.Unmapped, label)
+				}
+				continue
+			}
+			coverage.Mapped++
+			for _, tactic := range rule.Mitre.Tactics {
+				if !contains(coverage.Tactics[tactic], label) {
+					coverage.Tactics[tactic] = append(coverage.Tactics[tactic], label)
+				}
+			}
+			for _, technique := range rule.Mitre.Techniques {
+				if !contains(coverage.Techniques[technique], label) {
+					coverage.Techniques[technique] = append(coverage.Techniques[technique], label)
+				}
+			}
+		}
+	}
+	return coverage, nil
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (c *Coverage) SortedTactics() []string {
+	return sortedKeys(c.Tactics)
+}
+
+func (c *Coverage) SortedTechniques() []string {
+	return sortedKeys(c.Techniques)
+}
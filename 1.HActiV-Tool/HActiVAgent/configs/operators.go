@@ -0,0 +1,295 @@
+// Copyright Authors of HActiV
+
+// configs package for Setting and detection rules
+package configs
+
+import (
+	"HActiV/pkg/utils"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	integerTypes = []string{"int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64"}
+
+	numberOperators = []string{"==", "!=", ">", "<", ">=", "<=", "in", "!in"}
+	stringOperators = []string{"==", "!=", "()", "!()", "i==", "i!=", "i()", "i!()", "=~", "!~", "glob", "!glob", "in", "!in"}
+	ipOperators     = append(append([]string{}, stringOperators...), "cidr", "!cidr")
+)
+
+// CIDR(또는 IP) 목록을 네트워크 목록으로 변환한다.
+func parseNetworks(items []string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, item := range items {
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("'%s'은(는) 올바른 IP 또는 CIDR이 아닙니다.", item)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("'%s'은(는) 올바른 IP 또는 CIDR이 아닙니다.", item)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+func ipInNetworks(ip net.IP, networks []*net.IPNet) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// 경로 glob을 정규식으로 변환한다. '*'와 '?'는 '/'를 넘지 않고 '**'는 하위 경로 전체와 일치한다.
+func globToRegexp(glob string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				b.WriteString(".*")
+				i++
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("glob '%s'의 '['가 닫히지 않았습니다.", glob)
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end + 1
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+
+	pattern, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil, fmt.Errorf("glob '%s'이(가) 올바르지 않습니다: %v", glob, err)
+	}
+	return pattern, nil
+}
+
+// 부정 연산자는 대응하는 긍정 연산자의 결과를 뒤집는다. !cidr는 IP가 아닌 값에서 거짓이어야 하므로 stringPredicate에서 따로 평가한다.
+var negatedOperators = map[string]string{
+	"!=": "==", "!()": "()", "i!=": "i==", "i!()": "i()",
+	"!~": "=~", "!glob": "glob", "!in": "in", "!cidr": "cidr",
+}
+
+func newCompareNode(fieldName, operator string, value conditionValue) (conditionNode, error) {
+	field, ok := eventFields[fieldName]
+	if !ok {
+		return nil, fmt.Errorf("필드 '%s'은(는) 조건에 사용할 수 없습니다.", fieldName)
+	}
+	return newFieldCompareNode(field, operator, value)
+}
+
+// 필드 접근자(또는 함수를 적용한 접근자)와 값을 비교하는 노드를 만든다.
+func newFieldCompareNode(field eventField, operator string, value conditionValue) (conditionNode, error) {
+	base, negated := negatedOperators[operator]
+	if !negated || operator == "!cidr" {
+		base, negated = operator, false
+	}
+
+	var node predicateNode
+	var err error
+	switch {
+	case field.float != nil:
+		node, err = floatPredicate(field.float, base, value)
+	case field.number != nil:
+		node, err = numberPredicate(field.number, base, value)
+	case field.unsigned != nil:
+		node, err = unsignedPredicate(field.unsigned, base, value)
+	default:
+		node, err = stringPredicate(field.text, base, value)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if negated {
+		return predicateNode(func(event *utils.Event) bool { return !node(event) }), nil
+	}
+	return node, nil
+}
+
+func numberPredicate(get func(event *utils.Event) int64, operator string, value conditionValue) (predicateNode, error) {
+	if operator == "in" {
+		set := make(map[int64]struct{}, len(value.items))
+		for _, item := range value.items {
+			n, err := strconv.ParseInt(item, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("값 '%s'이(가) 정수가 아닙니다.", item)
+			}
+			set[n] = struct{}{}
+		}
+		return func(event *utils.Event) bool {
+			_, ok := set[get(event)]
+			return ok
+		}, nil
+	}
+
+	if value.list {
+		return nil, fmt.Errorf("연산자 '%s'에는 목록을 사용할 수 없습니다.", operator)
+	}
+	n, err := strconv.ParseInt(value.text, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("값 '%s'이(가) 정수가 아닙니다.", value.text)
+	}
+	switch operator {
+	case "==":
+		return func(event *utils.Event) bool { return get(event) == n }, nil
+	case ">":
+		return func(event *utils.Event) bool { return get(event) > n }, nil
+	case "<":
+		return func(event *utils.Event) bool { return get(event) < n }, nil
+	case ">=":
+		return func(event *utils.Event) bool { return get(event) >= n }, nil
+	case "<=":
+		return func(event *utils.Event) bool { return get(event) <= n }, nil
+	}
+	return nil, fmt.Errorf("연산자 '%s'는 정수 필드에 사용할 수 없습니다.", operator)
+}
+
+// uint64 필드는 int64로 바꾸지 않고 uint64로 비교한다. 음수 값은 쓸 수 없다.
+func unsignedPredicate(get func(event *utils.Event) uint64, operator string, value conditionValue) (predicateNode, error) {
+	if operator == "in" {
+		set := make(map[uint64]struct{}, len(value.items))
+		for _, item := range value.items {
+			n, err := strconv.ParseUint(item, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("값 '%s'이(가) 0 이상의 정수가 아닙니다.", item)
+			}
+			set[n] = struct{}{}
+		}
+		return func(event *utils.Event) bool {
+			_, ok := set[get(event)]
+			return ok
+		}, nil
+	}
+
+	if value.list {
+		return nil, fmt.Errorf("연산자 '%s'에는 목록을 사용할 수 없습니다.", operator)
+	}
+	n, err := strconv.ParseUint(value.text, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("값 '%s'이(가) 0 이상의 정수가 아닙니다.", value.text)
+	}
+	switch operator {
+	case "==":
+		return func(event *utils.Event) bool { return get(event) == n }, nil
+	case ">":
+		return func(event *utils.Event) bool { return get(event) > n }, nil
+	case "<":
+		return func(event *utils.Event) bool { return get(event) < n }, nil
+	case ">=":
+		return func(event *utils.Event) bool { return get(event) >= n }, nil
+	case "<=":
+		return func(event *utils.Event) bool { return get(event) <= n }, nil
+	}
+	return nil, fmt.Errorf("연산자 '%s'는 정수 필드에 사용할 수 없습니다.", operator)
+}
+
+func floatPredicate(get func(event *utils.Event) float64, operator string, value conditionValue) (predicateNode, error) {
+	if value.list {
+		return nil, fmt.Errorf("연산자 '%s'에는 목록을 사용할 수 없습니다.", operator)
+	}
+	n, err := strconv.ParseFloat(value.text, 64)
+	if err != nil {
+		return nil, fmt.Errorf("값 '%s'이(가) 숫자가 아닙니다.", value.text)
+	}
+	switch operator {
+	case "==":
+		return func(event *utils.Event) bool { return get(event) == n }, nil
+	case ">":
+		return func(event *utils.Event) bool { return get(event) > n }, nil
+	case "<":
+		return func(event *utils.Event) bool { return get(event) < n }, nil
+	case ">=":
+		return func(event *utils.Event) bool { return get(event) >= n }, nil
+	case "<=":
+		return func(event *utils.Event) bool { return get(event) <= n }, nil
+	}
+	return nil, fmt.Errorf("연산자 '%s'는 실수 값에 사용할 수 없습니다.", operator)
+}
+
+func stringPredicate(get func(event *utils.Event) string, operator string, value conditionValue) (predicateNode, error) {
+	if value.list && operator != "in" && operator != "cidr" && operator != "!cidr" {
+		return nil, fmt.Errorf("연산자 '%s'에는 목록을 사용할 수 없습니다.", operator)
+	}
+	text := value.text
+
+	var match func(s string) bool
+	switch operator {
+	case "==":
+		match = func(s string) bool { return s == text }
+	case "()":
+		match = func(s string) bool { return strings.Contains(s, text) }
+	case "i==":
+		match = func(s string) bool { return strings.EqualFold(s, text) }
+	case "i()":
+		lower := strings.ToLower(text)
+		match = func(s string) bool { return strings.Contains(strings.ToLower(s), lower) }
+	case "=~":
+		pattern, err := regexp.Compile(text)
+		if err != nil {
+			return nil, fmt.Errorf("정규식 '%s'이(가) 올바르지 않습니다: %v", text, err)
+		}
+		match = pattern.MatchString
+	case "glob":
+		pattern, err := globToRegexp(text)
+		if err != nil {
+			return nil, err
+		}
+		match = pattern.MatchString
+	case "in":
+		set := make(map[string]struct{}, len(value.items))
+		for _, item := range value.items {
+			set[item] = struct{}{}
+		}
+		match = func(s string) bool {
+			_, ok := set[s]
+			return ok
+		}
+	case "cidr", "!cidr":
+		items := value.items
+		if !value.list {
+			items = []string{text}
+		}
+		networks, err := parseNetworks(items)
+		if err != nil {
+			return nil, err
+		}
+		// 빈 값처럼 IP가 아닌 값은 어느 대역의 안도 밖도 아니므로 cidr와 !cidr 모두 거짓이다.
+		inside := operator == "cidr"
+		match = func(s string) bool {
+			ip := net.ParseIP(s)
+			return ip != nil && ipInNetworks(ip, networks) == inside
+		}
+	default:
+		return nil, fmt.Errorf("연산자 '%s'는 문자열 필드에 사용할 수 없습니다.", operator)
+	}
+	return func(event *utils.Event) bool { return match(get(event)) }, nil
+}
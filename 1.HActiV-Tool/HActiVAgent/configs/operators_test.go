@@ -0,0 +1,58 @@
+// Copyright Authors of HActiV
+
+package configs
+
+import (
+	"HActiV/pkg/utils"
+	"testing"
+)
+
+func TestCidrOperators(t *testing.T) {
+	tests := []struct {
+		dstIp  string
+		cidr   bool
+		ncidr  bool
+		single bool // %DstIp% cidr 10.0.0.0/8
+	}{
+		{"10.1.2.3", true, false, true},
+		{"192.168.0.10", true, false, false},
+		{"8.8.8.8", false, true, false},
+		{"2001:db8::1", false, true, false},
+		{"", false, false, false},
+		{"not-an-ip", false, false, false},
+		{"10.1.2.3:443", false, false, false},
+	}
+	conditions := []string{
+		"%DstIp% cidr [10.0.0.0/8, 192.168.0.0/16]",
+		"%DstIp% !cidr [10.0.0.0/8, 192.168.0.0/16]",
+		"%DstIp% cidr 10.0.0.0/8",
+	}
+	var nodes []conditionNode
+	for _, condition := range conditions {
+		node, err := parseCondition(condition, getFieldTypes(), nil)
+		if err != nil {
+			t.Fatalf("parseCondition(%q): %v", condition, err)
+		}
+		nodes = append(nodes, node)
+	}
+	for _, tt := range tests {
+		event := &utils.Event{DstIp: tt.dstIp}
+		for i, want := range []bool{tt.cidr, tt.ncidr, tt.single} {
+			if got := nodes[i].evaluate(event); got != want {
+				t.Errorf("%q with DstIp %q = %t, want %t", conditions[i], tt.dstIp, got, want)
+			}
+		}
+	}
+}
+
+func TestCidrOperatorRejectsInvalidNetworks(t *testing.T) {
+	for _, condition := range []string{
+		"%DstIp% cidr [10.0.0.0/33]",
+		"%DstIp% !cidr [10.0.0.0/8, example.com]",
+		"%Filename% cidr 10.0.0.0/8",
+	} {
+		if _, err := parseCondition(condition, getFieldTypes(), nil); err == nil {
+			t.Errorf("parseCondition(%q) succeeded, want an error", condition)
+		}
+	}
+}
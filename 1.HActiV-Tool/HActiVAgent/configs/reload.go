@@ -0,0 +1,305 @@
+// Copyright Authors of HActiV
+
+// configs package for Setting and detection rules
+package configs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// 모니터가 사용하는 정책 목록. 규칙 파일을 다시 불러오면 목록 전체를 한 번에 바꾸므로
+// 모니터는 이벤트마다 Load로 그 시점의 목록을 받아 쓴다.
+type Policies struct {
+	toolName string
+	current  atomic.Pointer[[]Policy]
+}
+
+func (p *Policies) Load() []Policy {
+	if policies := p.current.Load(); policies != nil {
+		return *policies
+	}
+	return nil
+}
+
+var (
+	reloadMu       sync.Mutex
+	policyRegistry = make(map[string]*Policies)
+	// WatchRuleLocation이 규칙 디렉터리를 감시하고 있으면 파일을 바꾸기만 해도 다시 불러온다.
+	watchingRuleLocation atomic.Bool
+)
+
+// Setting.json의 AllowRemoteResponseActions. false이면 서버에서 받은 규칙에 exec:와 대응 액션을 쓸 수 없다.
+var AllowRemoteResponseActions bool
+
+// 도구의 규칙을 불러오고 다시 불러오기 대상으로 등록한다.
+func RegisterRules(toolName string) (*Policies, error) {
+	policies, err := LoadRules(toolName)
+	if err != nil {
+		return nil, err
+	}
+
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	p := &Policies{toolName: toolName}
+	p.current.Store(&policies)
+	policyRegistry[toolName] = p
+	return p, nil
+}
+
+// 등록된 모든 도구의 규칙과 순서 규칙, allowlist를 다시 불러온다.
+// 규칙 파일을 읽지 못하면 그 도구는 기존 정책을 그대로 쓴다. 유효하지 않은 규칙은 LoadRules와 같이 건너뛴다.
+func ReloadRules() {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	toolNames := make([]string, 0, len(policyRegistry))
+	for toolName := range policyRegistry {
+		toolNames = append(toolNames, toolName)
+	}
+	sort.Strings(toolNames)
+
+	for _, toolName := range toolNames {
+		p := policyRegistry[toolName]
+		policies, err := loadToolPolicies(toolName)
+		if err != nil {
+			fmt.Printf("[규칙 다시 불러오기] %s: 기존 정책을 유지합니다: %v\n", toolName, err)
+			continue
+		}
+		old := p.Load()
+		keepPolicyState(old, policies)
+		p.current.Store(&policies)
+		printPolicyDiff(toolName, old, policies)
+	}
+
+	if err := LoadSequenceRules(); err != nil {
+		fmt.Println("[규칙 다시 불러오기] 순서 규칙: 기존 규칙을 유지합니다:", err)
+	}
+	if err := LoadAllowlist(); err != nil {
+		fmt.Println("[규칙 다시 불러오기] allowlist: 기존 allowlist를 유지합니다:", err)
+	}
+	if err := LoadHooks(); err != nil {
+		fmt.Println("[규칙 다시 불러오기] actions.json: 기존 설정을 유지합니다:", err)
+	}
+}
+
+// 서버가 gRPC 스트림으로 보낸 규칙 파일을 규칙 디렉터리에 쓰고 다시 불러온다.
+// 파일 이름은 규칙 디렉터리에서 읽는 이름만 받으며, 에이전트에서만 관리하는 actions.json과 allowlist.json은 받지 않는다.
+// 하나라도 rules lint에서 문제가 있거나 쓸 수 없는 액션이 있으면 아무 파일도 쓰지 않는다.
+func ApplyRuleUpdate(version string, files map[string][]byte) error {
+	if len(files) == 0 {
+		return fmt.Errorf("규칙 파일이 없습니다")
+	}
+	// 같은 파일시스템에서 rename하도록 규칙 디렉터리 안의 임시 디렉터리에서 검사한다.
+	dir, err := os.MkdirTemp(RuleLocation, ".update-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	names := sortedNames(files)
+	for _, name := range names {
+		if name != filepath.Base(name) || !isRuleLocationFile(name) {
+			return fmt.Errorf("규칙 디렉터리에 쓸 수 없는 파일 이름입니다: %s", name)
+		}
+		if name == hooksFileName || name == allowlistFileName {
+			return fmt.Errorf("%s은(는) 에이전트에서만 바꿀 수 있습니다", name)
+		}
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, files[name], 0644); err != nil {
+			return err
+		}
+		if issues := LintFile(path); len(issues) > 0 {
+			return fmt.Errorf("%s: %s", name, strings.TrimPrefix(issues[0].String(), path+":"))
+		}
+		if err := checkRemoteActions(path); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	for _, name := range names {
+		if err := os.Rename(filepath.Join(dir, name), filepath.Join(RuleLocation, name)); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("[규칙 다시 불러오기] 서버에서 받은 규칙 버전 %s을(를) 적용합니다: %s\n", version, strings.Join(names, ", "))
+	if !watchingRuleLocation.Load() {
+		ReloadRules()
+	}
+	return nil
+}
+
+// 서버에서 받은 규칙 파일에 exec:나 대응 액션이 있으면 오류를 돌려준다. 사용하지 않는 규칙도 검사한다.
+func checkRemoteActions(path string) error {
+	if AllowRemoteResponseActions || filepath.Base(path) == sharedMacrosFileName {
+		return nil
+	}
+	if data, err := os.ReadFile(path); err != nil || len(bytes.TrimSpace(data)) == 0 {
+		return err
+	}
+	type entry struct{ name, action string }
+	var entries []entry
+	if IsSequenceRuleFile(path) {
+		rules, err := readSequenceRuleFile(path)
+		if err != nil {
+			return err
+		}
+		for _, rule := range rules {
+			entries = append(entries, entry{rule.EventName, rule.Action})
+		}
+	} else {
+		rules, _, err := readRuleFile(path)
+		if err != nil {
+			return err
+		}
+		for _, rule := range rules {
+			entries = append(entries, entry{rule.EventName, rule.Action})
+		}
+	}
+	for _, e := range entries {
+		for _, action := range strings.Fields(e.action) {
+			if strings.HasPrefix(action, "exec:") || contains(responseActions, action) {
+				return fmt.Errorf("%s: 서버에서 받은 규칙에는 액션 '%s'을(를) 쓸 수 없습니다 (AllowRemoteResponseActions)", e.name, action)
+			}
+		}
+	}
+	return nil
+}
+
+// 규칙을 정규화한 JSON. 다시 불러올 때 바뀌지 않은 규칙을 알아보는 데 쓴다.
+func ruleFingerprint(rule Rule) string {
+	data, _ := json.Marshal(rule)
+	return string(data)
+}
+
+// 바뀌지 않은 규칙은 집계 중인 건수와 억제 상태를 이어받는다.
+func keepPolicyState(old, policies []Policy) {
+	unused := make(map[string][]*Policy)
+	for i := range old {
+		unused[old[i].fingerprint] = append(unused[old[i].fingerprint], &old[i])
+	}
+	for i := range policies {
+		candidates := unused[policies[i].fingerprint]
+		if len(candidates) == 0 {
+			continue
+		}
+		policies[i].aggregator = candidates[0].aggregator
+		policies[i].suppressor = candidates[0].suppressor
+		unused[policies[i].fingerprint] = candidates[1:]
+	}
+}
+
+// 정책 이름별로 추가, 삭제, 변경된 정책을 출력한다. 같은 이름의 정책이 여러 개이면 묶어서 비교한다.
+func printPolicyDiff(toolName string, old, policies []Policy) {
+	byName := func(policies []Policy) map[string]string {
+		fingerprints := make(map[string][]string)
+		for _, policy := range policies {
+			fingerprints[policy.PolicyName] = append(fingerprints[policy.PolicyName], policy.fingerprint)
+		}
+		joined := make(map[string]string, len(fingerprints))
+		for name, list := range fingerprints {
+			sort.Strings(list)
+			joined[name] = strings.Join(list, "\n")
+		}
+		return joined
+	}
+	before, after := byName(old), byName(policies)
+
+	var lines []string
+	added, removed, changed := 0, 0, 0
+	for name, fingerprint := range after {
+		previous, ok := before[name]
+		switch {
+		case !ok:
+			lines = append(lines, "  + "+name)
+			added++
+		case previous != fingerprint:
+			lines = append(lines, "  ~ "+name)
+			changed++
+		}
+	}
+	for name := range before {
+		if _, ok := after[name]; !ok {
+			lines = append(lines, "  - "+name)
+			removed++
+		}
+	}
+	sort.Slice(lines, func(i, j int) bool { return lines[i][4:] < lines[j][4:] })
+
+	fmt.Printf("[규칙 다시 불러오기] %s: 정책 %d개 (추가 %d, 삭제 %d, 변경 %d)\n", toolName, len(policies), added, removed, changed)
+	for _, line := range lines {
+		fmt.Println(line)
+	}
+}
+
+// 규칙 디렉터리를 inotify로 감시해 규칙 파일, macros.yaml, allowlist.json이 바뀌면 다시 불러온다.
+// 편집기는 파일 하나를 저장할 때도 여러 이벤트를 만들므로 마지막 이벤트 후 잠시 기다렸다가 한 번만 불러온다.
+func WatchRuleLocation() error {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC)
+	if err != nil {
+		return err
+	}
+	mask := uint32(syscall.IN_CLOSE_WRITE | syscall.IN_MOVED_TO | syscall.IN_MOVED_FROM | syscall.IN_DELETE)
+	if _, err := syscall.InotifyAddWatch(fd, RuleLocation, mask); err != nil {
+		syscall.Close(fd)
+		return err
+	}
+	watchingRuleLocation.Store(true)
+
+	changed := make(chan struct{}, 1)
+	go func() {
+		defer syscall.Close(fd)
+		buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+		for {
+			n, err := syscall.Read(fd, buf)
+			if err != nil {
+				if err == syscall.EINTR {
+					continue
+				}
+				fmt.Println("규칙 디렉터리 감시를 중단합니다:", err)
+				return
+			}
+			for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+				event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+				nameBytes := buf[offset+syscall.SizeofInotifyEvent : offset+syscall.SizeofInotifyEvent+int(event.Len)]
+				name := strings.TrimRight(string(nameBytes), "\x00")
+				offset += syscall.SizeofInotifyEvent + int(event.Len)
+
+				if isRuleLocationFile(name) {
+					select {
+					case changed <- struct{}{}:
+					default:
+					}
+				}
+			}
+		}
+	}()
+
+	go func() {
+		const settle = 500 * time.Millisecond
+		for range changed {
+			timer := time.NewTimer(settle)
+		wait:
+			for {
+				select {
+				case <-changed:
+					timer.Reset(settle)
+				case <-timer.C:
+					break wait
+				}
+			}
+			fmt.Println("[규칙 다시 불러오기] 규칙 디렉터리의 변경을 감지했습니다.")
+			ReloadRules()
+		}
+	}()
+	return nil
+}
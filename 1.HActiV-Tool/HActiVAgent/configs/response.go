@@ -0,0 +1,270 @@
+// Copyright Authors of HActiV
+
+// configs package for Setting and detection rules
+package configs
+
+import (
+	"HActiV/pkg/docker"
+	"HActiV/pkg/utils"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// 대응 액션. 규칙이 일치한 이벤트의 프로세스를 종료하거나 컨테이너를 일시 중지, 중지한다.
+// dedup으로 알림을 억제하는 동안에도 이벤트마다 실행하며, 실행 여부와 결과는 모두 감사 로그에 남긴다.
+var responseActions = []string{"kill", "pause_container", "stop_container"}
+
+var (
+	// Setting.json의 ResponseDryRun. true이면 대응 액션을 실행하지 않고 감사 로그에만 남긴다.
+	ResponseDryRun bool
+	// Setting.json의 ProtectedContainers. 이 컨테이너 이름 glob과 일치하는 컨테이너에는 대응 액션을 실행하지 않는다.
+	ProtectedContainers []string
+	// ProtectedContainers를 설정을 읽을 때 한 번 컴파일한 것
+	protectedPatterns []*regexp.Regexp
+)
+
+const (
+	responseAuditFileName = "response_audit.log"
+	stopContainerTimeout  = 10 * time.Second
+	// 이벤트 시각은 초 단위이므로 이만큼 늦게 시작한 프로세스까지는 이벤트의 프로세스로 본다.
+	killStartSlack = 2 * time.Second
+	// 커널의 프로세스 이름(comm) 최대 길이
+	commLen = 15
+)
+
+// 감사 로그 한 줄
+type responseAudit struct {
+	Time      string `json:"time"`
+	EventTime string `json:"event_time"`
+	Policy    string `json:"policy"`
+	Action    string `json:"action"`
+	Container string `json:"container"`
+	Pid       uint32 `json:"pid,omitempty"`
+	Process   string `json:"process,omitempty"`
+	DryRun    bool   `json:"dry_run"`
+	Result    string `json:"result"`
+	Attempts  int    `json:"attempts,omitempty"`
+	Output    string `json:"output,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+var (
+	auditMu sync.Mutex
+	// 같은 컨테이너에 진행 중인 pause_container, stop_container는 한 번만 실행한다.
+	pendingMu        sync.Mutex
+	pendingResponses = make(map[string]bool)
+)
+
+// "hactiv*,HActiV*" 형식의 설정 값을 glob 목록과 컴파일한 패턴으로 바꾼다. 올바르지 않은 glob은 알리고 건너뛴다.
+func parseProtectedContainers(value string) ([]string, []*regexp.Regexp) {
+	var globs []string
+	var patterns []*regexp.Regexp
+	for _, glob := range strings.Split(value, ",") {
+		if glob = strings.TrimSpace(glob); glob == "" {
+			continue
+		}
+		re, err := globToRegexp(glob)
+		if err != nil {
+			fmt.Printf("ProtectedContainers '%s'이(가) 올바르지 않아 사용하지 않습니다: %v\n", glob, err)
+			continue
+		}
+		globs = append(globs, glob)
+		patterns = append(patterns, re)
+	}
+	return globs, patterns
+}
+
+func protectedContainer(name string) bool {
+	for _, re := range protectedPatterns {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// 정책의 대응 액션을 모두 실행한다.
+func runResponses(policy *Policy, event *utils.Event) {
+	for _, action := range strings.Fields(policy.Action) {
+		if contains(responseActions, action) {
+			respondAction(policy, event, action)
+		}
+	}
+}
+
+// 대응 액션 하나를 실행한다. 보호 대상이면 건너뛰고, 컨테이너 액션은 모니터를 막지 않도록 따로 실행한다.
+func respondAction(policy *Policy, event *utils.Event, action string) {
+	audit := responseAudit{
+		EventTime: event.Time,
+		Policy:    policy.PolicyName,
+		Action:    action,
+		Container: event.ContainerName,
+		DryRun:    ResponseDryRun,
+	}
+	if action == "kill" {
+		audit.Pid = event.Pid
+		audit.Process = event.ProcessName
+	}
+
+	if reason := responseRefusal(event, action); reason != "" {
+		audit.Result = "skipped"
+		audit.Error = reason
+		writeResponseAudit(audit)
+		return
+	}
+	if ResponseDryRun {
+		audit.Result = "dry-run"
+		writeResponseAudit(audit)
+		return
+	}
+
+	if action == "kill" {
+		reason, err := killEventProcess(event)
+		if reason != "" {
+			audit.Result = "skipped"
+			audit.Error = reason
+			writeResponseAudit(audit)
+			return
+		}
+		finishResponse(&audit, err)
+		return
+	}
+
+	key := action + "/" + event.ContainerName
+	pendingMu.Lock()
+	if pendingResponses[key] {
+		pendingMu.Unlock()
+		return
+	}
+	pendingResponses[key] = true
+	pendingMu.Unlock()
+
+	go func() {
+		defer func() {
+			pendingMu.Lock()
+			delete(pendingResponses, key)
+			pendingMu.Unlock()
+		}()
+		var err error
+		if action == "pause_container" {
+			err = docker.PauseContainer(event.ContainerName)
+		} else {
+			err = docker.StopContainer(event.ContainerName, stopContainerTimeout)
+		}
+		finishResponse(&audit, err)
+	}()
+}
+
+// 이벤트의 프로세스에 SIGKILL을 보낸다. pidfd로 PID를 먼저 잡은 뒤 그 PID가 아직 이벤트의 프로세스인지 확인하므로,
+// 프로세스가 끝나고 PID가 다른 프로세스에 재사용되었으면 종료하지 않고 그 이유를 돌려준다.
+func killEventProcess(event *utils.Event) (string, error) {
+	pidfd, err := unix.PidfdOpen(int(event.Pid), 0)
+	switch {
+	case err == unix.ESRCH:
+		return fmt.Sprintf("PID %d이(가) 이미 종료되었습니다.", event.Pid), nil
+	case err == unix.ENOSYS:
+		pidfd = -1 // pidfd가 없는 커널(5.3 이전)
+	case err != nil:
+		return "", err
+	default:
+		defer unix.Close(pidfd)
+	}
+	if reason := killTargetMismatch(event); reason != "" {
+		return reason, nil
+	}
+	if pidfd < 0 {
+		return "", syscall.Kill(int(event.Pid), syscall.SIGKILL)
+	}
+	return "", unix.PidfdSendSignal(pidfd, unix.SIGKILL, nil, 0)
+}
+
+// PID의 현재 프로세스가 이벤트의 프로세스와 다르면 그 이유를 돌려준다. execve 이벤트는 실행하기 전에 기록하므로
+// 실행한 파일의 이름도 같은 프로세스로 본다. 이벤트 시각보다 늦게 시작한 프로세스는 PID를 재사용한 것이다.
+func killTargetMismatch(event *utils.Event) string {
+	stat, err := utils.ReadProcStat(event.Pid)
+	if err != nil {
+		return fmt.Sprintf("PID %d이(가) 이미 종료되었습니다.", event.Pid)
+	}
+	names := []string{event.ProcessName}
+	if event.Tool == "Systemcall" && event.Filename != "" {
+		name := filepath.Base(event.Filename)
+		names = append(names, name[:min(len(name), commLen)])
+	}
+	if !contains(names, stat.Comm) {
+		return fmt.Sprintf("PID %d의 프로세스 '%s'이(가) 이벤트의 '%s'와 달라 종료하지 않습니다 (PID 재사용).", event.Pid, stat.Comm, event.ProcessName)
+	}
+	if eventTime, err := time.Parse(time.RFC3339, event.Time); err == nil && stat.Start.After(eventTime.Add(killStartSlack)) {
+		return fmt.Sprintf("PID %d은(는) 이벤트 뒤(%s)에 시작한 프로세스라 종료하지 않습니다 (PID 재사용).", event.Pid, stat.Start.Format(time.RFC3339))
+	}
+	return ""
+}
+
+// 실행하면 안 되는 대응 액션이면 그 이유를 돌려준다.
+func responseRefusal(event *utils.Event, action string) string {
+	switch {
+	case protectedContainer(event.ContainerName):
+		return "보호 대상 컨테이너입니다."
+	case action == "kill" && event.Pid <= 1:
+		return fmt.Sprintf("PID %d은(는) 종료할 수 없습니다.", event.Pid)
+	case action == "kill" && int(event.Pid) == os.Getpid():
+		return "HActiV 에이전트 자신은 종료할 수 없습니다."
+	case action != "kill" && (event.ContainerName == "" || event.ContainerName == "H"):
+		return "호스트는 일시 중지하거나 중지할 수 없습니다."
+	}
+	return ""
+}
+
+func finishResponse(audit *responseAudit, err error) {
+	audit.Result = "done"
+	if err != nil {
+		audit.Result = "failed"
+		audit.Error = err.Error()
+	}
+	writeResponseAudit(*audit)
+}
+
+// 감사 기록을 출력하고 로그 디렉터리의 response_audit.log에 JSON 한 줄로 덧붙인다.
+func writeResponseAudit(audit responseAudit) {
+	audit.Time = time.Now().Format(time.RFC3339)
+	target := audit.Container
+	if audit.Pid != 0 {
+		target += fmt.Sprintf(" PID %d", audit.Pid)
+	}
+	if audit.Process != "" {
+		target += "(" + audit.Process + ")"
+	}
+	line := fmt.Sprintf("[대응] %s: %s %s -> %s", audit.Policy, audit.Action, target, audit.Result)
+	if audit.Output != "" {
+		line += " " + audit.Output
+	}
+	if audit.Attempts > 1 {
+		line += fmt.Sprintf(" (%d회 시도)", audit.Attempts)
+	}
+	if audit.Error != "" {
+		line += ": " + audit.Error
+	}
+	fmt.Println(line)
+
+	data, err := json.Marshal(audit)
+	if err != nil {
+		return
+	}
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	file, err := os.OpenFile(utils.LogLocation+responseAuditFileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		fmt.Println("대응 감사 로그를 쓰지 못했습니다:", err)
+		return
+	}
+	defer file.Close()
+	file.Write(append(data, '\n'))
+}
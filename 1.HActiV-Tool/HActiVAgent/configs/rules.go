@@ -8,15 +8,19 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"reflect"
 	"strconv"
 	"strings"
 	"time"
 )
 
+// 규칙을 평가할 시간. day(또는 days)의 time_ranges 동안, dates 기간 안에서 평가하며 지정하지 않은 항목은 검사하지 않는다.
+// exclude이면 그 시간에는 평가하지 않는다. 자세한 내용은 timeconditions.go를 참고한다.
 type TimeCondition struct {
-	Day        string      `json:"day"`
-	TimeRanges []TimeRange `json:"time_ranges"`
+	Day        string      `json:"day,omitempty"`
+	Days       []string    `json:"days,omitempty"`
+	TimeRanges []TimeRange `json:"time_ranges,omitempty"`
+	Dates      []DateRange `json:"dates,omitempty"`
+	Exclude    bool        `json:"exclude,omitempty"`
 }
 
 type TimeRange struct {
@@ -24,168 +28,333 @@ type TimeRange struct {
 	End   string `json:"end"`
 }
 
+// 절대 기간. "2006-01-02" 또는 "2006-01-02 15:04" 형식이며 날짜만 쓴 end는 그날 끝까지이다.
+type DateRange struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
 type Policy struct {
 	PolicyName     string          `json:"policy_name"`
+	Description    string          `json:"description"`
+	Severity       string          `json:"severity,omitempty"`
+	Tags           []string        `json:"tags,omitempty"`
+	Mitre          *Mitre          `json:"mitre,omitempty"`
 	Condition      string          `json:"condition"`
 	Action         string          `json:"action"`
 	PrintFormat    string          `json:"print_format"`
 	TimeConditions []TimeCondition `json:"time_conditions"`
+	Timezone       string          `json:"timezone,omitempty"`
+	Aggregation    *Aggregation    `json:"aggregation,omitempty"`
+	Dedup          *Dedup          `json:"dedup,omitempty"`
+	Exceptions     *Exceptions     `json:"exceptions,omitempty"`
+	Scope          *Scope          `json:"scope,omitempty"`
+	expr           conditionNode
+	format         printFormat
+	aggregator     *aggregator
+	suppressor     *suppressor
+	exceptions     *exceptionSet
+	schedule       *timeSchedule
+	scope          *scopeMatcher
+	fingerprint    string
 }
 
 type Rule struct {
 	EventName      string          `json:"event_name"`
 	Description    string          `json:"description"`
 	Usage          bool            `json:"usage"`
+	Severity       string          `json:"severity,omitempty"`
+	Tags           []string        `json:"tags,omitempty"`
+	Mitre          *Mitre          `json:"mitre,omitempty"`
 	Condition      string          `json:"condition"`
 	Action         string          `json:"action"`
 	PrintFormat    string          `json:"print_format"`
 	TimeConditions []TimeCondition `json:"time_conditions"`
+	Timezone       string          `json:"timezone,omitempty"`
+	Aggregation    *Aggregation    `json:"aggregation,omitempty"`
+	Dedup          *Dedup          `json:"dedup,omitempty"`
+	Exceptions     *Exceptions     `json:"exceptions,omitempty"`
+	Scope          *Scope          `json:"scope,omitempty"`
 }
 
+// 도구의 JSON 규칙 파일(<도구>rule.json)과 YAML 규칙 파일(<도구>rule.yaml)을 불러온다.
+// 둘 중 하나만 있어도 되며 둘 다 있으면 JSON 규칙 다음에 YAML 규칙을 붙인다.
 func LoadRules(toolName string) ([]Policy, error) {
-	filename := RuleLocation + toolName + "rule.json"
-	if _, err := os.Stat(filename); os.IsNotExist(err) {
-		return nil, fmt.Errorf("규칙 파일이 존재하지 않습니다: %s", filename)
-	}
-
-	data, err := os.ReadFile(filename)
-	if err != nil {
-		return nil, err
-	}
-	var rules []Rule
-	err = json.Unmarshal(data, &rules)
+	policies, err := loadToolPolicies(toolName)
 	if err != nil {
 		return nil, err
 	}
-	fieldTypes, err := getFieldTypes()
-	if err != nil {
-		return nil, err
+
+	for _, policy := range policies {
+		fmt.Printf("정책: %s\n", policy.PolicyName)
+		fmt.Printf("  설명: %s\n", policy.Description)
+		if policy.Severity != "" || len(policy.Tags) > 0 || policy.Mitre != nil {
+			fmt.Printf("  분류: %s\n", policy.classification())
+		}
+		fmt.Printf("  조건: %s\n", policy.Condition)
+		fmt.Printf("  액션: %s\n", policy.Action)
+		fmt.Printf("  출력: %s\n", policy.PrintFormat)
+		fmt.Printf("  시간: %s\n", describeTimeConditions(policy.TimeConditions, policy.Timezone))
+		if policy.Aggregation != nil {
+			fmt.Printf("  집계: %d건 초과 / %s / %s\n", policy.Aggregation.Count, policy.Aggregation.Window, strings.Join(policy.Aggregation.GroupBy, ", "))
+		}
+		if policy.Exceptions != nil {
+			fmt.Printf("  예외: %s\n", describeExceptions(policy.Exceptions))
+		}
+		if policy.Scope != nil {
+			fmt.Printf("  범위: %s\n", describeScope(policy.Scope))
+		}
+		if policy.Dedup != nil {
+			fmt.Printf("  중복 억제: %s / %s\n", policy.Dedup.Window, strings.Join(policy.Dedup.Fields, ", "))
+		}
 	}
+	return policies, nil
+}
+
+// 도구의 규칙 파일을 모두 읽어 컴파일한다. 규칙 파일이 하나도 없거나 읽지 못하면 오류를 돌려준다.
+// 유효하지 않은 규칙은 건너뛰기만 하고 규칙 파일은 수정하지 않는다. 미리 확인하려면 rules lint를 사용한다.
+func loadToolPolicies(toolName string) ([]Policy, error) {
+	filenames := []string{RuleLocation + toolName + "rule.json", RuleLocation + toolName + "rule.yaml"}
 
 	var policies []Policy
-	for i, rule := range rules {
-		if rule.Usage {
-			rule.Condition = strings.TrimSpace(rule.Condition)
-			rule.Action = strings.TrimSpace(rule.Action)
-			conditionCheck, conditionErrMsg := evaluateCondition(rule.Condition, fieldTypes)
-			timeCheck, timeErrMsg := evaluateTime(rule.TimeConditions)
-			if conditionCheck && timeCheck {
-				fmt.Printf("정책: %s\n", rule.EventName)
-				fmt.Printf("  설명: %s\n", rule.Description)
-				fmt.Printf("  조건: %s\n", rule.Condition)
-				fmt.Printf("  액션: %s\n", rule.Action)
-				fmt.Printf("  출력: %s\n", rule.PrintFormat)
-				fmt.Printf("  시간: %s\n", rule.TimeConditions)
-
-				policies = append(policies, Policy{
-					PolicyName:     rule.EventName,
-					Condition:      rule.Condition,
-					Action:         rule.Action,
-					PrintFormat:    rule.PrintFormat,
-					TimeConditions: rule.TimeConditions,
-				})
-			} else {
-				fmt.Printf("정책 '%s'의 조건이 유효하지 않습니다: %s %s\n", rule.EventName, conditionErrMsg, timeErrMsg)
-
-				rules[i].Usage = false
+	found := false
+	for _, filename := range filenames {
+		if _, err := os.Stat(filename); os.IsNotExist(err) {
+			continue
+		}
+		found = true
+		rules, env, err := readRuleFile(filename)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", filename, err)
+		}
+		compiled, ruleErrs := compileRules(rules, env)
+		for i := range rules {
+			if errMsg, ok := ruleErrs[i]; ok {
+				fmt.Printf("정책 '%s'이(가) 유효하지 않아 사용하지 않습니다: %s\n", rules[i].EventName, errMsg)
 			}
 		}
+		policies = append(policies, compiled...)
+	}
+	if !found {
+		return nil, fmt.Errorf("규칙 파일이 존재하지 않습니다: %s", filenames[0])
+	}
+	return policies, nil
+}
+
+// 규칙 파일을 읽는다. YAML 규칙 파일이면 조건에서 참조할 lists와 macros도 함께 돌려준다.
+func readRuleFile(filename string) ([]Rule, *conditionEnv, error) {
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		return nil, nil, fmt.Errorf("규칙 파일이 존재하지 않습니다: %s", filename)
+	}
+	if isYAMLRuleFile(filename) {
+		return readYAMLRuleFile(filename)
 	}
 
-	updatedData, err := json.MarshalIndent(rules, "", "  ")
+	data, err := os.ReadFile(filename)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	err = os.WriteFile(filename, updatedData, 0644)
+	var rules []Rule
+	err = json.Unmarshal(data, &rules)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	return policies, nil
+	return rules, nil, nil
 }
 
-func getFieldTypes() (map[string]string, error) {
-	var event utils.Event
-	fieldTypes := make(map[string]string)
+// 규칙에서 사용할 수 있는 액션. 이 밖에 exec:<스크립트>, webhook:<이름>을 쓸 수 있다(hooks.go).
+var knownActions = append([]string{"print", "alert", "ignore", "snapshot"}, responseActions...)
 
-	v := reflect.TypeOf(event)
-	for i := 0; i < v.NumField(); i++ {
-		field := v.Field(i)
-		fieldName := field.Name
-		fieldType := field.Type.Kind().String()
-		fieldTypes[fieldName] = fieldType
+// 규칙 항목별 오류. key는 오류가 난 규칙의 JSON 키이며 rules lint에서 줄 번호를 찾는 데 쓴다.
+type ruleError struct {
+	key string
+	msg string
+}
+
+func joinRuleErrors(errs []ruleError) string {
+	msgs := make([]string, len(errs))
+	for i, e := range errs {
+		msgs[i] = e.msg
 	}
-	return fieldTypes, nil
+	return strings.Join(msgs, " ")
 }
 
-func evaluateCondition(condition string, fieldTypes map[string]string) (bool, string) {
-	parts := strings.Split(condition, " and ")
-	for _, part := range parts {
-		part = strings.TrimSpace(part)
-		tokens := strings.Fields(part)
-		if len(tokens) != 3 {
-			return false, fmt.Sprintf("조건 '%s'의 인자 개수가 올바르지 않습니다.", part)
+// 사용 중인 규칙을 정책으로 컴파일한다. 컴파일에 실패한 규칙은 인덱스별 오류 메시지로 돌려준다.
+// env는 YAML 규칙 파일의 lists와 macros이며 JSON 규칙 파일은 nil이다.
+func compileRules(rules []Rule, env *conditionEnv) ([]Policy, map[int]string) {
+	fieldTypes := getFieldTypes()
+	ruleErrs := make(map[int]string)
+
+	var policies []Policy
+	for i, rule := range rules {
+		if !rule.Usage {
+			continue
+		}
+		policy, errs := compileRule(rule, fieldTypes, env)
+		if len(errs) > 0 {
+			ruleErrs[i] = joinRuleErrors(errs)
+			continue
 		}
+		policy.fingerprint = ruleFingerprint(rule)
+		policies = append(policies, policy)
+	}
+	return policies, ruleErrs
+}
 
-		fieldNameWithPercent := tokens[0]
-		operator := tokens[1]
-		value := tokens[2]
+// 규칙 하나를 정책으로 컴파일한다. 오류가 있으면 항목별 오류를 모두 돌려준다.
+func compileRule(rule Rule, fieldTypes map[string]string, env *conditionEnv) (Policy, []ruleError) {
+	var errs []ruleError
+	fail := func(key string, err error) {
+		errs = append(errs, ruleError{key: key, msg: err.Error()})
+	}
 
-		validOperators := []string{"==", "!=", ">", "<", ">=", "<=", "()"}
-		if !contains(validOperators, operator) {
-			return false, fmt.Sprintf("%s 조건에서 연산자 '%s'가 올바르지 않습니다.", part, operator)
+	rule.Condition = strings.TrimSpace(rule.Condition)
+	rule.Action = strings.TrimSpace(rule.Action)
+	expr, err := parseCondition(rule.Condition, fieldTypes, env)
+	if err != nil {
+		msg := err.Error()
+		if posErr, ok := err.(*conditionError); ok {
+			msg += "\n" + posErr.marker(rule.Condition)
 		}
-
-		fieldName := strings.Trim(fieldNameWithPercent, "%")
-		fieldType, ok := fieldTypes[fieldName]
-		if !ok {
-			return false, fmt.Sprintf("%s 조건에서 필드명 '%s'이(가) 올바르지 않습니다.", part, fieldName)
+		errs = append(errs, ruleError{key: "condition", msg: msg})
+	}
+	for _, action := range strings.Fields(rule.Action) {
+		if err := checkAction(action); err != nil {
+			fail("action", err)
 		}
-
-		switch fieldType {
-		case "uint32", "int", "uint":
-			if operator == "==" || operator == "!=" || operator == ">" || operator == "<" || operator == ">=" || operator == "<=" {
-				if _, err := strconv.Atoi(value); err != nil {
-					return false, fmt.Sprintf("%s 조건에서 필드 '%s'는 정수형이어야 하는데 값 '%s'이(가) 정수가 아닙니다.", part, fieldName, value)
-				}
-			} else {
-				return false, fmt.Sprintf("%s 조건에서 필드 '%s'는 정수형인데 연산자 '%s'는 사용할 수 없습니다.", part, fieldName, operator)
-			}
-		case "string":
-			if operator != "==" && operator != "!=" && operator != "()" {
-				return false, fmt.Sprintf("%s 조건에서 필드 '%s'는 문자열인데 연산자 '%s'는 사용할 수 없습니다.", part, fieldName, operator)
-			}
+	}
+	loc, err := loadTimezone(rule.Timezone)
+	if err != nil {
+		fail("timezone", err)
+	}
+	schedule, err := compileTimeConditions(rule.TimeConditions, loc)
+	if err != nil {
+		fail("time_conditions", err)
+	}
+	if err := validateRuleMeta(rule.Severity, rule.Mitre); err != nil {
+		key := "mitre"
+		if rule.Severity != "" && !contains(severities, strings.ToLower(rule.Severity)) {
+			key = "severity"
 		}
+		fail(key, err)
 	}
-	return true, ""
+	var agg *aggregator
+	if rule.Aggregation != nil {
+		if agg, err = newAggregator(rule.Aggregation); err != nil {
+			fail("aggregation", err)
+		} else if hasAction(rule.Action, "ignore") {
+			fail("aggregation", fmt.Errorf("집계 규칙에는 ignore 액션을 사용할 수 없습니다."))
+		}
+	}
+	var sup *suppressor
+	if rule.Dedup != nil {
+		if sup, err = newSuppressor(rule.Dedup); err != nil {
+			fail("dedup", err)
+		}
+	}
+	exceptions, err := compileExceptions(rule.Exceptions)
+	if err != nil {
+		fail("exceptions", err)
+	}
+	scope, err := compileScope(rule.Scope)
+	if err != nil {
+		fail("scope", err)
+	}
+	if len(errs) > 0 {
+		return Policy{}, errs
+	}
+
+	return Policy{
+		PolicyName:     rule.EventName,
+		Description:    rule.Description,
+		Severity:       strings.ToLower(rule.Severity),
+		Tags:           rule.Tags,
+		Mitre:          rule.Mitre,
+		Condition:      rule.Condition,
+		Action:         rule.Action,
+		PrintFormat:    rule.PrintFormat,
+		TimeConditions: rule.TimeConditions,
+		Timezone:       rule.Timezone,
+		Aggregation:    rule.Aggregation,
+		Dedup:          rule.Dedup,
+		Exceptions:     rule.Exceptions,
+		Scope:          rule.Scope,
+		expr:           expr,
+		format:         compilePrintFormat(rule.PrintFormat),
+		aggregator:     agg,
+		suppressor:     sup,
+		exceptions:     exceptions,
+		schedule:       schedule,
+		scope:          scope,
+	}, nil
 }
 
-func evaluateTime(timeCondition []TimeCondition) (bool, string) {
-	weekdays := map[string]bool{
-		"Monday": true, "Tuesday": true, "Wednesday": true,
-		"Thursday": true, "Friday": true, "Saturday": true, "Sunday": true,
+func getFieldTypes() map[string]string {
+	fieldTypes := make(map[string]string, len(eventFields))
+	for fieldName, field := range eventFields {
+		fieldTypes[fieldName] = field.kind
 	}
-	for _, timeCondition := range timeCondition {
-		if !weekdays[timeCondition.Day] {
-			return false, fmt.Sprintf("%s란 요일은 없습니다.", timeCondition.Day)
-		}
+	return fieldTypes
+}
 
-		for _, timeRange := range timeCondition.TimeRanges {
-			layout := "15:04"
+func evaluateCondition(fieldName, operator string, value conditionValue, fieldTypes map[string]string) (bool, string) {
+	part := fmt.Sprintf("%%%s%% %s %s", fieldName, operator, value.text)
 
-			s, strErr := time.Parse(layout, timeRange.Start)
-			if strErr != nil {
-				return false, fmt.Sprintf("%s에서 필드 '%s'의 시간 형식이 올바르지 않습니다. 예시: 00:00", timeCondition.Day, timeRange.Start)
-			}
+	if !contains(ipOperators, operator) && !contains(numberOperators, operator) {
+		return false, fmt.Sprintf("%s 조건에서 연산자 '%s'가 올바르지 않습니다.", part, operator)
+	}
 
-			e, endErr := time.Parse(layout, timeRange.End)
-			if endErr != nil && timeRange.End != "24:00" {
-				return false, fmt.Sprintf("%s에서 필드 '%s'의 시간 형식이 올바르지 않습니다. 예시: 00:00", timeCondition.Day, timeRange.End)
-			}
+	fieldType, ok := fieldTypes[fieldName]
+	if !ok {
+		return false, fmt.Sprintf("%s 조건에서 필드명 '%s'이(가) 올바르지 않습니다.", part, fieldName)
+	}
+	return evaluateOperand(part, fieldName, fieldType, operator, value)
+}
 
-			if timeRange.End != "24:00" && !s.Before(e) {
-				return false, fmt.Sprintf("%s에서 시작 시간(%s)은 종료 시간(%s) 이후가 될 수 없습니다.", timeCondition.Day, timeRange.Start, timeRange.End)
+// 필드(또는 함수 호출) 값의 형식에 연산자와 값을 쓸 수 있는지 확인한다.
+func evaluateOperand(part, fieldName, fieldType, operator string, value conditionValue) (bool, string) {
+	if !contains(ipOperators, operator) && !contains(numberOperators, operator) {
+		return false, fmt.Sprintf("%s 조건에서 연산자 '%s'가 올바르지 않습니다.", part, operator)
+	}
+
+	switch {
+	case contains(integerTypes, fieldType):
+		if !contains(numberOperators, operator) {
+			return false, fmt.Sprintf("%s 조건에서 필드 '%s'는 정수형인데 연산자 '%s'는 사용할 수 없습니다.", part, fieldName, operator)
+		}
+		values := []string{value.text}
+		if value.list {
+			values = value.items
+		}
+		for _, v := range values {
+			if fieldType == "uint64" {
+				if _, err := strconv.ParseUint(v, 10, 64); err != nil {
+					return false, fmt.Sprintf("%s 조건에서 필드 '%s'는 부호 없는 정수형이어야 하는데 값 '%s'이(가) 0 이상의 정수가 아닙니다.", part, fieldName, v)
+				}
+			} else if _, err := strconv.Atoi(v); err != nil {
+				return false, fmt.Sprintf("%s 조건에서 필드 '%s'는 정수형이어야 하는데 값 '%s'이(가) 정수가 아닙니다.", part, fieldName, v)
+			}
+		}
+	case fieldType == "float":
+		if !contains(numberOperators, operator) || value.list {
+			return false, fmt.Sprintf("%s 조건에서 필드 '%s'는 실수형인데 연산자 '%s'는 사용할 수 없습니다.", part, fieldName, operator)
+		}
+		if _, err := strconv.ParseFloat(value.text, 64); err != nil {
+			return false, fmt.Sprintf("%s 조건에서 필드 '%s'는 실수형이어야 하는데 값 '%s'이(가) 숫자가 아닙니다.", part, fieldName, value.text)
+		}
+	case fieldType == "string":
+		if !contains(stringOperators, operator) {
+			if contains(ipOperators, operator) {
+				return false, fmt.Sprintf("%s 조건에서 필드 '%s'는 IP 필드가 아니므로 연산자 '%s'는 사용할 수 없습니다.", part, fieldName, operator)
 			}
+			return false, fmt.Sprintf("%s 조건에서 필드 '%s'는 문자열인데 연산자 '%s'는 사용할 수 없습니다.", part, fieldName, operator)
 		}
+	case fieldType == "ip":
+		if !contains(ipOperators, operator) {
+			return false, fmt.Sprintf("%s 조건에서 필드 '%s'는 IP 필드인데 연산자 '%s'는 사용할 수 없습니다.", part, fieldName, operator)
+		}
+	default:
+		return false, fmt.Sprintf("%s 조건에서 필드 '%s'는 조건에 사용할 수 없습니다.", part, fieldName)
 	}
 	return true, ""
 }
@@ -199,147 +368,103 @@ func contains(slice []string, item string) bool {
 	return false
 }
 
-func MatchedEvent(policies []Policy, event utils.Event) {
-policyLoop:
-	for _, policy := range policies {
-		if checkPolicyTimeConditions(policy.TimeConditions, event.Time) {
-			if parseAndEvaluateCondition(policy.Condition, event) {
-				for _, action := range strings.Fields(policy.Action) {
-					switch strings.TrimSpace(action) {
-					case "ignore":
-						break policyLoop
-					case "print":
-						fmt.Println(replaceAllPlaceholders(policy.PrintFormat, event))
-					case "alert":
-						switch event.Tool {
-						case "Systemcall":
-							{
-								utils.RuleSend(policy.PolicyName, replaceAllPlaceholders(policy.PrintFormat, event), "Systemcall", event.Time, event.ContainerName, event.Uid, event.Gid, event.Pid, event.Ppid, event.Filename, event.ProcessName, strings.Replace(event.Args, "--color=auto", "", 1))
-							}
-						case "file_open":
-							{
-								utils.RuleSend(policy.PolicyName, replaceAllPlaceholders(policy.PrintFormat, event), "file_open", event.Time, event.ContainerName, event.Uid, event.Gid, event.Pid, event.Ppid, "open", event.Filename, event.ReturnValue, event.ProcessName)
-							}
-						case "delete":
-							{
-								utils.RuleSend(policy.PolicyName, replaceAllPlaceholders(policy.PrintFormat, event), "delete", event.Time, event.ContainerName, event.Uid, event.Gid, event.Pid, event.Ppid, event.ProcessName, event.Filename)
-							}
-						case "Memory":
-							{
-								utils.RuleSend(policy.PolicyName, replaceAllPlaceholders(policy.PrintFormat, event), "Memory", event.Time, event.ContainerName, event.Uid, event.Gid, event.Pid, event.Ppid, event.ProcessName, event.Syscall, event.StartAddr, event.EndAddr, event.Size, event.Prottemp, event.Prot, event.MappingType)
-							}
-						case "Network_traffic":
-							{
-								utils.RuleSend(policy.PolicyName, replaceAllPlaceholders(policy.PrintFormat, event), "Network_traffic", event.Time, event.ContainerName, event.SrcIp, event.SrcIpLabel, event.DstIp, event.DstIpLabel, event.ProcessName, event.PacketSize, event.PacketCount, event.TotalSize, event.PathJson, event.Direction, event.Method, event.Host, event.URL, event.Parameters)
-							}
-
-						}
-					}
-				}
-			}
+// 이벤트와 일치하는 정책을 순서대로 돌려준다. ignore 액션이 있는 정책은 예외처럼 규칙 파일 안의 위치와 관계없이 적용되어,
+// 일치하는 ignore 정책이 있으면 그 정책들만 돌려주고 같은 도구의 다른 정책은 실행하지 않는다.
+func matchPolicies(policies []Policy, event *utils.Event) []*Policy {
+	var matched, ignoring []*Policy
+	for i := range policies {
+		policy := &policies[i]
+		if !policy.scope.match(event) || !policy.schedule.match(event.Time) ||
+			!policy.expr.evaluate(event) || policy.exceptions.match(event) {
+			continue
 		}
+		if hasAction(policy.Action, "ignore") {
+			ignoring = append(ignoring, policy)
+			continue
+		}
+		matched = append(matched, policy)
 	}
+	if len(ignoring) > 0 {
+		return ignoring
+	}
+	return matched
 }
 
-func parseAndEvaluateCondition(condition string, event utils.Event) bool {
-	condition = replaceAllPlaceholders(condition, event)
-	parts := strings.Split(condition, " and ")
-	for _, part := range parts {
-		if !evaluateSingleCondition(part) {
-			return false
+func hasAction(actions, name string) bool {
+	for _, action := range strings.Fields(actions) {
+		if action == name {
+			return true
 		}
 	}
-	return true
+	return false
+}
+
+// 액션을 실행할 정책과 출력 메시지
+type trigger struct {
+	policy  *Policy
+	message string
 }
 
-func evaluateSingleCondition(condition string) bool {
-	parts := strings.Split(condition, " ")
-	if len(parts) > 3 {
-		for i, v := range parts {
-			if v == "==" || v == "!=" || v == "()" || v == "!()" {
-				left := strings.Join(parts[:i], " ")
-				operator := parts[i]
-				right := strings.Join(parts[i+1:], " ")
-				return compareString(left, operator, strings.Trim(right, "\""))
+// 이벤트로 액션을 실행할 정책을 순서대로 돌려준다. 집계 규칙은 임계치를 넘었을 때만 요약 문구를 붙여 포함한다.
+func triggeredPolicies(policies []Policy, event *utils.Event, now time.Time) []trigger {
+	var triggers []trigger
+	for _, policy := range matchPolicies(policies, event) {
+		summary := ""
+		if policy.aggregator != nil {
+			var fire bool
+			if summary, fire = policy.aggregator.add(event, now); !fire {
+				continue
 			}
 		}
+		message := policy.format.render(event)
+		if summary != "" {
+			message += " | " + summary
+		}
+		triggers = append(triggers, trigger{policy: policy, message: message})
 	}
-	left := parts[0]
-	operator := parts[1]
-	right := parts[2]
-	return compareString(left, operator, strings.Trim(right, "\""))
+	return triggers
 }
 
-func compareString(a, op, b string) bool {
-	switch op {
-	case "==":
-		return a == b
-	case "!=":
-		return a != b
-	case "()":
-		return strings.Contains(a, b)
-	case "!()":
-		return !strings.Contains(a, b)
-	}
-	aInt, _ := strconv.Atoi(a)
-	bInt, _ := strconv.Atoi(b)
-	switch op {
-	case ">":
-		return aInt > bInt
-	case "<":
-		return aInt < bInt
-	case ">=":
-		return aInt >= bInt
-	case "<=":
-		return aInt <= bInt
+// allowlist와 일치하는 이벤트는 어떤 규칙으로도 평가하지 않는다.
+// dedup이 있는 정책은 억제 중인 알림의 print/alert를 건너뛰되 대응 액션은 그대로 적용한다.
+func MatchedEvent(policies []Policy, event utils.Event) {
+	if globalAllowlist.Load().match(&event) {
+		return
 	}
-	return false
-}
+	now := time.Now()
+	sequenceEngine.feed(&event, now)
 
-func replaceAllPlaceholders(input string, event utils.Event) string {
-	v := reflect.ValueOf(event)
-	t := v.Type()
-
-	for i := 0; i < v.NumField(); i++ {
-		fieldName := t.Field(i).Name
-		fieldValue := fmt.Sprintf("%v", v.Field(i).Interface())
-
-		if fieldName == "StartAddr" || fieldName == "EndAddr" {
-			fieldValue = fmt.Sprintf("0x%X", v.Field(i).Interface())
+	for _, t := range triggeredPolicies(policies, &event, now) {
+		policy := t.policy
+		if policy.suppressor != nil && !policy.suppressor.admit(policy, &event, t.message) {
+			runResponses(policy, &event)
+			continue
 		}
-		input = strings.ReplaceAll(input, "%"+fieldName+"%", fieldValue)
+		runActions(policy, event, t.message, true)
 	}
-	return input
 }
 
-func checkPolicyTimeConditions(timeCondition []TimeCondition, eventTime string) bool {
-	if len(timeCondition) == 0 {
-		return true
+// 정책의 액션을 순서대로 실행한다. ignore는 matchPolicies에서 다른 정책을 막을 뿐 실행할 것이 없다.
+// respond가 false이면(억제한 알림의 요약) 대응 액션은 이미 실행했으므로 건너뛰고, 프로세스가 이미 끝났을 수 있어
+// 스냅샷도 남기지 않는다. exec:, webhook: 액션과 스냅샷은 alert처럼 알림을 보낼 때만 실행한다.
+func runActions(policy *Policy, event utils.Event, message string, respond bool) {
+	if respond && snapshotWanted(policy) && !hasAction(policy.Action, "ignore") {
+		takeSnapshot(policy, event, message)
 	}
-	exchangeEventTimes := utils.TimeExchange(eventTime, HostRegion)
-	weekday := exchangeEventTimes.Weekday().String()
-	checkTime := exchangeEventTimes.Format("15:04")
-	for _, timeCondition := range timeCondition {
-		if timeCondition.Day != weekday {
-			continue
-		}
-		for _, timeRange := range timeCondition.TimeRanges {
-			if isTimeInRange(checkTime, timeRange.Start, timeRange.End) {
-				return true
+	for _, action := range strings.Fields(policy.Action) {
+		switch strings.TrimSpace(action) {
+		case "kill", "pause_container", "stop_container":
+			if respond {
+				respondAction(policy, &event, action)
+			}
+		case "print":
+			fmt.Println(message)
+		case "alert":
+			utils.RuleSend(policy.ruleMeta(), policy.PolicyName, message, event)
+		default:
+			if isHookAction(action) {
+				runHook(policy, event, message, action)
 			}
 		}
 	}
-	return false
-}
-
-func isTimeInRange(checkTime, start, end string) bool {
-	layout := "15:04"
-	t, _ := time.Parse(layout, checkTime)
-	s, _ := time.Parse(layout, start)
-	e, _ := time.Parse(layout, end)
-
-	if end == "24:00" {
-		e = e.Add(24 * time.Hour)
-	}
-	return (t.After(s) || t.Equal(s)) && (t.Before(e) || t.Equal(e))
 }
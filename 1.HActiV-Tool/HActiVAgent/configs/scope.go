@@ -0,0 +1,221 @@
+// Copyright Authors of HActiV
+
+// configs package for Setting and detection rules
+package configs
+
+import (
+	"HActiV/pkg/utils"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/distribution/reference"
+)
+
+// 규칙을 적용할 컨테이너. 지정한 항목은 모두 일치해야 하고(and), 항목 안의 값은 하나만 일치하면 된다(or).
+// exclude와 일치하는 컨테이너에는 적용하지 않는다. scope가 없으면 모든 컨테이너에 적용한다.
+//
+//	"scope": {
+//	  "containers": ["web-*", "H"],
+//	  "images": ["nginx", "ghcr.io/acme/*:1.*"],
+//	  "labels": {"com.acme.exposure": "internet"},
+//	  "exclude": {"labels": {"com.acme.role": "build"}}
+//	}
+//
+// containers는 컨테이너 이름 glob이며 "H"는 호스트이다. images는 이미지 참조 glob으로 태그를 생략하면 모든 태그와 일치한다.
+// labels는 Docker 레이블별 값 glob이며 "*"이면 레이블이 있기만 하면 된다. 호스트에는 이미지와 레이블이 없다.
+type Scope struct {
+	Containers []string          `json:"containers,omitempty"`
+	Images     []string          `json:"images,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	Exclude    *Scope            `json:"exclude,omitempty"`
+}
+
+// 컴파일된 적용 범위
+type scopeMatcher struct {
+	containers []*regexp.Regexp
+	images     []imagePattern
+	labels     map[string]*regexp.Regexp
+	exclude    *scopeMatcher
+}
+
+// 이미지 참조 glob. tagged이면 태그나 다이제스트까지 비교하고, 아니면 저장소 이름만 비교한다.
+type imagePattern struct {
+	pattern *regexp.Regexp
+	tagged  bool
+}
+
+func compileScope(s *Scope) (*scopeMatcher, error) {
+	if s == nil {
+		return nil, nil
+	}
+	m, err := compileScopeItems(s)
+	if err != nil {
+		return nil, err
+	}
+	if s.Exclude != nil {
+		if s.Exclude.Exclude != nil {
+			return nil, fmt.Errorf("scope.exclude 안에는 exclude를 쓸 수 없습니다.")
+		}
+		if m.exclude, err = compileScopeItems(s.Exclude); err != nil {
+			return nil, fmt.Errorf("exclude: %v", err)
+		}
+		if m.exclude.empty() {
+			return nil, fmt.Errorf("scope.exclude에 containers, images, labels 중 하나 이상을 지정하세요.")
+		}
+	}
+	if m.empty() && m.exclude == nil {
+		return nil, fmt.Errorf("scope에 containers, images, labels, exclude 중 하나 이상을 지정하세요.")
+	}
+	return m, nil
+}
+
+func compileScopeItems(s *Scope) (*scopeMatcher, error) {
+	m := &scopeMatcher{}
+	for _, pattern := range s.Containers {
+		re, err := globToRegexp(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("scope.containers: %v", err)
+		}
+		m.containers = append(m.containers, re)
+	}
+	for _, pattern := range s.Images {
+		if pattern == "" {
+			return nil, fmt.Errorf("scope.images에 빈 이미지가 있습니다.")
+		}
+		re, err := globToRegexp(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("scope.images: %v", err)
+		}
+		name := pattern[strings.LastIndex(pattern, "/")+1:]
+		m.images = append(m.images, imagePattern{pattern: re, tagged: strings.ContainsAny(name, ":@")})
+	}
+	if len(s.Labels) > 0 {
+		m.labels = make(map[string]*regexp.Regexp, len(s.Labels))
+		for key, value := range s.Labels {
+			if key == "" {
+				return nil, fmt.Errorf("scope.labels에 빈 레이블 이름이 있습니다.")
+			}
+			if value == "*" {
+				m.labels[key] = nil
+				continue
+			}
+			re, err := globToRegexp(value)
+			if err != nil {
+				return nil, fmt.Errorf("scope.labels의 '%s': %v", key, err)
+			}
+			m.labels[key] = re
+		}
+	}
+	return m, nil
+}
+
+func (m *scopeMatcher) empty() bool {
+	return len(m.containers) == 0 && len(m.images) == 0 && len(m.labels) == 0
+}
+
+// 이벤트가 일어난 컨테이너가 적용 범위 안인지 확인한다. 범위가 없으면 항상 참이다.
+func (m *scopeMatcher) match(event *utils.Event) bool {
+	if m == nil {
+		return true
+	}
+	if !m.matchItems(event) {
+		return false
+	}
+	return m.exclude == nil || !m.exclude.matchItems(event)
+}
+
+func (m *scopeMatcher) matchItems(event *utils.Event) bool {
+	if len(m.containers) > 0 && !matchAny(m.containers, event.ContainerName) {
+		return false
+	}
+	if len(m.images) > 0 && !m.matchImage(event.ContainerImage) {
+		return false
+	}
+	for key, pattern := range m.labels {
+		value, ok := event.ContainerLabels[key]
+		if !ok || (pattern != nil && !pattern.MatchString(value)) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchAny(patterns []*regexp.Regexp, s string) bool {
+	for _, pattern := range patterns {
+		if pattern.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *scopeMatcher) matchImage(image string) bool {
+	if image == "" {
+		return false
+	}
+	ref := parseImageReference(image)
+	for _, p := range m.images {
+		candidates := ref.repositories
+		if p.tagged {
+			candidates = ref.references
+		}
+		for _, candidate := range candidates {
+			if p.pattern.MatchString(candidate) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 이미지 참조를 비교할 수 있는 형태들. 'nginx'는 저장소 'nginx', 'docker.io/library/nginx'와
+// 참조 'nginx:latest', 'docker.io/library/nginx:latest'로 비교한다.
+type imageReference struct {
+	repositories []string
+	references   []string
+}
+
+var imageReferences sync.Map
+
+// 컨테이너의 이미지는 몇 개 되지 않으므로 이미지별로 한 번만 해석해 둔다.
+func parseImageReference(image string) imageReference {
+	if cached, ok := imageReferences.Load(image); ok {
+		return cached.(imageReference)
+	}
+	ref := imageReference{repositories: []string{image}, references: []string{image}}
+	if named, err := reference.ParseNormalizedNamed(image); err == nil {
+		named = reference.TagNameOnly(named)
+		familiar := reference.FamiliarString(named)
+		ref = imageReference{
+			repositories: uniqueSorted([]string{reference.FamiliarName(named), named.Name()}),
+			references:   uniqueSorted([]string{familiar, named.String()}),
+		}
+	}
+	imageReferences.Store(image, ref)
+	return ref
+}
+
+func describeScope(s *Scope) string {
+	var parts []string
+	if len(s.Containers) > 0 {
+		parts = append(parts, "컨테이너 ["+strings.Join(s.Containers, ", ")+"]")
+	}
+	if len(s.Images) > 0 {
+		parts = append(parts, "이미지 ["+strings.Join(s.Images, ", ")+"]")
+	}
+	if len(s.Labels) > 0 {
+		labels := make([]string, 0, len(s.Labels))
+		for key, value := range s.Labels {
+			labels = append(labels, key+"="+value)
+		}
+		sort.Strings(labels)
+		parts = append(parts, "레이블 ["+strings.Join(labels, ", ")+"]")
+	}
+	if s.Exclude != nil {
+		parts = append(parts, "제외 ("+describeScope(s.Exclude)+")")
+	}
+	return strings.Join(parts, " | ")
+}
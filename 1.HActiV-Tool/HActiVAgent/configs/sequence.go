@@ -0,0 +1,303 @@
+// Copyright Authors of HActiV
+
+// configs package for Setting and detection rules
+package configs
+
+import (
+	"HActiV/pkg/utils"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 순서 규칙(sequencerule.json). 여러 모니터의 이벤트가 steps 순서대로 within 안에 일어나면 액션을 실행한다.
+//
+//	key: "container" 같은 컨테이너 안의 이벤트끼리 묶는다.
+//	     "lineage"   같은 컨테이너이면서 첫 단계 프로세스의 자손(또는 자신)인 이벤트끼리 묶는다.
+//	                 pid가 없는 이벤트(예: 네트워크)는 컨테이너만 비교한다.
+type SequenceRule struct {
+	EventName   string         `json:"event_name"`
+	Description string         `json:"description"`
+	Usage       bool           `json:"usage"`
+	Severity    string         `json:"severity,omitempty"`
+	Tags        []string       `json:"tags,omitempty"`
+	Mitre       *Mitre         `json:"mitre,omitempty"`
+	Key         string         `json:"key"`
+	Within      string         `json:"within"`
+	Steps       []SequenceStep `json:"steps"`
+	Action      string         `json:"action"`
+	PrintFormat string         `json:"print_format"`
+}
+
+type SequenceStep struct {
+	Tool      string `json:"tool"`
+	Condition string `json:"condition"`
+}
+
+// 규칙 파일 이름의 도구명과 Event.Tool 값의 대응
+var sequenceTools = map[string]string{
+	"execve":  "Systemcall",
+	"open":    "file_open",
+	"delete":  "delete",
+	"memory":  "Memory",
+	"network": "Network_traffic",
+}
+
+const (
+	maxSequenceInstances = 1024
+	maxLineageEntries    = 65536
+)
+
+type sequence struct {
+	policy  Policy
+	lineage bool
+	within  time.Duration
+	steps   []sequenceStep
+}
+
+type sequenceStep struct {
+	tool string
+	expr conditionNode
+}
+
+// 진행 중인 순서 하나. 첫 단계가 일치할 때 만들어진다.
+type sequenceInstance struct {
+	container string
+	rootPid   uint32
+	started   time.Time
+	events    []utils.Event
+}
+
+type correlator struct {
+	mu        sync.Mutex
+	sequences []*sequence
+	instances map[*sequence][]*sequenceInstance
+	parents   map[uint32]uint32
+}
+
+var sequenceEngine = &correlator{}
+
+func LoadSequenceRules() error {
+	rules, err := readSequenceRuleFile(RuleLocation + "sequencerule.json")
+	if err != nil {
+		return err
+	}
+
+	sequences, ruleErrs := compileSequences(rules)
+	for i := range rules {
+		if errMsg, ok := ruleErrs[i]; ok {
+			fmt.Printf("순서 정책 '%s'이(가) 유효하지 않습니다: %s\n", rules[i].EventName, errMsg)
+		}
+	}
+	for _, seq := range sequences {
+		fmt.Printf("순서 정책: %s\n", seq.policy.PolicyName)
+		fmt.Printf("  설명: %s\n", seq.policy.Description)
+		for i, step := range seq.steps {
+			fmt.Printf("  %d단계: %s\n", i+1, step.tool)
+		}
+		fmt.Printf("  시간: %s 이내\n", seq.within)
+	}
+	sequenceEngine.setSequences(sequences)
+	return nil
+}
+
+// 순서 규칙 파일을 읽는다. 파일이 없거나 비어 있으면 규칙이 없는 것으로 본다.
+func readSequenceRuleFile(filename string) ([]SequenceRule, error) {
+	data, err := os.ReadFile(filename)
+	if os.IsNotExist(err) || (err == nil && len(strings.TrimSpace(string(data))) == 0) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var rules []SequenceRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func compileSequences(rules []SequenceRule) ([]*sequence, map[int]string) {
+	fieldTypes := getFieldTypes()
+	ruleErrs := make(map[int]string)
+
+	var sequences []*sequence
+	for i, rule := range rules {
+		if !rule.Usage {
+			continue
+		}
+		seq, err := compileSequence(rule, fieldTypes)
+		if err != nil {
+			ruleErrs[i] = err.Error()
+			continue
+		}
+		sequences = append(sequences, seq)
+	}
+	return sequences, ruleErrs
+}
+
+func compileSequence(rule SequenceRule, fieldTypes map[string]string) (*sequence, error) {
+	if len(rule.Steps) < 2 {
+		return nil, fmt.Errorf("steps는 2단계 이상이어야 합니다.")
+	}
+	within, err := time.ParseDuration(rule.Within)
+	if err != nil || within <= 0 {
+		return nil, fmt.Errorf("within '%s'이(가) 올바르지 않습니다. 예시: 30s, 5m", rule.Within)
+	}
+	if rule.Key != "container" && rule.Key != "lineage" {
+		return nil, fmt.Errorf("key '%s'이(가) 올바르지 않습니다. container 또는 lineage를 사용하세요.", rule.Key)
+	}
+	if hasAction(rule.Action, "ignore") {
+		return nil, fmt.Errorf("순서 규칙에는 ignore 액션을 사용할 수 없습니다.")
+	}
+	for _, action := range strings.Fields(rule.Action) {
+		if err := checkAction(action); err != nil {
+			return nil, err
+		}
+	}
+	if err := validateRuleMeta(rule.Severity, rule.Mitre); err != nil {
+		return nil, err
+	}
+
+	seq := &sequence{
+		lineage: rule.Key == "lineage",
+		within:  within,
+		policy: Policy{
+			PolicyName:  rule.EventName,
+			Description: rule.Description,
+			Severity:    strings.ToLower(rule.Severity),
+			Tags:        rule.Tags,
+			Mitre:       rule.Mitre,
+			Action:      strings.TrimSpace(rule.Action),
+			PrintFormat: rule.PrintFormat,
+			format:      compilePrintFormat(rule.PrintFormat),
+		},
+	}
+	for i, step := range rule.Steps {
+		tool, ok := sequenceTools[step.Tool]
+		if !ok {
+			return nil, fmt.Errorf("%d단계의 tool '%s'이(가) 올바르지 않습니다.", i+1, step.Tool)
+		}
+		expr, err := parseCondition(strings.TrimSpace(step.Condition), fieldTypes, nil)
+		if err != nil {
+			return nil, fmt.Errorf("%d단계 조건 오류: %v", i+1, err)
+		}
+		seq.steps = append(seq.steps, sequenceStep{tool: tool, expr: expr})
+	}
+	return seq, nil
+}
+
+func (c *correlator) setSequences(sequences []*sequence) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sequences = sequences
+	c.instances = make(map[*sequence][]*sequenceInstance)
+	c.parents = make(map[uint32]uint32)
+}
+
+// 모든 모니터의 이벤트가 MatchedEvent를 거쳐 들어온다. 완성된 순서는 액션을 실행한다.
+func (c *correlator) feed(event *utils.Event, now time.Time) {
+	for _, done := range c.advance(event, now) {
+		runActions(done.policy, done.event, done.message, true)
+	}
+}
+
+type completion struct {
+	policy  *Policy
+	event   utils.Event
+	message string
+}
+
+// 이벤트로 진행 중인 순서를 한 단계씩 넘기고, 이 이벤트로 완성된 순서를 돌려준다.
+func (c *correlator) advance(event *utils.Event, now time.Time) []completion {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.sequences) == 0 {
+		return nil
+	}
+	c.learnParent(event)
+
+	var completed []completion
+	for _, seq := range c.sequences {
+		var kept []*sequenceInstance
+		for _, instance := range c.instances[seq] {
+			if now.Sub(instance.started) > seq.within {
+				continue
+			}
+			step := seq.steps[len(instance.events)]
+			if step.tool == event.Tool && c.sameKey(seq, instance, event) && step.expr.evaluate(event) {
+				instance.events = append(instance.events, *event)
+				if len(instance.events) == len(seq.steps) {
+					completed = append(completed, completion{
+						policy:  &seq.policy,
+						event:   *event,
+						message: seq.policy.format.render(event) + " | " + describeSequence(instance.events),
+					})
+					continue
+				}
+			}
+			kept = append(kept, instance)
+		}
+
+		first := seq.steps[0]
+		if first.tool == event.Tool && first.expr.evaluate(event) {
+			if len(kept) >= maxSequenceInstances {
+				kept = kept[1:]
+			}
+			kept = append(kept, &sequenceInstance{
+				container: event.ContainerName,
+				rootPid:   event.Pid,
+				started:   now,
+				events:    []utils.Event{*event},
+			})
+		}
+		c.instances[seq] = kept
+	}
+	return completed
+}
+
+func (c *correlator) learnParent(event *utils.Event) {
+	if event.Pid == 0 || event.Ppid == 0 {
+		return
+	}
+	if len(c.parents) >= maxLineageEntries {
+		c.parents = make(map[uint32]uint32)
+	}
+	c.parents[event.Pid] = event.Ppid
+}
+
+func (c *correlator) sameKey(seq *sequence, instance *sequenceInstance, event *utils.Event) bool {
+	if event.ContainerName != instance.container {
+		return false
+	}
+	if !seq.lineage || event.Pid == 0 || instance.rootPid == 0 {
+		return true
+	}
+	// pid 재사용으로 인한 순환을 막기 위해 조상 탐색 깊이를 제한한다.
+	pid := event.Pid
+	for depth := 0; depth < 64 && pid != 0; depth++ {
+		if pid == instance.rootPid {
+			return true
+		}
+		pid = c.parents[pid]
+	}
+	return false
+}
+
+func describeSequence(events []utils.Event) string {
+	steps := make([]string, len(events))
+	for i, event := range events {
+		switch event.Tool {
+		case "Network_traffic":
+			steps[i] = fmt.Sprintf("%s %s -> %s", event.Tool, event.SrcIp, event.DstIp)
+		case "Memory":
+			steps[i] = fmt.Sprintf("%s %s(%d) %s", event.Tool, event.ProcessName, event.Pid, event.Syscall)
+		default:
+			steps[i] = fmt.Sprintf("%s %s(%d) %s", event.Tool, event.ProcessName, event.Pid, event.Filename)
+		}
+	}
+	return "순서: " + strings.Join(steps, " => ")
+}
@@ -32,14 +32,32 @@ func HActiVSetting() {
 		defer file.Close()
 		//Url 필드 제거 후 DataUrl, RuleUrl 필드 추가
 		data := map[string]string{
-			"HostMonitoring": "False",
-			"RuleLocation":   "/etc/HActiV/rules",
-			"API":            "TestAPIForBasic",
-			"DataUrl":        "http://localhost:8080/api/dashboard",
-			"DataSend":       "True",
-			"RuleUrl":        "http://localhost:8080/api/alert",
-			"Region":         "Asia/Seoul",
-			"LogLocation":    "/etc/HActiV/logs",
+			"HostMonitoring":             "False",
+			"RuleLocation":               "/etc/HActiV/rules",
+			"API":                        "TestAPIForBasic",
+			"DataUrl":                    "http://localhost:8080/api/dashboard",
+			"DataSend":                   "True",
+			"RuleUrl":                    "http://localhost:8080/api/alert",
+			"Region":                     "Asia/Seoul",
+			"LogLocation":                "/etc/HActiV/logs",
+			"ResponseDryRun":             "False",
+			"ProtectedContainers":        "hactiv*,HActiV*",
+			"SnapshotSeverity":           "critical",
+			"SnapshotMaxSizeMB":          "1024",
+			"SendQueueSize":              "10000",
+			"SendBatchSize":              "100",
+			"SendBatchInterval":          "500ms",
+			"SendTimeout":                "10s",
+			"SendRetries":                "5",
+			"SendGzip":                   "True",
+			"SpoolMaxSizeMB":             "256",
+			"DataSink":                   "http",
+			"KafkaBrokers":               "",
+			"GrpcAddr":                   "",
+			"GrpcTLS":                    "False",
+			"GrpcCAFile":                 "",
+			"GrpcInsecureRuleUpdates":    "False",
+			"AllowRemoteResponseActions": "False",
 		}
 		encoder := json.NewEncoder(file)
 		encoder.SetIndent("", "  ")
@@ -73,6 +91,27 @@ func HActiVSetting() {
 	ruleurl := existingData["RuleUrl"]
 	HostRegion = existingData["Region"]
 	logLocation := existingData["LogLocation"]
+	ResponseDryRun, err = strconv.ParseBool(strings.TrimSpace(existingData["ResponseDryRun"]))
+	if err != nil {
+		ResponseDryRun = false // 기본값 False
+	}
+	AllowRemoteResponseActions, err = strconv.ParseBool(strings.TrimSpace(existingData["AllowRemoteResponseActions"]))
+	if err != nil {
+		AllowRemoteResponseActions = false // 기본값 False
+	}
+	protectedContainers, ok := existingData["ProtectedContainers"]
+	if !ok {
+		protectedContainers = "hactiv*,HActiV*"
+	}
+	ProtectedContainers, protectedPatterns = parseProtectedContainers(protectedContainers)
+	snapshotSeverity, ok := existingData["SnapshotSeverity"]
+	if !ok {
+		snapshotSeverity = "critical"
+	}
+	SnapshotSeverity = parseSnapshotSeverity(snapshotSeverity)
+	if snapshotMaxSize, ok := existingData["SnapshotMaxSizeMB"]; ok {
+		SnapshotMaxSize = parseSnapshotMaxSize(snapshotMaxSize)
+	}
 
 	_, err = time.LoadLocation(HostRegion)
 	if err != nil {
@@ -88,9 +127,15 @@ func HActiVSetting() {
 	fmt.Printf("RuleUrl: %s\n", ruleurl)
 	fmt.Printf("Region: %s\n", HostRegion)
 	fmt.Printf("LogLocation: %s\n", logLocation)
+	fmt.Printf("ResponseDryRun: %t\n", ResponseDryRun)
+	fmt.Printf("AllowRemoteResponseActions: %t\n", AllowRemoteResponseActions)
+	fmt.Printf("ProtectedContainers: %s\n", strings.Join(ProtectedContainers, ", "))
+	fmt.Printf("SnapshotSeverity: %s\n", SnapshotSeverity)
+	fmt.Printf("SnapshotMaxSizeMB: %d\n", SnapshotMaxSize>>20)
 
-	if !strings.HasSuffix(ruleLocation, "/") {
-		RuleLocation = ruleLocation + "/"
+	RuleLocation = ruleLocation
+	if !strings.HasSuffix(RuleLocation, "/") {
+		RuleLocation += "/"
 	}
 	if !strings.HasSuffix(logLocation, "/") {
 		logLocation = logLocation + "/"
@@ -106,10 +151,27 @@ func ConfigError(errmsg string) {
 
 func FirstRules() {
 	os.MkdirAll(RuleLocation, 0755)
-	for _, fileName := range []string{"delete", "execve", "memory", "network", "open"} {
+	for _, fileName := range []string{"delete", "execve", "memory", "network", "open", "sequence"} {
 		filePath := RuleLocation + fileName + "rule.json"
 		if _, err := os.Stat(filePath); os.IsNotExist(err) {
 			os.Create(filePath)
 		}
 	}
 }
+
+// Setting.json을 만들거나 출력하지 않고 규칙 디렉터리만 읽는다. rules 하위 명령에서 사용한다.
+func ReadRuleLocation() string {
+	ruleLocation := "/etc/HActiV/rules"
+
+	data, err := os.ReadFile("/etc/HActiV/Setting.json")
+	if err == nil {
+		var setting map[string]string
+		if json.Unmarshal(data, &setting) == nil && strings.TrimSpace(setting["RuleLocation"]) != "" {
+			ruleLocation = strings.TrimSpace(setting["RuleLocation"])
+		}
+	}
+	if !strings.HasSuffix(ruleLocation, "/") {
+		ruleLocation += "/"
+	}
+	return ruleLocation
+}
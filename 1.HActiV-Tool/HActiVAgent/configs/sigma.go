@@ -0,0 +1,466 @@
+// Copyright Authors of HActiV
+
+// configs package for Setting and detection rules
+package configs
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Sigma logsource.category별 도구와 필드 대응. 대응이 없는 필드를 쓰는 규칙은 변환하지 않는다.
+var sigmaCategories = map[string]struct {
+	tool   string
+	fields map[string]string
+}{
+	"process_creation": {"execve", map[string]string{
+		"Image":           "Filename",
+		"CommandLine":     "Args",
+		"ProcessId":       "Pid",
+		"ParentProcessId": "Ppid",
+	}},
+	"file_event": {"open", map[string]string{
+		"TargetFilename": "Filename",
+		"ProcessId":      "Pid",
+	}},
+	"file_access": {"open", map[string]string{
+		"TargetFilename": "Filename",
+		"ProcessId":      "Pid",
+	}},
+	"file_delete": {"delete", map[string]string{
+		"TargetFilename": "Filename",
+		"ProcessId":      "Pid",
+	}},
+	"network_connection": {"network", map[string]string{
+		"DestinationIp":   "DstIp",
+		"DestinationPort": "DstPort",
+		"SourceIp":        "SrcIp",
+		"SourcePort":      "SrcPort",
+		"Protocol":        "Protocol",
+		"Initiated":       "Direction",
+	}},
+}
+
+var sigmaLevels = map[string]string{
+	"informational": "info",
+	"low":           "low",
+	"medium":        "medium",
+	"high":          "high",
+	"critical":      "critical",
+}
+
+// Sigma 규칙 문서 하나를 변환한다.
+func importSigmaRule(result *ImportResult, file string, doc *yaml.Node) {
+	var rule struct {
+		Title       string   `yaml:"title"`
+		ID          string   `yaml:"id"`
+		Description string   `yaml:"description"`
+		Level       string   `yaml:"level"`
+		Tags        []string `yaml:"tags"`
+		Logsource   struct {
+			Category string `yaml:"category"`
+			Product  string `yaml:"product"`
+		} `yaml:"logsource"`
+	}
+	if err := doc.Decode(&rule); err != nil {
+		result.skip(file, doc.Line, "", "%v", err)
+		return
+	}
+	name := rule.Title
+	if name == "" {
+		name = rule.ID
+	}
+
+	if rule.Logsource.Product != "" && rule.Logsource.Product != "linux" {
+		result.skip(file, doc.Line, name, "logsource.product '%s'은(는) 지원하지 않습니다. linux 규칙만 변환합니다.", rule.Logsource.Product)
+		return
+	}
+	category, ok := sigmaCategories[rule.Logsource.Category]
+	if !ok {
+		result.skip(file, doc.Line, name, "logsource.category '%s'에 대응하는 모니터가 없습니다. process_creation, file_event, file_access, file_delete, network_connection을 지원합니다.", rule.Logsource.Category)
+		return
+	}
+
+	c := &sigmaConverter{fields: category.fields, detection: yamlMapValue(doc, "detection")}
+	condition, err := c.convert()
+	if err != nil {
+		line := doc.Line
+		if c.line > 0 {
+			line = c.line
+		}
+		result.skip(file, line, name, "%v", err)
+		return
+	}
+
+	severity := ""
+	if rule.Level != "" {
+		if severity, ok = sigmaLevels[strings.ToLower(rule.Level)]; !ok {
+			result.issue(file, doc.Line, name, "level '%s'을(를) 알 수 없어 severity를 비워 둡니다.", rule.Level)
+		}
+	}
+	mitre, tags := mitreFromTags(rule.Tags, "attack.")
+	result.add(file, doc.Line, category.tool, importedRule(category.tool, name, rule.Description, severity, tags, mitre, condition), nil)
+}
+
+// detection의 selection들을 조건식으로 바꾼다. line은 변환하지 못한 항목의 줄이다.
+type sigmaConverter struct {
+	fields    map[string]string
+	detection *yaml.Node
+	line      int
+}
+
+func (c *sigmaConverter) fail(node *yaml.Node, format string, args ...interface{}) error {
+	c.line = node.Line
+	return fmt.Errorf(format, args...)
+}
+
+func (c *sigmaConverter) convert() (string, error) {
+	if c.detection.Kind != yaml.MappingNode {
+		return "", c.fail(c.detection, "detection은 객체여야 합니다.")
+	}
+	selections := make(map[string]*yaml.Node)
+	var names []string
+	var conditions []*yaml.Node
+	for i := 0; i+1 < len(c.detection.Content); i += 2 {
+		key, value := c.detection.Content[i], c.detection.Content[i+1]
+		switch key.Value {
+		case "condition":
+			conditions = append(conditions, value)
+		case "timeframe":
+			return "", c.fail(key, "timeframe(집계)은 지원하지 않습니다. 변환한 뒤 aggregation으로 작성하세요.")
+		default:
+			selections[key.Value] = value
+			names = append(names, key.Value)
+		}
+	}
+	if len(conditions) == 0 {
+		return "", c.fail(c.detection, "detection.condition이 없습니다.")
+	}
+
+	// condition이 목록이면 각 조건 중 하나와 일치하면 된다.
+	var texts []*yaml.Node
+	if conditions[0].Kind == yaml.SequenceNode {
+		texts = conditions[0].Content
+	} else {
+		texts = conditions
+	}
+	var parts []string
+	for _, text := range texts {
+		p := &sigmaConditionParser{converter: c, node: text, selections: selections, names: names, tokens: tokenizeSigmaCondition(text.Value)}
+		part, err := p.parse()
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " or "), nil
+}
+
+// selection 하나의 조건식. 객체는 모든 항목과, 객체의 목록은 객체 중 하나와 일치해야 한다.
+func (c *sigmaConverter) selection(node *yaml.Node) (string, error) {
+	switch {
+	case node.Kind == yaml.MappingNode:
+		var parts []string
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			part, err := c.fieldCondition(node.Content[i], node.Content[i+1])
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, part)
+		}
+		if len(parts) == 0 {
+			return "", c.fail(node, "빈 selection은 변환할 수 없습니다.")
+		}
+		return joinConditions(parts, "and"), nil
+	case node.Kind == yaml.SequenceNode && len(node.Content) > 0 && node.Content[0].Kind == yaml.MappingNode:
+		var parts []string
+		for _, item := range node.Content {
+			part, err := c.selection(item)
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, part)
+		}
+		return joinConditions(parts, "or"), nil
+	default:
+		return "", c.fail(node, "필드 없이 값만 쓴 키워드 검색은 변환할 수 없습니다.")
+	}
+}
+
+var sigmaSupportedModifiers = []string{"contains", "startswith", "endswith", "all", "re", "cidr", "gt", "gte", "lt", "lte"}
+
+// 'Field|modifier|...: 값 또는 값 목록' 항목 하나의 조건식. 값이 여럿이면 하나와 일치하면 되고, all이면 모두와 일치해야 한다.
+func (c *sigmaConverter) fieldCondition(key, value *yaml.Node) (string, error) {
+	parts := strings.Split(key.Value, "|")
+	sigmaField, modifiers := parts[0], parts[1:]
+	field, ok := c.fields[sigmaField]
+	if !ok {
+		return "", c.fail(key, "필드 '%s'에 대응하는 HActiV 필드가 없습니다.", sigmaField)
+	}
+	for _, modifier := range modifiers {
+		if !contains(sigmaSupportedModifiers, modifier) {
+			return "", c.fail(key, "'%s'의 수정자 '%s'는 지원하지 않습니다.", key.Value, modifier)
+		}
+	}
+
+	var values []*yaml.Node
+	switch value.Kind {
+	case yaml.ScalarNode:
+		values = []*yaml.Node{value}
+	case yaml.SequenceNode:
+		values = value.Content
+	default:
+		return "", c.fail(value, "'%s'의 값은 문자열이나 문자열 목록이어야 합니다.", key.Value)
+	}
+
+	joiner := "or"
+	if contains(modifiers, "all") {
+		joiner = "and"
+	}
+	var conds []string
+	for _, v := range values {
+		if v.Kind != yaml.ScalarNode || v.Tag == "!!null" {
+			return "", c.fail(v, "'%s'의 값 '%s'은(는) 변환할 수 없습니다.", key.Value, v.Value)
+		}
+		cond, err := c.valueCondition(field, modifiers, v)
+		if err != nil {
+			return "", err
+		}
+		conds = append(conds, cond)
+	}
+	return joinConditions(conds, joiner), nil
+}
+
+// Sigma의 문자열 비교는 대소문자를 구분하지 않고 값의 *, ?는 와일드카드이다. 정규식(re)만 대소문자를 구분한다.
+func (c *sigmaConverter) valueCondition(field string, modifiers []string, v *yaml.Node) (string, error) {
+	fieldName := "%" + field + "%"
+	value := v.Value
+
+	if field == "Direction" {
+		switch strings.ToLower(value) {
+		case "true":
+			return fieldName + " == outgoing", nil
+		case "false":
+			return fieldName + " == incoming", nil
+		}
+		return "", c.fail(v, "Initiated의 값 '%s'은(는) true 또는 false여야 합니다.", value)
+	}
+
+	if contains(integerTypes, eventFields[field].kind) {
+		if _, err := strconv.Atoi(value); err != nil {
+			return "", c.fail(v, "정수 필드 %s에 정수가 아닌 값 '%s'을(를) 쓸 수 없습니다.", fieldName, value)
+		}
+		operator := "=="
+		for modifier, op := range map[string]string{"gt": ">", "gte": ">=", "lt": "<", "lte": "<="} {
+			if contains(modifiers, modifier) {
+				operator = op
+			}
+		}
+		return fieldName + " " + operator + " " + value, nil
+	}
+
+	switch {
+	case contains(modifiers, "re"):
+		return fieldName + " =~ " + quoteConditionValue(value), nil
+	case contains(modifiers, "cidr"):
+		return fieldName + " cidr " + conditionList([]string{quoteConditionValue(value)}), nil
+	case contains(modifiers, "gt"), contains(modifiers, "gte"), contains(modifiers, "lt"), contains(modifiers, "lte"):
+		return "", c.fail(v, "문자열 필드 %s에는 크기 비교 수정자를 쓸 수 없습니다.", fieldName)
+	}
+
+	pattern, wildcard := sigmaPattern(value)
+	switch {
+	case contains(modifiers, "contains"):
+		if !wildcard {
+			return fieldName + " i() " + quoteConditionValue(value), nil
+		}
+	case contains(modifiers, "startswith"):
+		pattern = "^" + pattern
+	case contains(modifiers, "endswith"):
+		pattern += "$"
+	default:
+		if !wildcard {
+			return fieldName + " i== " + quoteConditionValue(value), nil
+		}
+		pattern = "^" + pattern + "$"
+	}
+	return fieldName + " =~ " + quoteConditionValue("(?i)"+pattern), nil
+}
+
+// Sigma 값을 정규식으로 바꾼다. '\'로 이스케이프하지 않은 *, ?가 있으면 wildcard가 true이다.
+func sigmaPattern(value string) (string, bool) {
+	var b strings.Builder
+	wildcard := false
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; {
+		case c == '\\' && i+1 < len(value) && strings.IndexByte(`*?\`, value[i+1]) >= 0:
+			b.WriteString(regexp.QuoteMeta(value[i+1 : i+2]))
+			i++
+		case c == '*':
+			b.WriteString(".*")
+			wildcard = true
+		case c == '?':
+			b.WriteString(".")
+			wildcard = true
+		default:
+			b.WriteString(regexp.QuoteMeta(value[i : i+1]))
+		}
+	}
+	return b.String(), wildcard
+}
+
+func tokenizeSigmaCondition(condition string) []string {
+	condition = strings.NewReplacer("(", " ( ", ")", " ) ").Replace(condition)
+	return strings.Fields(condition)
+}
+
+// Sigma condition 파서.
+//
+//	expr  := and { "or" and }
+//	and   := unary { "and" unary }
+//	unary := "not" unary | "(" expr ")" | ("1" | "all") "of" (pattern | "them") | selection
+type sigmaConditionParser struct {
+	converter  *sigmaConverter
+	node       *yaml.Node
+	selections map[string]*yaml.Node
+	names      []string
+	tokens     []string
+	pos        int
+}
+
+func (p *sigmaConditionParser) fail(format string, args ...interface{}) error {
+	return p.converter.fail(p.node, "condition '%s': %s", p.node.Value, fmt.Sprintf(format, args...))
+}
+
+func (p *sigmaConditionParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *sigmaConditionParser) parse() (string, error) {
+	if len(p.tokens) == 0 {
+		return "", p.fail("조건이 비어 있습니다.")
+	}
+	expr, err := p.parseOr()
+	if err != nil {
+		return "", err
+	}
+	if p.pos < len(p.tokens) {
+		if p.peek() == "|" {
+			return "", p.fail("'|' 뒤의 집계 조건은 지원하지 않습니다.")
+		}
+		return "", p.fail("'%s' 위치에서 and/or가 필요합니다.", p.peek())
+	}
+	return expr, nil
+}
+
+func (p *sigmaConditionParser) parseOr() (string, error) {
+	parts, err := p.parseSeries("or", p.parseAnd)
+	if err != nil {
+		return "", err
+	}
+	return joinConditions(parts, "or"), nil
+}
+
+func (p *sigmaConditionParser) parseAnd() (string, error) {
+	parts, err := p.parseSeries("and", p.parseUnary)
+	if err != nil {
+		return "", err
+	}
+	return joinConditions(parts, "and"), nil
+}
+
+func (p *sigmaConditionParser) parseSeries(operator string, next func() (string, error)) ([]string, error) {
+	first, err := next()
+	if err != nil {
+		return nil, err
+	}
+	parts := []string{first}
+	for strings.EqualFold(p.peek(), operator) {
+		p.pos++
+		part, err := next()
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, part)
+	}
+	return parts, nil
+}
+
+func (p *sigmaConditionParser) parseUnary() (string, error) {
+	token := p.peek()
+	p.pos++
+	switch {
+	case token == "":
+		return "", p.fail("조건식이 완성되지 않았습니다.")
+	case strings.EqualFold(token, "not"):
+		operand, err := p.parseUnary()
+		if err != nil {
+			return "", err
+		}
+		return "not " + operand, nil
+	case token == "(":
+		expr, err := p.parseOr()
+		if err != nil {
+			return "", err
+		}
+		if p.peek() != ")" {
+			return "", p.fail("'('에 대응하는 ')'가 없습니다.")
+		}
+		p.pos++
+		return expr, nil
+	case (token == "1" || strings.EqualFold(token, "all") || strings.EqualFold(token, "any")) && strings.EqualFold(p.peek(), "of"):
+		p.pos++
+		return p.parseQuantifier(token)
+	default:
+		return p.selection(token)
+	}
+}
+
+// '1 of selection_*', 'all of them'. them은 '_'로 시작하지 않는 모든 selection이다.
+func (p *sigmaConditionParser) parseQuantifier(quantifier string) (string, error) {
+	pattern := p.peek()
+	p.pos++
+	if pattern == "" {
+		return "", p.fail("'%s of' 뒤에 selection 이름이 없습니다.", quantifier)
+	}
+	var parts []string
+	for _, name := range p.names {
+		matched := false
+		if pattern == "them" {
+			matched = !strings.HasPrefix(name, "_")
+		} else {
+			matched, _ = path.Match(pattern, name)
+		}
+		if !matched {
+			continue
+		}
+		part, err := p.selection(name)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, part)
+	}
+	if len(parts) == 0 {
+		return "", p.fail("'%s'와 일치하는 selection이 없습니다.", pattern)
+	}
+	if quantifier == "1" || strings.EqualFold(quantifier, "any") {
+		return joinConditions(parts, "or"), nil
+	}
+	return joinConditions(parts, "and"), nil
+}
+
+func (p *sigmaConditionParser) selection(name string) (string, error) {
+	node, ok := p.selections[name]
+	if !ok {
+		return "", p.fail("selection '%s'이(가) 없습니다.", name)
+	}
+	return p.converter.selection(node)
+}
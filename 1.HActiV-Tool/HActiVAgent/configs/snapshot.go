@@ -0,0 +1,451 @@
+// Copyright Authors of HActiV
+
+// configs package for Setting and detection rules
+package configs
+
+import (
+	"HActiV/pkg/docker"
+	"HActiV/pkg/utils"
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// 포렌식 스냅샷. snapshot 액션이 있거나 severity가 SnapshotSeverity 이상인 정책이 알림을 보내면
+// 프로세스와 컨테이너의 증거를 LogLocation/snapshots/ 아래 tar.zst 하나로 묶는다.
+//
+//	summary.json       정책, 메시지, 이벤트, 수집하지 못한 항목
+//	proc/              cmdline, environ, status, maps, fds, cwd, exe(경로와 SHA-256)
+//	process_tree.txt   조상 프로세스와 자식 프로세스
+//	docker_diff.txt    컨테이너 파일시스템 변경 (docker diff)
+//	events.jsonl       컨테이너의 최근 이벤트
+
+// Setting.json의 SnapshotSeverity. 비어 있으면 snapshot 액션이 있는 정책만 스냅샷을 남긴다.
+var SnapshotSeverity string
+
+// Setting.json의 SnapshotMaxSizeMB(바이트). 스냅샷 디렉터리가 이 크기를 넘으면 오래된 스냅샷부터 지운다.
+var SnapshotMaxSize int64 = defaultSnapshotMaxSizeMB << 20
+
+const (
+	snapshotDirName          = "snapshots"
+	defaultSnapshotMaxSizeMB = 1024
+)
+
+// 스냅샷을 지우는 동안 다른 스냅샷이 크기를 다시 계산하지 않도록 한다.
+var snapshotPruneMu sync.Mutex
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// "critical", "none" 형식의 설정 값을 검사한다. 올바르지 않으면 기본값 critical을 쓴다.
+func parseSnapshotSeverity(value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	switch {
+	case value == "" || value == "none":
+		return ""
+	case contains(severities, value):
+		return value
+	}
+	fmt.Printf("SnapshotSeverity '%s'이(가) 올바르지 않아 기본값 critical을 사용합니다.\n", value)
+	return "critical"
+}
+
+// MB 단위 설정 값을 검사한다. 0 이하이거나 올바르지 않으면 기본값을 쓴다.
+func parseSnapshotMaxSize(value string) int64 {
+	n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil || n <= 0 {
+		fmt.Printf("SnapshotMaxSizeMB '%s'이(가) 올바르지 않아 기본값 %d을 사용합니다.\n", value, defaultSnapshotMaxSizeMB)
+		return defaultSnapshotMaxSizeMB << 20
+	}
+	return n << 20
+}
+
+// 정책이 알림을 보낼 때 스냅샷을 남기는지 확인한다.
+func snapshotWanted(policy *Policy) bool {
+	if hasAction(policy.Action, "snapshot") {
+		return true
+	}
+	if SnapshotSeverity == "" || policy.Severity == "" {
+		return false
+	}
+	return severityRank(policy.Severity) >= severityRank(SnapshotSeverity)
+}
+
+func severityRank(severity string) int {
+	for i, s := range severities {
+		if s == strings.ToLower(severity) {
+			return i
+		}
+	}
+	return -1
+}
+
+// 스냅샷을 따로 만든다. 같은 컨테이너와 PID의 스냅샷을 만드는 중이면 다시 만들지 않는다.
+// 증거 수집만 하므로 ResponseDryRun과 ProtectedContainers의 영향을 받지 않는다.
+// 정책에 대응 액션이 있으면 프로세스가 종료되기 전에 /proc 증거를 먼저 모으고 나머지만 따로 만든다.
+func takeSnapshot(policy *Policy, event utils.Event, message string) {
+	audit := responseAudit{
+		EventTime: event.Time,
+		Policy:    policy.PolicyName,
+		Action:    "snapshot",
+		Container: event.ContainerName,
+		Pid:       event.Pid,
+		Process:   event.ProcessName,
+	}
+	key := fmt.Sprintf("snapshot/%s/%d", event.ContainerName, event.Pid)
+	pendingMu.Lock()
+	if pendingResponses[key] {
+		pendingMu.Unlock()
+		return
+	}
+	pendingResponses[key] = true
+	pendingMu.Unlock()
+
+	now := time.Now()
+	var proc *procEvidence
+	if hasResponseAction(policy.Action) {
+		proc = collectProcEvidence(event.Pid)
+	}
+	go func() {
+		defer func() {
+			pendingMu.Lock()
+			delete(pendingResponses, key)
+			pendingMu.Unlock()
+		}()
+		if proc == nil {
+			proc = collectProcEvidence(event.Pid)
+		}
+		var err error
+		audit.Output, err = writeSnapshot(policy, &event, message, proc, now)
+		finishResponse(&audit, err)
+	}()
+}
+
+// kill, pause_container, stop_container 중 하나가 있는지 확인한다.
+func hasResponseAction(actions string) bool {
+	for _, action := range responseActions {
+		if hasAction(actions, action) {
+			return true
+		}
+	}
+	return false
+}
+
+type snapshotSummary struct {
+	Time    string      `json:"time"`
+	Policy  *Policy     `json:"policy"`
+	Message string      `json:"message"`
+	Event   utils.Event `json:"event"`
+	// 프로세스가 이미 종료되었거나 권한이 없어 수집하지 못한 항목
+	Missing []string `json:"missing,omitempty"`
+}
+
+type snapshotWriter struct {
+	tw      *tar.Writer
+	dir     string
+	modTime time.Time
+	summary snapshotSummary
+	// 처음 실패한 쓰기 오류. 오류가 나면 이후 항목은 쓰지 않는다.
+	err error
+}
+
+// 프로세스의 /proc 내용과 프로세스 트리. 스냅샷 파일에 쓰기 전에 메모리에 모아 둔다.
+type procEvidence struct {
+	files  []evidenceFile
+	missed []string
+}
+
+type evidenceFile struct {
+	name string
+	data []byte
+}
+
+func collectProcEvidence(pid uint32) *procEvidence {
+	e := &procEvidence{}
+	e.collectProc(pid)
+	e.collectProcessTree(pid)
+	return e
+}
+
+func (e *procEvidence) add(name string, data []byte) {
+	e.files = append(e.files, evidenceFile{name: name, data: data})
+}
+
+func (e *procEvidence) missing(item string, err error) {
+	e.missed = append(e.missed, fmt.Sprintf("%s: %v", item, err))
+}
+
+// 스냅샷 파일을 만들고 경로를 돌려준다. 수집하지 못한 항목은 summary.json에 남기고 계속한다.
+func writeSnapshot(policy *Policy, event *utils.Event, message string, proc *procEvidence, now time.Time) (string, error) {
+	dir := utils.LogLocation + snapshotDirName + "/"
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("%s_%s_%s_%d", now.Format("20060102T150405"),
+		unsafeFileChars.ReplaceAllString(policy.PolicyName, "_"), unsafeFileChars.ReplaceAllString(event.ContainerName, "_"), event.Pid)
+	path := dir + name + ".tar.zst"
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	encoder, err := zstd.NewWriter(file)
+	if err != nil {
+		return "", err
+	}
+
+	s := &snapshotWriter{
+		tw:      tar.NewWriter(encoder),
+		dir:     name + "/",
+		modTime: now,
+		summary: snapshotSummary{Time: now.Format(time.RFC3339), Policy: policy, Message: message, Event: *event},
+	}
+	for _, file := range proc.files {
+		s.add(file.name, file.data)
+	}
+	s.summary.Missing = append(s.summary.Missing, proc.missed...)
+	s.collectDockerDiff(event.ContainerName)
+	s.collectEvents(event.ContainerName)
+
+	summary, _ := json.MarshalIndent(s.summary, "", "  ")
+	s.add("summary.json", summary)
+	err = s.err
+	for _, closeErr := range []error{s.tw.Close(), encoder.Close()} {
+		if err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	pruneSnapshots(dir, path)
+	return path, nil
+}
+
+// 스냅샷 디렉터리가 SnapshotMaxSize를 넘으면 오래된 스냅샷부터 지운다. 방금 만든 keep은 지우지 않는다.
+func pruneSnapshots(dir, keep string) {
+	snapshotPruneMu.Lock()
+	defer snapshotPruneMu.Unlock()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	type snapshotFile struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var files []snapshotFile
+	var total int64
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".tar.zst") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, snapshotFile{path: dir + entry.Name(), size: info.Size(), modTime: info.ModTime()})
+		total += info.Size()
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if !files[i].modTime.Equal(files[j].modTime) {
+			return files[i].modTime.Before(files[j].modTime)
+		}
+		return files[i].path < files[j].path
+	})
+	removed := 0
+	for _, file := range files {
+		if total <= SnapshotMaxSize {
+			break
+		}
+		if file.path == keep || os.Remove(file.path) != nil {
+			continue
+		}
+		total -= file.size
+		removed++
+	}
+	if removed > 0 {
+		fmt.Printf("[스냅샷] %s이(가) SnapshotMaxSizeMB(%dMB)를 넘어 오래된 스냅샷 %d개를 지웠습니다.\n", dir, SnapshotMaxSize>>20, removed)
+	}
+}
+
+func (s *snapshotWriter) add(name string, data []byte) {
+	if s.err != nil {
+		return
+	}
+	header := &tar.Header{Name: s.dir + name, Mode: 0600, Size: int64(len(data)), ModTime: s.modTime}
+	if s.err = s.tw.WriteHeader(header); s.err == nil {
+		_, s.err = s.tw.Write(data)
+	}
+}
+
+func (s *snapshotWriter) missing(item string, err error) {
+	s.summary.Missing = append(s.summary.Missing, fmt.Sprintf("%s: %v", item, err))
+}
+
+// /proc/<pid>의 내용. cmdline과 environ의 NUL 구분자는 읽기 쉽게 공백과 줄바꿈으로 바꾼다.
+func (e *procEvidence) collectProc(pid uint32) {
+	if pid == 0 {
+		e.missing("proc", fmt.Errorf("이벤트에 PID가 없습니다"))
+		return
+	}
+	for _, item := range []struct {
+		name string
+		sep  string
+	}{{"cmdline", " "}, {"environ", "\n"}, {"status", ""}, {"maps", ""}} {
+		data, err := utils.ReadProc(pid, item.name)
+		if err != nil {
+			e.missing("proc/"+item.name, err)
+			continue
+		}
+		if item.sep != "" {
+			data = bytes.ReplaceAll(bytes.TrimRight(data, "\x00"), []byte{0}, []byte(item.sep))
+		}
+		e.add("proc/"+item.name, data)
+	}
+
+	if cwd, err := os.Readlink(utils.ProcPath(pid, "cwd")); err != nil {
+		e.missing("proc/cwd", err)
+	} else {
+		e.add("proc/cwd", []byte(cwd+"\n"))
+	}
+
+	if fds, err := os.ReadDir(utils.ProcPath(pid, "fd")); err != nil {
+		e.missing("proc/fds", err)
+	} else {
+		var lines []string
+		for _, fd := range fds {
+			target, err := os.Readlink(utils.ProcPath(pid, "fd/"+fd.Name()))
+			if err != nil {
+				continue
+			}
+			lines = append(lines, fd.Name()+" -> "+target)
+		}
+		sort.Slice(lines, func(i, j int) bool {
+			a, _ := strconv.Atoi(strings.Fields(lines[i])[0])
+			b, _ := strconv.Atoi(strings.Fields(lines[j])[0])
+			return a < b
+		})
+		e.add("proc/fds", []byte(strings.Join(lines, "\n")+"\n"))
+	}
+
+	// 실행 파일이 삭제되었어도 /proc/<pid>/exe로는 읽을 수 있다.
+	exe, err := os.Readlink(utils.ProcPath(pid, "exe"))
+	if err != nil {
+		e.missing("proc/exe", err)
+		return
+	}
+	hash, err := fileSHA256(utils.ProcPath(pid, "exe"))
+	if err != nil {
+		e.missing("proc/exe sha256", err)
+		e.add("proc/exe", []byte(exe+"\n"))
+		return
+	}
+	e.add("proc/exe", []byte(fmt.Sprintf("%s\nsha256 %s\n", exe, hash)))
+}
+
+func fileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// PID 1부터 대상 프로세스까지의 조상과 대상의 자식 프로세스를 들여쓰기로 나타낸다. 대상에는 '*'를 붙인다.
+func (e *procEvidence) collectProcessTree(pid uint32) {
+	if pid == 0 {
+		return
+	}
+	procs, err := utils.ListProcs()
+	if err != nil {
+		e.missing("process_tree", err)
+		return
+	}
+	byPid := make(map[uint32]utils.ProcStat, len(procs))
+	children := make(map[uint32][]uint32)
+	for _, proc := range procs {
+		byPid[proc.Pid] = proc
+		children[proc.Ppid] = append(children[proc.Ppid], proc.Pid)
+	}
+	if _, ok := byPid[pid]; !ok {
+		e.missing("process_tree", fmt.Errorf("PID %d이(가) 이미 종료되었습니다", pid))
+		return
+	}
+
+	var ancestors []uint32
+	for p := byPid[pid].Ppid; p != 0 && len(ancestors) < 64; p = byPid[p].Ppid {
+		if _, ok := byPid[p]; !ok {
+			break
+		}
+		ancestors = append([]uint32{p}, ancestors...)
+	}
+
+	var buf strings.Builder
+	line := func(depth int, p uint32, mark string) {
+		cmdline, _ := utils.ReadProc(p, "cmdline")
+		command := strings.TrimSpace(string(bytes.ReplaceAll(cmdline, []byte{0}, []byte{' '})))
+		if command == "" {
+			command = "[" + byPid[p].Comm + "]"
+		}
+		fmt.Fprintf(&buf, "%s%d %s%s\n", strings.Repeat("  ", depth), p, command, mark)
+	}
+	for depth, p := range ancestors {
+		line(depth, p, "")
+	}
+	var descend func(depth int, p uint32)
+	descend = func(depth int, p uint32) {
+		mark := ""
+		if p == pid {
+			mark = " *"
+		}
+		line(depth, p, mark)
+		kids := children[p]
+		sort.Slice(kids, func(i, j int) bool { return kids[i] < kids[j] })
+		for _, kid := range kids {
+			descend(depth+1, kid)
+		}
+	}
+	descend(len(ancestors), pid)
+	e.add("process_tree.txt", []byte(buf.String()))
+}
+
+func (s *snapshotWriter) collectDockerDiff(containerName string) {
+	if containerName == "" || containerName == "H" {
+		return
+	}
+	changes, err := docker.ContainerDiff(containerName)
+	if err != nil {
+		s.missing("docker_diff", err)
+		return
+	}
+	s.add("docker_diff.txt", []byte(strings.Join(changes, "\n")+"\n"))
+}
+
+func (s *snapshotWriter) collectEvents(containerName string) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, event := range utils.RecentEvents(containerName) {
+		encoder.Encode(event)
+	}
+	s.add("events.jsonl", buf.Bytes())
+}
@@ -0,0 +1,81 @@
+// Copyright Authors of HActiV
+
+package configs
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPruneSnapshotsRemovesOldest(t *testing.T) {
+	tests := []struct {
+		name    string
+		maxSize int64
+		keep    string
+		want    string
+	}{
+		{"within limit", 40, "d.tar.zst", "a.tar.zst,b.tar.zst,c.tar.zst,d.tar.zst"},
+		{"over limit", 25, "d.tar.zst", "c.tar.zst,d.tar.zst"},
+		{"newest alone exceeds limit", 5, "d.tar.zst", "d.tar.zst"},
+		{"keeps the new snapshot even if older", 15, "a.tar.zst", "a.tar.zst"},
+	}
+	defer func(size int64) { SnapshotMaxSize = size }(SnapshotMaxSize)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir() + "/"
+			base := time.Now().Add(-time.Hour)
+			// a가 가장 오래되었고 스냅샷마다 10바이트이다. 스냅샷이 아닌 파일은 세지 않는다.
+			for i, name := range []string{"a.tar.zst", "b.tar.zst", "c.tar.zst", "d.tar.zst", "notes.txt"} {
+				path := dir + name
+				if err := os.WriteFile(path, make([]byte, 10), 0600); err != nil {
+					t.Fatal(err)
+				}
+				modTime := base.Add(time.Duration(i) * time.Minute)
+				if err := os.Chtimes(path, modTime, modTime); err != nil {
+					t.Fatal(err)
+				}
+			}
+			SnapshotMaxSize = tt.maxSize
+			pruneSnapshots(dir, dir+tt.keep)
+
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, entry := range entries {
+				if strings.HasSuffix(entry.Name(), ".tar.zst") {
+					names = append(names, entry.Name())
+				}
+			}
+			sort.Strings(names)
+			if got := strings.Join(names, ","); got != tt.want {
+				t.Errorf("remaining snapshots %s, want %s", got, tt.want)
+			}
+			if _, err := os.Stat(dir + "notes.txt"); err != nil {
+				t.Errorf("non-snapshot file removed: %v", err)
+			}
+		})
+	}
+}
+
+func TestParseSnapshotMaxSize(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int64
+	}{
+		{"512", 512 << 20},
+		{" 1 ", 1 << 20},
+		{"0", defaultSnapshotMaxSizeMB << 20},
+		{"-1", defaultSnapshotMaxSizeMB << 20},
+		{"1GB", defaultSnapshotMaxSizeMB << 20},
+	}
+	for _, tt := range tests {
+		if got := parseSnapshotMaxSize(tt.value); got != tt.want {
+			t.Errorf("parseSnapshotMaxSize(%q) = %d, want %d", tt.value, got, tt.want)
+		}
+	}
+}
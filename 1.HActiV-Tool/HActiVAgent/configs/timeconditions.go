@@ -0,0 +1,274 @@
+// Copyright Authors of HActiV
+
+// configs package for Setting and detection rules
+package configs
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 컴파일된 time_conditions. include 중 하나와 일치하고(include가 없으면 항상) exclude와 일치하지 않을 때 규칙을 평가한다.
+// loc이 nil이면 설정의 Region(HostRegion)을 쓴다.
+type timeSchedule struct {
+	loc     *time.Location
+	include []timeWindow
+	exclude []timeWindow
+}
+
+// time_conditions 항목 하나. 요일, 시간대, 기간을 모두 만족해야 일치하며 지정하지 않은 항목은 검사하지 않는다.
+type timeWindow struct {
+	days   [7]bool
+	ranges []minuteRange
+	dates  []dateRange
+}
+
+// 하루 중 분 단위 시간대. start > end이면 자정을 넘겨 다음 날 end까지이며, 시작한 요일의 시간대로 본다.
+type minuteRange struct {
+	start, end int
+}
+
+// 절대 기간 [start, end)
+type dateRange struct {
+	start, end time.Time
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"Sunday": time.Sunday, "Monday": time.Monday, "Tuesday": time.Tuesday, "Wednesday": time.Wednesday,
+	"Thursday": time.Thursday, "Friday": time.Friday, "Saturday": time.Saturday,
+}
+
+// 기간에 쓸 수 있는 형식. 날짜만 쓰면 start는 그날 0시, end는 그날 끝(다음 날 0시)이다.
+var dateLayouts = []string{"2006-01-02 15:04", "2006-01-02T15:04", "2006-01-02"}
+
+// 규칙의 timezone. 비어 있으면 nil을 돌려주며 설정의 Region을 쓴다.
+func loadTimezone(timezone string) (*time.Location, error) {
+	if timezone == "" {
+		return nil, nil
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("시간대 '%s'을(를) 찾을 수 없습니다. 'Asia/Seoul' 같은 IANA 시간대 이름을 사용하세요.", timezone)
+	}
+	return loc, nil
+}
+
+func compileTimeConditions(timeConditions []TimeCondition, loc *time.Location) (*timeSchedule, error) {
+	if len(timeConditions) == 0 {
+		if loc == nil {
+			return nil, nil
+		}
+		return &timeSchedule{loc: loc}, nil
+	}
+
+	dateLoc := loc
+	if dateLoc == nil {
+		dateLoc = hostLocation()
+	}
+	schedule := &timeSchedule{loc: loc}
+	for _, timeCondition := range timeConditions {
+		window, err := compileTimeWindow(timeCondition, dateLoc)
+		if err != nil {
+			return nil, err
+		}
+		if timeCondition.Exclude {
+			schedule.exclude = append(schedule.exclude, window)
+		} else {
+			schedule.include = append(schedule.include, window)
+		}
+	}
+	return schedule, nil
+}
+
+func compileTimeWindow(timeCondition TimeCondition, loc *time.Location) (timeWindow, error) {
+	var window timeWindow
+	days := timeCondition.Days
+	if timeCondition.Day != "" {
+		days = append([]string{timeCondition.Day}, days...)
+	}
+	if len(days) == 0 && len(timeCondition.TimeRanges) == 0 && len(timeCondition.Dates) == 0 {
+		return window, fmt.Errorf("time_conditions 항목에 day, days, time_ranges, dates 중 하나 이상을 지정하세요.")
+	}
+	for _, day := range days {
+		weekday, ok := weekdayNames[day]
+		if !ok {
+			return window, fmt.Errorf("%s란 요일은 없습니다.", day)
+		}
+		window.days[weekday] = true
+	}
+	if len(days) == 0 {
+		window.days = [7]bool{true, true, true, true, true, true, true}
+	}
+
+	label := strings.Join(days, ", ")
+	if label == "" {
+		label = "매일"
+	}
+	for _, timeRange := range timeCondition.TimeRanges {
+		start, ok := parseClock(timeRange.Start, false)
+		if !ok {
+			return window, fmt.Errorf("%s에서 필드 '%s'의 시간 형식이 올바르지 않습니다. 예시: 00:00", label, timeRange.Start)
+		}
+		end, ok := parseClock(timeRange.End, true)
+		if !ok {
+			return window, fmt.Errorf("%s에서 필드 '%s'의 시간 형식이 올바르지 않습니다. 예시: 00:00", label, timeRange.End)
+		}
+		if start == end {
+			return window, fmt.Errorf("%s에서 시작 시간(%s)과 종료 시간(%s)이 같습니다.", label, timeRange.Start, timeRange.End)
+		}
+		window.ranges = append(window.ranges, minuteRange{start: start, end: end})
+	}
+
+	for _, dates := range timeCondition.Dates {
+		start, _, err := parseDate(dates.Start, loc)
+		if err != nil {
+			return window, err
+		}
+		end, dateOnly, err := parseDate(dates.End, loc)
+		if err != nil {
+			return window, err
+		}
+		if dateOnly {
+			end = end.AddDate(0, 0, 1)
+		}
+		if !start.Before(end) {
+			return window, fmt.Errorf("기간의 시작(%s)은 끝(%s) 이후가 될 수 없습니다.", dates.Start, dates.End)
+		}
+		window.dates = append(window.dates, dateRange{start: start, end: end})
+	}
+	return window, nil
+}
+
+// "15:04" 형식의 시각을 0시부터의 분으로 바꾼다. 종료 시간에는 "24:00"도 쓸 수 있다.
+func parseClock(clock string, end bool) (int, bool) {
+	if end && clock == "24:00" {
+		return 24 * 60, true
+	}
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, false
+	}
+	return t.Hour()*60 + t.Minute(), true
+}
+
+func parseDate(value string, loc *time.Location) (time.Time, bool, error) {
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, layout == "2006-01-02", nil
+		}
+	}
+	return time.Time{}, false, fmt.Errorf("기간 '%s'의 형식이 올바르지 않습니다. 예시: 2024-12-24, 2024-12-24 18:00", value)
+}
+
+// 이벤트 시각(RFC3339)이 규칙을 평가할 시간인지 확인한다. time_conditions가 없으면 항상 참이다.
+func (s *timeSchedule) match(eventTime string) bool {
+	if s == nil || (len(s.include) == 0 && len(s.exclude) == 0) {
+		return true
+	}
+	t, err := time.Parse(time.RFC3339, eventTime)
+	if err != nil {
+		t = time.Now()
+	}
+	loc := s.loc
+	if loc == nil {
+		loc = hostLocation()
+	}
+	t = t.In(loc)
+
+	for _, window := range s.exclude {
+		if window.match(t) {
+			return false
+		}
+	}
+	if len(s.include) == 0 {
+		return true
+	}
+	for _, window := range s.include {
+		if window.match(t) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w timeWindow) match(t time.Time) bool {
+	if len(w.dates) > 0 {
+		inDates := false
+		for _, dates := range w.dates {
+			if !t.Before(dates.start) && t.Before(dates.end) {
+				inDates = true
+				break
+			}
+		}
+		if !inDates {
+			return false
+		}
+	}
+
+	weekday := t.Weekday()
+	if len(w.ranges) == 0 {
+		return w.days[weekday]
+	}
+	minute := t.Hour()*60 + t.Minute()
+	yesterday := (weekday + 6) % 7
+	for _, r := range w.ranges {
+		switch {
+		case r.start < r.end:
+			if w.days[weekday] && minute >= r.start && minute <= r.end {
+				return true
+			}
+		case w.days[weekday] && minute >= r.start, w.days[yesterday] && minute <= r.end:
+			return true
+		}
+	}
+	return false
+}
+
+var hostLocations sync.Map
+
+// 설정의 Region에 해당하는 시간대. 이벤트마다 시간대 파일을 읽지 않도록 이름별로 한 번만 불러온다.
+func hostLocation() *time.Location {
+	if loc, ok := hostLocations.Load(HostRegion); ok {
+		return loc.(*time.Location)
+	}
+	loc, err := time.LoadLocation(HostRegion)
+	if err != nil {
+		loc = time.UTC
+	}
+	hostLocations.Store(HostRegion, loc)
+	return loc
+}
+
+func describeTimeConditions(timeConditions []TimeCondition, timezone string) string {
+	var parts []string
+	for _, timeCondition := range timeConditions {
+		days := timeCondition.Days
+		if timeCondition.Day != "" {
+			days = append([]string{timeCondition.Day}, days...)
+		}
+		var items []string
+		if len(days) > 0 {
+			items = append(items, strings.Join(days, ", "))
+		}
+		for _, timeRange := range timeCondition.TimeRanges {
+			items = append(items, timeRange.Start+"-"+timeRange.End)
+		}
+		for _, dates := range timeCondition.Dates {
+			items = append(items, dates.Start+" ~ "+dates.End)
+		}
+		part := strings.Join(items, " ")
+		if timeCondition.Exclude {
+			part = "제외 " + part
+		}
+		parts = append(parts, part)
+	}
+	if len(parts) == 0 {
+		parts = append(parts, "항상")
+	}
+	if timezone != "" {
+		parts = append(parts, "("+timezone+")")
+	}
+	return strings.Join(parts, " | ")
+}
@@ -0,0 +1,334 @@
+// Copyright Authors of HActiV
+
+// configs package for Setting and detection rules
+package configs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// YAML 규칙 파일(<도구>rule.yaml). 규칙 항목은 JSON 규칙 파일과 같고, 조건에서 이름으로 참조하는
+// lists와 macros를 함께 정의할 수 있다. 같은 디렉터리의 macros.yaml에 있는 lists와 macros는
+// 모든 YAML 규칙 파일에서 쓸 수 있으며, 이름이 같으면 규칙 파일의 정의를 쓴다.
+//
+//	lists:
+//	  shell_binaries: [sh, bash, dash, zsh]
+//	macros:
+//	  spawned_shell: "%ProcessName% in shell_binaries"
+//	rules:
+//	  - event_name: Shell_in_container
+//	    usage: true
+//	    condition: spawned_shell and not %ContainerName% in [hactiv]
+//	    action: print alert
+//
+// '%'로 시작하는 조건은 YAML 문법상 따옴표로 감싸야 한다.
+const sharedMacrosFileName = "macros.yaml"
+
+var definitionName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// 조건에서 참조하는 lists와 macros. 매크로는 처음 참조될 때 컴파일해 다른 규칙과 함께 쓴다.
+type conditionEnv struct {
+	lists     map[string][]string
+	macros    map[string]string
+	compiled  map[string]conditionNode
+	compiling map[string]bool
+}
+
+// lists 또는 macros 항목 하나의 오류
+type definitionError struct {
+	name string
+	msg  string
+}
+
+func (e definitionError) Error() string {
+	return fmt.Sprintf("'%s': %s", e.name, e.msg)
+}
+
+// 목록 안에서 다른 목록의 이름을 쓰면 그 목록의 항목으로 펼친다. 잘못된 정의는 빼고 나머지로 env를 만든다.
+func newConditionEnv(lists map[string][]string, macros map[string]string) (*conditionEnv, []definitionError) {
+	env := &conditionEnv{
+		lists:     make(map[string][]string, len(lists)),
+		macros:    make(map[string]string, len(macros)),
+		compiled:  make(map[string]conditionNode),
+		compiling: make(map[string]bool),
+	}
+	var errs []definitionError
+	valid := func(name string) bool {
+		switch {
+		case !definitionName.MatchString(name):
+			errs = append(errs, definitionError{name, "이름은 영문자, 숫자, '_'만 쓸 수 있고 숫자로 시작할 수 없습니다."})
+		case contains([]string{"and", "or", "not"}, strings.ToLower(name)):
+			errs = append(errs, definitionError{name, "and, or, not은 이름으로 쓸 수 없습니다."})
+		default:
+			return true
+		}
+		return false
+	}
+
+	var expand func(name string, seen []string) ([]string, error)
+	expand = func(name string, seen []string) ([]string, error) {
+		if contains(seen, name) {
+			return nil, fmt.Errorf("목록이 서로를 참조합니다: %s", strings.Join(append(seen, name), " -> "))
+		}
+		var items []string
+		for _, item := range lists[name] {
+			if _, ok := lists[item]; ok {
+				nested, err := expand(item, append(seen, name))
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, nested...)
+				continue
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	}
+	for _, name := range sortedNames(lists) {
+		if !valid(name) {
+			continue
+		}
+		items, err := expand(name, nil)
+		if err != nil {
+			errs = append(errs, definitionError{name, err.Error()})
+			continue
+		}
+		env.lists[name] = items
+	}
+	for _, name := range sortedNames(macros) {
+		if !valid(name) {
+			continue
+		}
+		if _, ok := lists[name]; ok {
+			errs = append(errs, definitionError{name, "같은 이름의 목록이 있습니다."})
+			continue
+		}
+		env.macros[name] = strings.TrimSpace(macros[name])
+	}
+	return env, errs
+}
+
+func sortedNames[V any](m map[string]V) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (env *conditionEnv) macro(name string, fieldTypes map[string]string) (conditionNode, error) {
+	if node, ok := env.compiled[name]; ok {
+		return node, nil
+	}
+	if env.compiling[name] {
+		return nil, fmt.Errorf("매크로 참조가 순환합니다.")
+	}
+	env.compiling[name] = true
+	defer delete(env.compiling, name)
+
+	node, err := parseCondition(env.macros[name], fieldTypes, env)
+	if err != nil {
+		return nil, err
+	}
+	env.compiled[name] = node
+	return node, nil
+}
+
+func isYAMLRuleFile(filename string) bool {
+	return strings.HasSuffix(filename, ".yaml")
+}
+
+// YAML 파일 안의 문제 하나와 그 줄 번호
+type yamlIssue struct {
+	line int
+	msg  string
+}
+
+// YAML 규칙 파일의 내용. lines는 lists와 macros 정의("lists.이름", "macros.이름")가 있는 줄이다.
+type yamlRuleFile struct {
+	lists  map[string][]string
+	macros map[string]string
+	rules  []yamlRule
+	lines  map[string]int
+}
+
+type yamlRule struct {
+	rule Rule
+	node *yaml.Node
+}
+
+// YAML 규칙 파일을 읽는다. strict이면 rules lint와 같이 알 수 없는 항목도 문제로 돌려준다.
+// rulesAllowed가 false이면(macros.yaml) rules 항목을 허용하지 않는다.
+func parseYAMLRuleFile(data []byte, strict, rulesAllowed bool) (*yamlRuleFile, []yamlIssue) {
+	file := &yamlRuleFile{lines: make(map[string]int)}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return file, []yamlIssue{{line: yamlErrorLine(err), msg: err.Error()}}
+	}
+	if len(doc.Content) == 0 {
+		return file, nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return file, []yamlIssue{{line: root.Line, msg: "YAML 규칙 파일의 최상위는 lists, macros, rules를 가진 객체여야 합니다."}}
+	}
+
+	var issues []yamlIssue
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		switch {
+		case key.Value == "lists":
+			if err := value.Decode(&file.lists); err != nil {
+				issues = append(issues, yamlIssue{line: value.Line, msg: "lists는 이름별 문자열 목록이어야 합니다: " + err.Error()})
+			}
+			file.recordLines("lists", value)
+		case key.Value == "macros":
+			if err := value.Decode(&file.macros); err != nil {
+				issues = append(issues, yamlIssue{line: value.Line, msg: "macros는 이름별 조건 문자열이어야 합니다: " + err.Error()})
+			}
+			file.recordLines("macros", value)
+		case key.Value == "rules" && rulesAllowed:
+			if value.Kind != yaml.SequenceNode {
+				issues = append(issues, yamlIssue{line: value.Line, msg: "rules는 규칙 객체의 목록이어야 합니다."})
+				continue
+			}
+			for _, item := range value.Content {
+				rule, issue := decodeYAMLRule(item, strict)
+				if issue != nil {
+					issues = append(issues, *issue)
+					continue
+				}
+				file.rules = append(file.rules, yamlRule{rule: rule, node: item})
+			}
+		default:
+			issues = append(issues, yamlIssue{line: key.Line, msg: fmt.Sprintf("알 수 없는 항목 '%s'", key.Value)})
+		}
+	}
+	return file, issues
+}
+
+func (f *yamlRuleFile) recordLines(section string, node *yaml.Node) {
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		f.lines[section+"."+node.Content[i].Value] = node.Content[i].Line
+	}
+}
+
+func yamlErrorLine(err error) int {
+	var line int
+	if _, scanErr := fmt.Sscanf(err.Error(), "yaml: line %d:", &line); scanErr == nil {
+		return line
+	}
+	return 1
+}
+
+// 규칙 항목은 JSON으로 바꿔 Rule로 디코딩한다. JSON 규칙 파일과 같은 키와 형식 검사를 쓰기 위해서이다.
+func decodeYAMLRule(item *yaml.Node, strict bool) (Rule, *yamlIssue) {
+	var rule Rule
+	var value interface{}
+	if err := item.Decode(&value); err != nil {
+		return rule, &yamlIssue{line: item.Line, msg: err.Error()}
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return rule, &yamlIssue{line: item.Line, msg: err.Error()}
+	}
+	if strict {
+		err = decodeStrict(raw, &rule)
+	} else {
+		err = json.Unmarshal(raw, &rule)
+	}
+	if err == nil {
+		return rule, nil
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &typeErr):
+		key := strings.SplitN(typeErr.Field, ".", 2)[0]
+		return rule, &yamlIssue{line: yamlKeyLine(item, key), msg: fmt.Sprintf("'%s'의 값 형식이 올바르지 않습니다: %s 대신 %s", typeErr.Field, typeErr.Type, typeErr.Value)}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		key := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return rule, &yamlIssue{line: yamlKeyLine(item, key), msg: fmt.Sprintf("알 수 없는 항목 '%s'", key)}
+	default:
+		return rule, &yamlIssue{line: item.Line, msg: err.Error()}
+	}
+}
+
+// 규칙 객체에서 최상위 키가 있는 줄. 찾지 못하면 객체가 시작하는 줄을 돌려준다.
+func yamlKeyLine(item *yaml.Node, key string) int {
+	if item.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(item.Content); i += 2 {
+			if item.Content[i].Value == key {
+				return item.Content[i].Line
+			}
+		}
+	}
+	return item.Line
+}
+
+// YAML 규칙 파일과 같은 디렉터리의 macros.yaml을 읽어 규칙과 조건 env를 만든다.
+func readYAMLRuleFile(filename string) ([]Rule, *conditionEnv, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	file, issues := parseYAMLRuleFile(data, false, true)
+	if len(issues) > 0 {
+		return nil, nil, fmt.Errorf("%d번째 줄: %s", issues[0].line, issues[0].msg)
+	}
+	shared, err := readSharedMacros(filepath.Dir(filename))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	env, errs := newConditionEnv(mergeDefinitions(shared.lists, file.lists), mergeDefinitions(shared.macros, file.macros))
+	if len(errs) > 0 {
+		return nil, nil, errs[0]
+	}
+	rules := make([]Rule, len(file.rules))
+	for i, r := range file.rules {
+		rules[i] = r.rule
+	}
+	return rules, env, nil
+}
+
+// 디렉터리의 macros.yaml. 파일이 없으면 빈 정의를 돌려준다.
+func readSharedMacros(dir string) (*yamlRuleFile, error) {
+	filename := filepath.Join(dir, sharedMacrosFileName)
+	data, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return &yamlRuleFile{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	file, issues := parseYAMLRuleFile(data, false, false)
+	if len(issues) > 0 {
+		return nil, fmt.Errorf("%s: %d번째 줄: %s", sharedMacrosFileName, issues[0].line, issues[0].msg)
+	}
+	return file, nil
+}
+
+func mergeDefinitions[V any](shared, local map[string]V) map[string]V {
+	merged := make(map[string]V, len(shared)+len(local))
+	for name, v := range shared {
+		merged[name] = v
+	}
+	for name, v := range local {
+		merged[name] = v
+	}
+	return merged
+}
@@ -0,0 +1,69 @@
+// Copyright Authors of HActiV
+
+// docker package for docker information
+package docker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	containertypes "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+)
+
+var (
+	controlOnce   sync.Once
+	controlClient *client.Client
+	controlErr    error
+)
+
+// 대응 액션에서 쓰는 Docker 클라이언트. 처음 사용할 때 한 번만 만든다.
+func getControlClient() (*client.Client, error) {
+	controlOnce.Do(func() {
+		controlClient, controlErr = client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	})
+	return controlClient, controlErr
+}
+
+// 컨테이너를 일시 중지한다. name은 컨테이너 이름 또는 ID이다.
+func PauseContainer(name string) error {
+	cli, err := getControlClient()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return cli.ContainerPause(ctx, name)
+}
+
+// 컨테이너를 중지한다. timeout 동안 종료되지 않으면 Docker가 SIGKILL을 보낸다.
+func StopContainer(name string, timeout time.Duration) error {
+	cli, err := getControlClient()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout+30*time.Second)
+	defer cancel()
+	seconds := int(timeout / time.Second)
+	return cli.ContainerStop(ctx, name, containertypes.StopOptions{Timeout: &seconds})
+}
+
+// 컨테이너 파일시스템의 변경 목록. docker diff와 같이 "C /etc/passwd" 형식으로 돌려준다.
+func ContainerDiff(name string) ([]string, error) {
+	cli, err := getControlClient()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	changes, err := cli.ContainerDiff(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	lines := make([]string, 0, len(changes))
+	for _, change := range changes {
+		lines = append(lines, change.Kind.String()+" "+change.Path)
+	}
+	return lines, nil
+}
@@ -0,0 +1,232 @@
+// Copyright Authors of HActiV
+
+// utils package for helping other package
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// 서버로 보내고 로그에 남기는 이벤트. 이벤트 종류(event_type)마다 구조체가 하나씩 있으며,
+// 모니터 이벤트는 NewApiData로, 매트릭은 NewContainerMetrics, NewHostMetrics로 만든다.
+// DataSend, RuleSend, DualLogger가 모두 이 타입의 JSON을 그대로 쓰므로 스키마는 여기 한 곳에만 있다.
+type ApiData interface {
+	apiData()
+}
+
+type BasicApiData struct {
+	EventType     string `json:"event_type"`
+	Time          string `json:"timestamp"`
+	ContainerName string `json:"container_name"`
+	Uid           uint32 `json:"uid"`
+	Gid           uint32 `json:"gid"`
+	Pid           uint32 `json:"pid"`
+	Ppid          uint32 `json:"ppid"`
+}
+
+type ExecveApiData struct {
+	BasicApiData
+	Command     string `json:"command"`
+	ProcessName string `json:"process_name"`
+	Args        string `json:"arguments"`
+}
+
+type OpenApiData struct {
+	BasicApiData
+	Command     string `json:"command"`
+	Filename    string `json:"filename"`
+	ReturnValue int32  `json:"status"`
+	ProcessName string `json:"process_name"`
+}
+
+type Node struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+}
+
+type Link struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+}
+
+type Path struct {
+	Nodes []Node `json:"nodes"`
+	Links []Link `json:"links"`
+}
+
+type NetworkApiData struct {
+	EventType     string `json:"event_type"`
+	Time          string `json:"timestamp"`
+	ContainerName string `json:"container_name"`
+	SrcIp         string `json:"src_ip"`
+	SrcIpLabel    string `json:"src_ip_label"`
+	DstIp         string `json:"dst_ip"`
+	DstIpLabel    string `json:"dst_ip_label"`
+	Protocol      string `json:"protocol"`
+	PacketSize    int    `json:"packet_size"`
+	TotalPackets  int    `json:"total_packets"`
+	TotalSize     int    `json:"total_size"`
+	Path          string `json:"path"`
+	Direction     string `json:"direction"`
+	Method        string `json:"http_method,omitempty"`
+	Host          string `json:"http_host,omitempty"`
+	URL           string `json:"http_url,omitempty"`
+	Parameters    string `json:"http_parameters,omitempty"`
+}
+
+type MemoryApiData struct {
+	BasicApiData
+	ProcessName  string `json:"process_name"`
+	Syscall      string `json:"syscall"`
+	Prot         string `json:"prot"`
+	Prottemp     uint32 `json:"prottemp"`
+	MappingType  string `json:"mapping_type"`
+	StartAddress uint64 `json:"start_address"`
+	EndAddress   uint64 `json:"end_address"`
+	Size         uint64 `json:"size"`
+}
+
+type DeleteApiData struct {
+	BasicApiData
+	ProcessName string `json:"process_name"`
+	Filename    string `json:"filename"`
+}
+
+type LogAccessApiData struct {
+	BasicApiData
+	ProcessName string `json:"process_name"`
+	Filename    string `json:"filename"`
+	FileSize    int64  `json:"file_size"`
+	MountStatus string `json:"mount_status"`
+}
+
+type ContainerMetricsApiData struct {
+	EventType   string  `json:"event_type"`
+	Time        string  `json:"timestamp"`
+	Name        string  `json:"container_name"`
+	CpuUsage    float64 `json:"cpu_usage"`
+	MemoryUsage float64 `json:"memory_usage"`
+	DiskUsage   float64 `json:"disk_usage"`
+	RxBytes     uint64  `json:"rx_bytes"`
+	TxBytes     uint64  `json:"tx_bytes"`
+}
+
+type HostMetricsApiData struct {
+	EventType   string  `json:"event_type"`
+	Time        string  `json:"timestamp"`
+	CpuUsage    float64 `json:"cpu_usage"`
+	MemoryUsage float64 `json:"memory_usage"`
+	DiskUsage   float64 `json:"disk_usage"`
+	CpuCores    int     `json:"cpu_cores"`
+}
+
+func (ExecveApiData) apiData()           {}
+func (OpenApiData) apiData()             {}
+func (NetworkApiData) apiData()          {}
+func (MemoryApiData) apiData()           {}
+func (DeleteApiData) apiData()           {}
+func (LogAccessApiData) apiData()        {}
+func (ContainerMetricsApiData) apiData() {}
+func (HostMetricsApiData) apiData()      {}
+
+// 모니터 이벤트를 event.Tool에 맞는 ApiData로 바꾼다.
+func NewApiData(event Event) (ApiData, error) {
+	basic := BasicApiData{
+		EventType:     event.Tool,
+		Time:          event.Time,
+		ContainerName: event.ContainerName,
+		Uid:           event.Uid,
+		Gid:           event.Gid,
+		Pid:           event.Pid,
+		Ppid:          event.Ppid,
+	}
+	switch event.Tool {
+	case "Systemcall":
+		return ExecveApiData{
+			BasicApiData: basic,
+			Command:      event.Filename,
+			ProcessName:  event.ProcessName,
+			Args:         strings.Replace(event.Args, "--color=auto", "", 1),
+		}, nil
+	case "file_open":
+		return OpenApiData{
+			BasicApiData: basic,
+			Command:      "open",
+			Filename:     event.Filename,
+			ReturnValue:  event.ReturnValue,
+			ProcessName:  event.ProcessName,
+		}, nil
+	case "delete":
+		return DeleteApiData{
+			BasicApiData: basic,
+			ProcessName:  event.ProcessName,
+			Filename:     event.Filename,
+		}, nil
+	case "Memory":
+		return MemoryApiData{
+			BasicApiData: basic,
+			ProcessName:  event.ProcessName,
+			Syscall:      event.Syscall,
+			StartAddress: event.StartAddr,
+			EndAddress:   event.EndAddr,
+			Size:         event.Size,
+			Prottemp:     event.Prottemp,
+			Prot:         event.Prot,
+			MappingType:  event.MappingType,
+		}, nil
+	case "Network_traffic":
+		path, err := json.Marshal(Path{
+			Nodes: []Node{{ID: event.SrcIp, Type: event.SrcIpLabel}, {ID: event.DstIp, Type: event.DstIpLabel}},
+			Links: []Link{{Source: event.SrcIp, Target: event.DstIp}},
+		})
+		if err != nil {
+			return nil, err
+		}
+		return NetworkApiData{
+			EventType:     event.Tool,
+			Time:          event.Time,
+			ContainerName: event.ContainerName,
+			SrcIp:         event.SrcIp,
+			SrcIpLabel:    event.SrcIpLabel,
+			DstIp:         event.DstIp,
+			DstIpLabel:    event.DstIpLabel,
+			Protocol:      event.Protocol,
+			PacketSize:    event.PacketSize,
+			TotalPackets:  event.PacketCount,
+			TotalSize:     event.TotalSize,
+			Path:          string(path),
+			Direction:     event.Direction,
+			Method:        event.Method,
+			Host:          event.Host,
+			URL:           event.URL,
+			Parameters:    event.Parameters,
+		}, nil
+	}
+	return nil, fmt.Errorf("이벤트 종류 '%s'을(를) 알 수 없습니다", event.Tool)
+}
+
+func NewContainerMetrics(time, name string, cpuUsage, memoryUsage, diskUsage float64, rxBytes, txBytes uint64) ContainerMetricsApiData {
+	return ContainerMetricsApiData{
+		EventType:   "ContainerMetrics",
+		Time:        time,
+		Name:        name,
+		CpuUsage:    cpuUsage,
+		MemoryUsage: memoryUsage,
+		DiskUsage:   diskUsage,
+		RxBytes:     rxBytes,
+		TxBytes:     txBytes,
+	}
+}
+
+func NewHostMetrics(time string, cpuUsage, memoryUsage, diskUsage float64, cpuCores int) HostMetricsApiData {
+	return HostMetricsApiData{
+		EventType:   "HostMetrics",
+		Time:        time,
+		CpuUsage:    cpuUsage,
+		MemoryUsage: memoryUsage,
+		DiskUsage:   diskUsage,
+		CpuCores:    cpuCores,
+	}
+}
@@ -18,8 +18,10 @@ import (
 )
 
 type ContainerInfo struct {
-	ID   string
-	Name string
+	ID     string
+	Name   string
+	Image  string
+	Labels map[string]string
 }
 
 func GetNamespaceInode(pid uint32) (uint64, error) {
@@ -79,7 +81,7 @@ func GetAllContainerNamespaces() (map[uint64]ContainerInfo, error) {
 			fmt.Printf("failed to get namespace inode for PID %d: %s\n", pid, err)
 			continue
 		}
-		containerNamespaces[inode] = ContainerInfo{ID: container.ID, Name: strings.Replace(container.Names[0], "/", "", 1)}
+		containerNamespaces[inode] = ContainerInfo{ID: container.ID, Name: strings.Replace(container.Names[0], "/", "", 1), Image: container.Image, Labels: container.Labels}
 
 	}
 	if HostMonitoring {
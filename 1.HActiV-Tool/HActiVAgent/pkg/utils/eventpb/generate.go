@@ -0,0 +1,5 @@
+// Copyright Authors of HActiV
+
+package eventpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative event.proto
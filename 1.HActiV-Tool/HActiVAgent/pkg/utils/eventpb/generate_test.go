@@ -0,0 +1,29 @@
+// Copyright Authors of HActiV
+
+package eventpb
+
+import (
+	"os"
+	"testing"
+)
+
+// 서버의 생성 코드가 이 디렉터리의 event.proto에서 만든 것과 같은지 확인한다. 저장소 밖에서 빌드하면 건너뛴다.
+func TestServerCodeGeneratedFromSameProto(t *testing.T) {
+	const serverDir = "../../../../server/eventpb/"
+	if _, err := os.Stat(serverDir); err != nil {
+		t.Skipf("server/eventpb가 없습니다: %v", err)
+	}
+	for _, name := range []string{"event.pb.go", "event_grpc.pb.go"} {
+		agent, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		server, err := os.ReadFile(serverDir + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(agent) != string(server) {
+			t.Errorf("server/eventpb/%s가 event.proto에서 만든 코드와 다릅니다. 두 디렉터리에서 go generate를 실행하세요.", name)
+		}
+	}
+}
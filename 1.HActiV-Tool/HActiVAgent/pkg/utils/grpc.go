@@ -0,0 +1,625 @@
+// Copyright Authors of HActiV
+
+// utils package for helping other package
+package utils
+
+import (
+	"HActiV/pkg/utils/eventpb"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// gRPC 싱크. GrpcAddr이 있으면 DataSink와 관계없이 Ingest.Stream 하나를 열어 두고, 서버가 같은 스트림으로 보낸
+// 규칙 업데이트를 RuleUpdateHandler로 넘긴다. DataSink가 grpc이면 이벤트도 protobuf(eventpb)로 바꿔 이 스트림으로 보내며,
+// 서버는 배치마다 Ack를 보낸다.
+//
+// 이벤트는 큐에 넣고 백그라운드에서 배치로 보낸다. 연결이 끊기면 간격을 두 배씩 늘리며 다시 연결하고 그동안 이벤트는 큐에 쌓인다.
+// 스풀이 있으면 Ack를 받지 못한 채 연결이 끊긴 배치와 서버가 429, 5xx로 저장하지 못한 이벤트를 LogLocation/spool/grpc에
+// 보관하고(sender와 같은 형식으로 한 줄에 protojson 이벤트 하나), 다시 연결되면 보관한 순서대로 먼저 보낸다.
+// 스풀이 없으면 그 이벤트는 보내지 못한 것으로 센다.
+type grpcSink struct {
+	addr    string
+	setting TransportSetting
+	conn    *grpc.ClientConn
+	queue   chan *eventpb.Event
+	stop    chan struct{}
+	once    sync.Once
+	done    chan struct{}
+	spool   *spool
+	// DataSink가 grpc이면 true. false이면 규칙 업데이트만 받는다.
+	events bool
+
+	// 마지막으로 적용한 규칙 버전. 다시 연결할 때 Hello로 알린다.
+	rulesVersion atomic.Value
+
+	// 스풀에서 읽어 보내는 배치. 스트림 고루틴에서만 쓴다. 서버가 일부를 저장하지 못하면 nextReplay에 남은 이벤트를
+	// 다시 보내고, 실패할 때마다 replayDelay를 두 배로 늘린다. 모두 처리된 뒤에 스풀 커서를 옮긴다.
+	replaying   *grpcReplay
+	nextReplay  time.Time
+	replayDelay time.Duration
+
+	sent    atomic.Uint64
+	dropped atomic.Uint64
+	failed  atomic.Uint64
+	spooled atomic.Uint64
+}
+
+// 스트림으로 보내고 Ack를 기다리는 배치. replay이면 스풀에서 읽은 배치이다.
+type grpcBatch struct {
+	events []*eventpb.Event
+	replay bool
+}
+
+// 스풀에서 읽었지만 아직 커서를 옮기지 않은 배치. seq는 Ack를 기다리는 배치 번호이며 0이면 보내야 한다.
+type grpcReplay struct {
+	events []*eventpb.Event
+	cursor spooledBatch
+	seq    uint64
+}
+
+// 서버가 보낸 규칙 파일을 적용한다. main에서 configs.ApplyRuleUpdate로 정한다.
+var RuleUpdateHandler func(version string, files map[string][]byte) error
+
+var grpcStream *grpcSink
+
+// 연결은 스트림을 열 때 맺으므로 서버가 아직 떠 있지 않아도 실패하지 않고, run이 다시 연결한다.
+// 오류는 TLS 설정을 읽을 수 없을 때만 돌려준다.
+func newGrpcSink(setting TransportSetting) (*grpcSink, error) {
+	creds := insecure.NewCredentials()
+	if setting.GrpcTLS {
+		config := &tls.Config{MinVersion: tls.VersionTLS12}
+		if setting.GrpcCAFile != "" {
+			pem, err := os.ReadFile(setting.GrpcCAFile)
+			if err != nil {
+				return nil, err
+			}
+			config.RootCAs = x509.NewCertPool()
+			if !config.RootCAs.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("%s에서 CA 인증서를 읽을 수 없습니다", setting.GrpcCAFile)
+			}
+		}
+		creds = credentials.NewTLS(config)
+	} else if setting.GrpcInsecureRuleUpdates {
+		fmt.Println("[전송] grpc: TLS 없이 서버의 규칙 업데이트를 받습니다. 신뢰할 수 있는 망에서만 사용하세요.")
+	}
+	conn, err := grpc.NewClient(setting.GrpcAddr, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, err
+	}
+	g := &grpcSink{
+		addr:        setting.GrpcAddr,
+		setting:     setting,
+		conn:        conn,
+		queue:       make(chan *eventpb.Event, setting.QueueSize),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+		replayDelay: retryBaseDelay,
+		events:      setting.DataSink == "grpc",
+	}
+	g.rulesVersion.Store("")
+	if g.events && setting.SpoolMaxSize > 0 {
+		if g.spool, err = openSpool(LogLocation+"spool/grpc", setting.SpoolMaxSize); err != nil {
+			fmt.Printf("[전송] grpc: 스풀을 열 수 없어 보내지 못한 이벤트를 보관하지 않습니다: %v\n", err)
+		} else if g.spool.pending() {
+			fmt.Printf("[전송] grpc: 이전에 보내지 못한 이벤트 %d bytes를 다시 보냅니다.\n", g.spool.pendingBytes.Load())
+		}
+	}
+	go g.run()
+	go report("grpc", g.stats, g.done)
+	return g, nil
+}
+
+// 스트림이 끝나면 간격을 두 배씩 늘리며 다시 연결한다. 한동안 연결되어 있었으면 간격을 처음으로 되돌린다.
+func (g *grpcSink) run() {
+	defer close(g.done)
+	defer g.conn.Close()
+	delay := retryBaseDelay
+	for {
+		started := time.Now()
+		err := g.stream()
+		select {
+		case <-g.stop:
+			return
+		default:
+		}
+		if time.Since(started) > retryMaxDelay {
+			delay = retryBaseDelay
+		}
+		if delay == retryBaseDelay {
+			fmt.Printf("[전송] grpc: %s 스트림이 끊겨 다시 연결합니다: %v\n", g.addr, err)
+		}
+		select {
+		case <-time.After(delay):
+		case <-g.stop:
+			return
+		}
+		delay = min(delay*2, retryMaxDelay)
+	}
+}
+
+// 스트림 하나를 열어 끊길 때까지 이벤트를 보낸다. 종료할 때는 큐에 남은 이벤트를 보내고 마지막 Ack까지 기다린다.
+// 스풀에 남은 이벤트가 있으면 큐의 이벤트는 순서를 지키도록 스풀 끝에 넣고, 스풀을 한 배치씩 보낸다.
+func (g *grpcSink) stream() error {
+	ctx, cancel := context.WithCancel(metadata.AppendToOutgoingContext(context.Background(), "x-api-key", apiKey))
+	defer cancel()
+	var opts []grpc.CallOption
+	if g.setting.Gzip {
+		opts = append(opts, grpc.UseCompressor(gzip.Name))
+	}
+	stream, err := eventpb.NewIngestClient(g.conn).Stream(ctx, opts...)
+	if err != nil {
+		return err
+	}
+
+	hostname, _ := os.Hostname()
+	hello := &eventpb.Hello{Agent: hostname, RulesVersion: g.rulesVersion.Load().(string)}
+	if err := stream.Send(&eventpb.AgentMessage{Body: &eventpb.AgentMessage_Hello{Hello: hello}}); err != nil {
+		return err
+	}
+
+	// grpc 스트림의 Send는 한 고루틴에서만 불러야 하므로 규칙 적용 결과도 이 루프에서 보낸다.
+	// Ack도 이 루프에서 처리해 pending과 스풀을 이 고루틴에서만 다룬다.
+	results := make(chan *eventpb.RuleUpdateResult, 1)
+	acks := make(chan *eventpb.Ack, 64)
+	recvErr := make(chan error, 1)
+	go func() {
+		recvErr <- g.receive(ctx, stream, acks, results)
+	}()
+
+	var seq uint64
+	pending := make(map[uint64]*grpcBatch)
+	batch := make([]*eventpb.Event, 0, g.setting.BatchSize)
+	defer func() {
+		g.lost(pending, batch)
+	}()
+	send := func(b *grpcBatch) error {
+		seq++
+		pending[seq] = b
+		return stream.Send(&eventpb.AgentMessage{Body: &eventpb.AgentMessage_Events{Events: &eventpb.EventBatch{Seq: seq, Events: b.events}}})
+	}
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		events := batch
+		batch = make([]*eventpb.Event, 0, g.setting.BatchSize)
+		if g.spool != nil && g.spool.pending() {
+			g.spoolEvents(events)
+			return nil
+		}
+		return send(&grpcBatch{events: events})
+	}
+	replay := func() error {
+		if events := g.nextReplayBatch(); len(events) > 0 {
+			if err := send(&grpcBatch{events: events, replay: true}); err != nil {
+				return err
+			}
+			g.replaying.seq = seq
+		}
+		return nil
+	}
+	// 받은 Ack를 모두 처리한다. 스트림이 끝나면 receive는 Ack를 모두 넘긴 뒤에 오류를 돌려준다.
+	drainAcks := func() {
+		for {
+			select {
+			case ack := <-acks:
+				g.acked(pending, ack)
+			default:
+				return
+			}
+		}
+	}
+
+	if err := replay(); err != nil {
+		return err
+	}
+	timer := time.NewTimer(g.setting.BatchInterval)
+	defer timer.Stop()
+	for {
+		select {
+		case event := <-g.queue:
+			batch = append(batch, event)
+			if len(batch) < g.setting.BatchSize {
+				continue
+			}
+		case <-timer.C:
+		case ack := <-acks:
+			g.acked(pending, ack)
+			continue
+		case result := <-results:
+			if err := stream.Send(&eventpb.AgentMessage{Body: &eventpb.AgentMessage_RuleResult{RuleResult: result}}); err != nil {
+				return err
+			}
+			continue
+		case err := <-recvErr:
+			drainAcks()
+			return err
+		case <-g.stop:
+			for drained := false; !drained; {
+				select {
+				case event := <-g.queue:
+					batch = append(batch, event)
+					if len(batch) >= g.setting.BatchSize {
+						if err := flush(); err != nil {
+							return err
+						}
+					}
+				default:
+					drained = true
+				}
+			}
+			if err := flush(); err != nil {
+				return err
+			}
+			stream.CloseSend()
+			for {
+				select {
+				case ack := <-acks:
+					g.acked(pending, ack)
+				case err := <-recvErr:
+					drainAcks()
+					if err != io.EOF {
+						return err
+					}
+					return nil
+				}
+			}
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+		if err := replay(); err != nil {
+			return err
+		}
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(g.setting.BatchInterval)
+	}
+}
+
+// 서버의 Ack와 규칙 업데이트를 받아 stream 루프로 넘긴다. 스트림이 끝나면 그 오류를 돌려준다.
+func (g *grpcSink) receive(ctx context.Context, stream eventpb.Ingest_StreamClient, acks chan<- *eventpb.Ack, results chan<- *eventpb.RuleUpdateResult) error {
+	for {
+		msg, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		switch body := msg.Body.(type) {
+		case *eventpb.ServerMessage_Ack:
+			select {
+			case acks <- body.Ack:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		case *eventpb.ServerMessage_RuleUpdate:
+			result := g.applyRules(body.RuleUpdate)
+			select {
+			case results <- result:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+// Ack를 받은 배치에서 저장된 이벤트를 보낸 것으로 센다. 429, 5xx로 저장하지 못한 이벤트는 sender처럼 다시 보내고
+// 그 밖의 실패는 버린다. 스풀에서 읽은 배치는 남은 이벤트를 같은 자리에서 다시 보내고, 모두 처리되면 커서를 옮긴다.
+func (g *grpcSink) acked(pending map[uint64]*grpcBatch, ack *eventpb.Ack) {
+	b, ok := pending[ack.Seq]
+	if !ok {
+		return
+	}
+	delete(pending, ack.Seq)
+	g.sent.Add(uint64(ack.Saved))
+	if ack.Failed > 0 {
+		fmt.Printf("[전송] grpc: 서버가 이벤트 %d개를 저장하지 못했습니다: %s\n", ack.Failed, ack.Error)
+	}
+	var retry []*eventpb.Event
+	if len(ack.Failures) == 0 {
+		// 이벤트별 실패를 알려 주지 않는 서버
+		g.failed.Add(uint64(ack.Failed))
+	}
+	for _, failure := range ack.Failures {
+		if int(failure.Index) >= len(b.events) {
+			continue
+		}
+		if retryableStatus(int(failure.Status)) {
+			retry = append(retry, b.events[failure.Index])
+		} else {
+			g.failed.Add(1)
+		}
+	}
+
+	if !b.replay {
+		g.spoolEvents(retry)
+		return
+	}
+	if g.replaying == nil || g.replaying.seq != ack.Seq {
+		return
+	}
+	if len(retry) > 0 {
+		g.replaying.events, g.replaying.seq = retry, 0
+		g.nextReplay = time.Now().Add(g.replayDelay)
+		g.replayDelay = min(g.replayDelay*2, retryMaxDelay)
+		return
+	}
+	if g.spool.reading(g.replaying.cursor.seq) {
+		g.spool.commit(g.replaying.cursor.next, g.replaying.cursor.eof)
+	}
+	g.replaying = nil
+	g.replayDelay = retryBaseDelay
+	if !g.spool.pending() {
+		fmt.Println("[전송] grpc: 보관한 이벤트를 모두 보냈습니다.")
+	}
+}
+
+// 스풀에서 다음으로 보낼 이벤트를 돌려준다. 보낸 배치의 Ack를 기다리거나 nextReplay 전이면 nil이다.
+func (g *grpcSink) nextReplayBatch() []*eventpb.Event {
+	if g.spool == nil || time.Now().Before(g.nextReplay) {
+		return nil
+	}
+	if g.replaying != nil && !g.spool.reading(g.replaying.cursor.seq) {
+		// 스풀이 가득 차 trim이 읽던 세그먼트를 지웠다. 남은 이벤트는 버린 것으로 이미 셌다.
+		g.replaying = nil
+	}
+	for g.replaying == nil {
+		if !g.spool.pending() {
+			return nil
+		}
+		segment := g.spool.segments[0]
+		lines, next, eof, err := g.spool.peek(g.setting.BatchSize)
+		if err != nil {
+			fmt.Printf("[전송] grpc: 스풀을 읽을 수 없습니다: %v\n", err)
+			g.nextReplay = time.Now().Add(retryMaxDelay)
+			return nil
+		}
+		var events []*eventpb.Event
+		for _, line := range lines {
+			event := &eventpb.Event{}
+			if err := protojson.Unmarshal(line, event); err != nil {
+				g.failed.Add(1)
+				continue
+			}
+			events = append(events, event)
+		}
+		if len(events) == 0 {
+			g.spool.commit(next, eof)
+			continue
+		}
+		g.replaying = &grpcReplay{events: events, cursor: spooledBatch{seq: segment, next: next, eof: eof}}
+	}
+	if g.replaying.seq != 0 {
+		return nil
+	}
+	return g.replaying.events
+}
+
+// 스트림이 끊겨 Ack를 받지 못한 배치와 아직 보내지 않은 배치를 보낸 순서대로 스풀에 넣는다.
+// 스풀에서 읽은 배치는 커서를 옮기지 않았으므로 다음 스트림에서 다시 보낸다.
+func (g *grpcSink) lost(pending map[uint64]*grpcBatch, batch []*eventpb.Event) {
+	seqs := make([]uint64, 0, len(pending))
+	for seq := range pending {
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	for _, seq := range seqs {
+		if !pending[seq].replay {
+			g.spoolEvents(pending[seq].events)
+		}
+	}
+	g.spoolEvents(batch)
+	if g.replaying != nil {
+		g.replaying.seq = 0
+	}
+}
+
+// 보내지 못한 이벤트를 스풀 끝에 넣는다. 스풀이 없으면 보내지 못한 것으로 센다.
+func (g *grpcSink) spoolEvents(events []*eventpb.Event) {
+	if len(events) == 0 {
+		return
+	}
+	if g.spool == nil {
+		g.failed.Add(uint64(len(events)))
+		return
+	}
+	lines := make([]json.RawMessage, 0, len(events))
+	for _, event := range events {
+		line, err := protojson.Marshal(event)
+		if err != nil {
+			g.failed.Add(1)
+			continue
+		}
+		lines = append(lines, line)
+	}
+	dropped, err := g.spool.append(lines)
+	if err != nil {
+		fmt.Printf("[전송] grpc: 이벤트 %d개를 디스크에 보관하지 못했습니다: %v\n", len(lines), err)
+		g.failed.Add(uint64(len(lines)))
+		return
+	}
+	g.spooled.Add(uint64(len(lines)))
+	g.dropped.Add(uint64(dropped))
+}
+
+// 규칙 업데이트를 적용한다. 위조한 서버가 규칙을 바꾸지 못하도록 TLS를 쓰지 않으면 GrpcInsecureRuleUpdates를 켠 경우에만 받는다.
+func (g *grpcSink) applyRules(update *eventpb.RuleUpdate) *eventpb.RuleUpdateResult {
+	result := &eventpb.RuleUpdateResult{Version: update.Version}
+	if !g.setting.GrpcTLS && !g.setting.GrpcInsecureRuleUpdates {
+		fmt.Printf("[전송] grpc: TLS를 쓰지 않아 규칙 버전 %s을(를) 받지 않습니다.\n", update.Version)
+		result.Error = "TLS를 쓰지 않는 연결에서는 규칙 업데이트를 받지 않습니다 (GrpcTLS 또는 GrpcInsecureRuleUpdates)"
+		return result
+	}
+	files := make(map[string][]byte, len(update.Files))
+	for _, file := range update.Files {
+		files[file.Name] = file.Content
+	}
+	err := fmt.Errorf("규칙 업데이트를 적용할 수 없습니다")
+	if RuleUpdateHandler != nil {
+		err = RuleUpdateHandler(update.Version, files)
+	}
+	if err != nil {
+		fmt.Printf("[전송] grpc: 규칙 버전 %s을(를) 적용하지 못했습니다: %v\n", update.Version, err)
+		result.Error = err.Error()
+		return result
+	}
+	g.rulesVersion.Store(update.Version)
+	return result
+}
+
+// 이벤트를 보낼 큐에 넣는다. 큐가 가득 차 있으면 기다리지 않고 버린다.
+func (g *grpcSink) publish(data ApiData) {
+	if g == nil || !g.events {
+		return
+	}
+	event := protoEvent(data)
+	if event == nil {
+		return
+	}
+	select {
+	case g.queue <- event:
+	default:
+		g.dropped.Add(1)
+	}
+}
+
+func (g *grpcSink) stats() TransportStats {
+	stats := TransportStats{Sent: g.sent.Load(), Dropped: g.dropped.Load(), Failed: g.failed.Load(), Spooled: g.spooled.Load(), Queued: len(g.queue)}
+	if g.spool != nil {
+		stats.SpoolBytes = g.spool.pendingBytes.Load()
+	}
+	return stats
+}
+
+// 큐에 남은 이벤트를 보내고 스트림을 닫는다. 끝나면 done이 닫힌다.
+func (g *grpcSink) close() {
+	g.once.Do(func() { close(g.stop) })
+}
+
+// ApiData를 protobuf 이벤트로 바꾼다. 필드는 HTTP로 보내는 JSON과 같으며 네트워크 이벤트의 path는 보내지 않는다.
+func protoEvent(data ApiData) *eventpb.Event {
+	process := func(b BasicApiData) *eventpb.Process {
+		return &eventpb.Process{Uid: b.Uid, Gid: b.Gid, Pid: b.Pid, Ppid: b.Ppid}
+	}
+	basic := func(b BasicApiData) *eventpb.Event {
+		return &eventpb.Event{EventType: b.EventType, Timestamp: b.Time, ContainerName: b.ContainerName}
+	}
+	switch d := data.(type) {
+	case ExecveApiData:
+		event := basic(d.BasicApiData)
+		event.Data = &eventpb.Event_Execve{Execve: &eventpb.Execve{
+			Process:     process(d.BasicApiData),
+			Command:     d.Command,
+			ProcessName: d.ProcessName,
+			Arguments:   d.Args,
+		}}
+		return event
+	case OpenApiData:
+		event := basic(d.BasicApiData)
+		event.Data = &eventpb.Event_Open{Open: &eventpb.Open{
+			Process:     process(d.BasicApiData),
+			Command:     d.Command,
+			Filename:    d.Filename,
+			Status:      d.ReturnValue,
+			ProcessName: d.ProcessName,
+		}}
+		return event
+	case DeleteApiData:
+		event := basic(d.BasicApiData)
+		event.Data = &eventpb.Event_Delete{Delete: &eventpb.Delete{
+			Process:     process(d.BasicApiData),
+			ProcessName: d.ProcessName,
+			Filename:    d.Filename,
+		}}
+		return event
+	case MemoryApiData:
+		event := basic(d.BasicApiData)
+		event.Data = &eventpb.Event_Memory{Memory: &eventpb.Memory{
+			Process:      process(d.BasicApiData),
+			ProcessName:  d.ProcessName,
+			Syscall:      d.Syscall,
+			Prot:         d.Prot,
+			Prottemp:     d.Prottemp,
+			MappingType:  d.MappingType,
+			StartAddress: d.StartAddress,
+			EndAddress:   d.EndAddress,
+			Size:         d.Size,
+		}}
+		return event
+	case LogAccessApiData:
+		event := basic(d.BasicApiData)
+		event.Data = &eventpb.Event_LogAccess{LogAccess: &eventpb.LogAccess{
+			Process:     process(d.BasicApiData),
+			ProcessName: d.ProcessName,
+			Filename:    d.Filename,
+			FileSize:    d.FileSize,
+			MountStatus: d.MountStatus,
+		}}
+		return event
+	case NetworkApiData:
+		return &eventpb.Event{
+			EventType:     d.EventType,
+			Timestamp:     d.Time,
+			ContainerName: d.ContainerName,
+			Data: &eventpb.Event_Network{Network: &eventpb.Network{
+				SrcIp:          d.SrcIp,
+				SrcIpLabel:     d.SrcIpLabel,
+				DstIp:          d.DstIp,
+				DstIpLabel:     d.DstIpLabel,
+				Protocol:       d.Protocol,
+				PacketSize:     int32(d.PacketSize),
+				TotalPackets:   int32(d.TotalPackets),
+				TotalSize:      int64(d.TotalSize),
+				Direction:      d.Direction,
+				HttpMethod:     d.Method,
+				HttpHost:       d.Host,
+				HttpUrl:        d.URL,
+				HttpParameters: d.Parameters,
+			}},
+		}
+	case ContainerMetricsApiData:
+		return &eventpb.Event{
+			EventType:     d.EventType,
+			Timestamp:     d.Time,
+			ContainerName: d.Name,
+			Data: &eventpb.Event_ContainerMetrics{ContainerMetrics: &eventpb.ContainerMetrics{
+				CpuUsage:    d.CpuUsage,
+				MemoryUsage: d.MemoryUsage,
+				DiskUsage:   d.DiskUsage,
+				RxBytes:     d.RxBytes,
+				TxBytes:     d.TxBytes,
+			}},
+		}
+	case HostMetricsApiData:
+		return &eventpb.Event{
+			EventType: d.EventType,
+			Timestamp: d.Time,
+			Data: &eventpb.Event_HostMetrics{HostMetrics: &eventpb.HostMetrics{
+				CpuUsage:    d.CpuUsage,
+				MemoryUsage: d.MemoryUsage,
+				DiskUsage:   d.DiskUsage,
+				CpuCores:    int32(d.CpuCores),
+			}},
+		}
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+// Copyright Authors of HActiV
+
+package utils
+
+import (
+	"HActiV/pkg/utils/eventpb"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// 받은 이벤트마다 handle이 정한 상태로 Ack를 돌려주고 저장한 이벤트를 기록하는 Ingest 서버.
+// handle이 ok로 false를 돌려주면 Ack 없이 스트림을 끊는다.
+type ingestServer struct {
+	eventpb.UnimplementedIngestServer
+	mu      sync.Mutex
+	streams int
+	saved   []string
+	handle  func(stream int, event *eventpb.Event) (status uint32, ok bool)
+	// 있으면 Hello를 받은 뒤 보내는 규칙 업데이트
+	update  *eventpb.RuleUpdate
+	results chan *eventpb.RuleUpdateResult
+}
+
+func (s *ingestServer) Stream(stream eventpb.Ingest_StreamServer) error {
+	s.mu.Lock()
+	s.streams++
+	n := s.streams
+	s.mu.Unlock()
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if msg.GetHello() != nil && s.update != nil {
+			if err := stream.Send(&eventpb.ServerMessage{Body: &eventpb.ServerMessage_RuleUpdate{RuleUpdate: s.update}}); err != nil {
+				return err
+			}
+		}
+		if result := msg.GetRuleResult(); result != nil {
+			s.results <- result
+		}
+		batch := msg.GetEvents()
+		if batch == nil {
+			continue
+		}
+		ack := &eventpb.Ack{Seq: batch.Seq}
+		s.mu.Lock()
+		for i, event := range batch.Events {
+			status, ok := s.handle(n, event)
+			if !ok {
+				s.mu.Unlock()
+				return errors.New("stream closed by test")
+			}
+			if status == 200 {
+				s.saved = append(s.saved, event.ContainerName)
+				ack.Saved++
+				continue
+			}
+			ack.Failed++
+			ack.Failures = append(ack.Failures, &eventpb.EventFailure{Index: uint32(i), Status: status})
+		}
+		s.mu.Unlock()
+		if err := stream.Send(&eventpb.ServerMessage{Body: &eventpb.ServerMessage_Ack{Ack: ack}}); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *ingestServer) savedEvents() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return strings.Join(s.saved, ",")
+}
+
+func TestGrpcSinkSpoolsUnsavedEvents(t *testing.T) {
+	tests := []struct {
+		name    string
+		handle  func(attempts map[string]int) func(stream int, event *eventpb.Event) (uint32, bool)
+		saved   string
+		sent    uint64
+		failed  uint64
+		spooled uint64
+	}{
+		{
+			// 1은 두 번 503으로 실패해 스풀에 들어간 뒤 같은 자리에서 다시 보내고, 2는 400이라 버린다.
+			name: "retryable failures in ack",
+			handle: func(attempts map[string]int) func(int, *eventpb.Event) (uint32, bool) {
+				return func(_ int, event *eventpb.Event) (uint32, bool) {
+					attempts[event.ContainerName]++
+					switch {
+					case event.ContainerName == "1" && attempts["1"] <= 2:
+						return 503, true
+					case event.ContainerName == "2":
+						return 400, true
+					}
+					return 200, true
+				}
+			},
+			saved: "0,3,4,1", sent: 4, failed: 1, spooled: 1,
+		},
+		{
+			// 첫 스트림은 Ack 없이 끊기므로 보낸 배치를 모두 스풀에 넣고 다시 연결한 뒤 순서대로 보낸다.
+			name: "stream lost before ack",
+			handle: func(map[string]int) func(int, *eventpb.Event) (uint32, bool) {
+				return func(stream int, _ *eventpb.Event) (uint32, bool) {
+					return 200, stream > 1
+				}
+			},
+			saved: "0,1,2,3,4", sent: 5, failed: 0, spooled: 5,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			server := grpc.NewServer()
+			ingest := &ingestServer{handle: tt.handle(map[string]int{})}
+			eventpb.RegisterIngestServer(server, ingest)
+			go server.Serve(listener)
+			defer server.Stop()
+
+			LogLocation = t.TempDir() + "/"
+			setting := testTransportSetting()
+			setting.SpoolMaxSize = 2 * spoolSegmentSize
+			setting.GrpcAddr = listener.Addr().String()
+			setting.DataSink = "grpc"
+			g, err := newGrpcSink(setting)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 5; i++ {
+				g.queue <- &eventpb.Event{EventType: "execve", ContainerName: strconv.Itoa(i)}
+			}
+
+			deadline := time.Now().Add(10 * time.Second)
+			for ingest.savedEvents() != tt.saved && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+			}
+			g.close()
+			<-g.done
+			g.spool.close()
+
+			if got := ingest.savedEvents(); got != tt.saved {
+				t.Errorf("server saved %s, want %s", got, tt.saved)
+			}
+			if stats := g.stats(); stats.Sent != tt.sent || stats.Failed != tt.failed || stats.Spooled != tt.spooled || stats.SpoolBytes != 0 {
+				t.Errorf("stats: %s, want sent %d, failed %d, spooled %d", stats, tt.sent, tt.failed, tt.spooled)
+			}
+		})
+	}
+}
+
+func TestGrpcStreamReceivesRulesWithoutGrpcDataSink(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	ingest := &ingestServer{
+		update:  &eventpb.RuleUpdate{Version: "v2", Files: []*eventpb.RuleFile{{Name: "execverule.json", Content: []byte("[]")}}},
+		results: make(chan *eventpb.RuleUpdateResult, 1),
+		handle: func(int, *eventpb.Event) (uint32, bool) {
+			t.Error("event sent over the gRPC stream while DataSink is http")
+			return 200, true
+		},
+	}
+	eventpb.RegisterIngestServer(server, ingest)
+	go server.Serve(listener)
+	defer server.Stop()
+
+	applied := make(chan string, 1)
+	defer func(handler func(string, map[string][]byte) error) { RuleUpdateHandler = handler }(RuleUpdateHandler)
+	RuleUpdateHandler = func(version string, files map[string][]byte) error {
+		applied <- version
+		return nil
+	}
+
+	setting := testTransportSetting()
+	setting.GrpcAddr = listener.Addr().String()
+	setting.GrpcInsecureRuleUpdates = true
+	g, err := newGrpcSink(setting)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		g.close()
+		<-g.done
+	}()
+	g.publish(ExecveApiData{BasicApiData: BasicApiData{ContainerName: "web"}})
+
+	select {
+	case version := <-applied:
+		if version != "v2" {
+			t.Errorf("applied rules version %q, want v2", version)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("rule update was not applied")
+	}
+	select {
+	case result := <-ingest.results:
+		if result.Version != "v2" || result.Error != "" {
+			t.Errorf("rule result = %v", result)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("agent did not report the rule result")
+	}
+	if stats := g.stats(); stats.Queued != 0 || g.spool != nil {
+		t.Errorf("stats: %s, spool %v, want no queued events and no spool", stats, g.spool)
+	}
+}
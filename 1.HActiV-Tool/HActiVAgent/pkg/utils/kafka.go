@@ -0,0 +1,197 @@
+// Copyright Authors of HActiV
+
+// utils package for helping other package
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// Kafka 싱크. DataSink가 kafka나 both이면 이벤트를 서버의 Kafka 컨슈머(models.InitializeKafkaConsumers)가
+// 읽는 토픽에 이벤트 종류별로 보낸다. 키는 컨테이너 이름이라 같은 컨테이너의 이벤트는 같은 파티션에 순서대로 쌓인다.
+// 알림은 지금처럼 RuleUrl로만 보낸다.
+//
+// 이벤트는 큐에 넣고 백그라운드에서 프로듀서로 넘긴다. 브로커에 연결할 때까지 다시 연결하며 그동안 이벤트는 큐에 쌓이고,
+// 큐가 가득 찬 뒤의 이벤트는 기다리지 않고 버린다.
+type kafkaSink struct {
+	brokers []string
+	config  *sarama.Config
+	queue   chan *sarama.ProducerMessage
+	stop    chan struct{}
+	once    sync.Once
+	done    chan struct{} // 종료할 때 남은 메시지를 모두 보내거나 실패하면 닫힌다.
+
+	sent    atomic.Uint64
+	dropped atomic.Uint64
+	failed  atomic.Uint64
+}
+
+var dataKafka *kafkaSink
+
+// 이벤트 종류별 토픽. 서버의 InitializeKafkaConsumers와 같아야 한다.
+func kafkaRoute(data ApiData) (topic, key string) {
+	switch d := data.(type) {
+	case ExecveApiData:
+		return "execve_events", d.ContainerName
+	case OpenApiData:
+		return "open_events", d.ContainerName
+	case NetworkApiData:
+		return "network_events", d.ContainerName
+	case MemoryApiData:
+		return "memory_events", d.ContainerName
+	case DeleteApiData:
+		return "delete_events", d.ContainerName
+	case LogAccessApiData:
+		return "log_access_events", d.ContainerName
+	case ContainerMetricsApiData:
+		return "container_metrics_events", d.Name
+	case HostMetricsApiData:
+		return "host_metrics_events", ""
+	}
+	return "", ""
+}
+
+// 전송 설정을 sarama 프로듀서 설정으로 바꾼다. 배치, 큐 크기, 제한 시간, 재시도, 압축은 HTTP 전송과 같은 값을 쓴다.
+func newKafkaConfig(setting TransportSetting) *sarama.Config {
+	config := sarama.NewConfig()
+	config.ClientID = "hactiv-agent"
+	config.ChannelBufferSize = setting.QueueSize
+	config.Net.DialTimeout = setting.Timeout
+	config.Net.ReadTimeout = setting.Timeout
+	config.Net.WriteTimeout = setting.Timeout
+	config.Producer.RequiredAcks = sarama.WaitForLocal
+	config.Producer.Timeout = setting.Timeout
+	config.Producer.Return.Successes = true
+	config.Producer.Flush.Messages = setting.BatchSize
+	config.Producer.Flush.Frequency = setting.BatchInterval
+	config.Producer.Retry.Max = setting.Retries
+	config.Producer.Retry.Backoff = retryBaseDelay
+	if setting.Gzip {
+		config.Producer.Compression = sarama.CompressionGZIP
+	}
+	return config
+}
+
+func newKafkaSink(brokers []string, config *sarama.Config) *kafkaSink {
+	k := &kafkaSink{
+		brokers: brokers,
+		config:  config,
+		queue:   make(chan *sarama.ProducerMessage, config.ChannelBufferSize),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go k.connect()
+	go report("kafka", k.stats, k.done)
+	return k
+}
+
+// 브로커에 연결할 때까지 간격을 두 배씩 늘리며 다시 시도한다.
+func (k *kafkaSink) connect() {
+	delay := retryBaseDelay
+	for attempt := 0; ; attempt++ {
+		producer, err := sarama.NewAsyncProducer(k.brokers, k.config)
+		if err == nil {
+			fmt.Printf("[전송] kafka: %v에 연결했습니다.\n", k.brokers)
+			go k.collect(producer)
+			k.forward(producer)
+			return
+		}
+		if attempt == 0 {
+			fmt.Printf("[전송] kafka: 브로커에 연결할 수 없어 다시 시도합니다: %v\n", err)
+		}
+		select {
+		case <-time.After(delay):
+		case <-k.stop:
+			close(k.done)
+			return
+		}
+		delay = min(delay*2, retryMaxDelay)
+	}
+}
+
+// 큐의 메시지를 프로듀서로 넘긴다. 종료할 때는 남은 메시지를 넘기고 프로듀서를 닫는다.
+func (k *kafkaSink) forward(producer sarama.AsyncProducer) {
+	defer producer.AsyncClose()
+	for {
+		select {
+		case msg := <-k.queue:
+			producer.Input() <- msg
+		case <-k.stop:
+			for {
+				select {
+				case msg := <-k.queue:
+					producer.Input() <- msg
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// 프로듀서의 결과를 세고, 프로듀서가 닫히면 done을 닫는다.
+func (k *kafkaSink) collect(producer sarama.AsyncProducer) {
+	defer close(k.done)
+	successes, errors := producer.Successes(), producer.Errors()
+	var lastErr time.Time
+	for successes != nil || errors != nil {
+		select {
+		case _, ok := <-successes:
+			if !ok {
+				successes = nil
+				continue
+			}
+			k.sent.Add(1)
+		case err, ok := <-errors:
+			if !ok {
+				errors = nil
+				continue
+			}
+			k.failed.Add(1)
+			if time.Since(lastErr) >= transportReportInterval {
+				fmt.Printf("[전송] kafka: 메시지를 보내지 못했습니다: %v\n", err)
+				lastErr = time.Now()
+			}
+		}
+	}
+}
+
+// 이벤트를 종류에 맞는 토픽에 보낼 큐에 넣는다. 큐가 가득 차 있으면 기다리지 않고 버린다.
+func (k *kafkaSink) publish(data ApiData) {
+	if k == nil {
+		return
+	}
+	topic, key := kafkaRoute(data)
+	if topic == "" {
+		return
+	}
+	value, err := json.Marshal(data)
+	if err != nil {
+		fmt.Println("JSON 변환 오류:", err)
+		return
+	}
+	msg := &sarama.ProducerMessage{Topic: topic, Value: sarama.ByteEncoder(value)}
+	if key != "" {
+		msg.Key = sarama.StringEncoder(key)
+	}
+	select {
+	case k.queue <- msg:
+	default:
+		k.dropped.Add(1)
+	}
+}
+
+func (k *kafkaSink) stats() TransportStats {
+	return TransportStats{Sent: k.sent.Load(), Dropped: k.dropped.Load(), Failed: k.failed.Load(), Queued: len(k.queue)}
+}
+
+// 큐에 남은 메시지를 보내고 프로듀서를 닫는다. 끝나면 done이 닫힌다.
+func (k *kafkaSink) close() {
+	k.once.Do(func() { close(k.stop) })
+}
@@ -0,0 +1,134 @@
+// Copyright Authors of HActiV
+
+package utils
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// 프로듀서가 브로커로 넘기는 메시지를 기록한다.
+type recordingInterceptor struct {
+	mu       sync.Mutex
+	messages []*sarama.ProducerMessage
+}
+
+func (r *recordingInterceptor) OnSend(msg *sarama.ProducerMessage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages = append(r.messages, msg)
+}
+
+func testKafkaConfig(queueSize int) *sarama.Config {
+	setting := defaultTransportSetting
+	setting.QueueSize = queueSize
+	setting.BatchInterval = 10 * time.Millisecond
+	setting.Timeout = time.Second
+	config := newKafkaConfig(setting)
+	config.Metadata.Retry.Max = 0
+	return config
+}
+
+func TestKafkaSinkRoutesByEventType(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  ApiData
+		topic string
+		key   string
+	}{
+		{"execve", ExecveApiData{BasicApiData: BasicApiData{ContainerName: "web"}}, "execve_events", "web"},
+		{"open", OpenApiData{BasicApiData: BasicApiData{ContainerName: "web"}}, "open_events", "web"},
+		{"network", NetworkApiData{ContainerName: "db"}, "network_events", "db"},
+		{"memory", MemoryApiData{BasicApiData: BasicApiData{ContainerName: "db"}}, "memory_events", "db"},
+		{"delete", DeleteApiData{BasicApiData: BasicApiData{ContainerName: "cache"}}, "delete_events", "cache"},
+		{"log access", LogAccessApiData{BasicApiData: BasicApiData{ContainerName: "cache"}}, "log_access_events", "cache"},
+		{"container metrics", ContainerMetricsApiData{Name: "web"}, "container_metrics_events", "web"},
+		{"host metrics", HostMetricsApiData{}, "host_metrics_events", ""},
+	}
+
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+	metadata := sarama.NewMockMetadataResponse(t).SetBroker(broker.Addr(), broker.BrokerID())
+	for _, tt := range tests {
+		metadata.SetLeader(tt.topic, 0, broker.BrokerID())
+	}
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+		"ProduceRequest":  sarama.NewMockProduceResponse(t),
+	})
+
+	config := testKafkaConfig(100)
+	interceptor := &recordingInterceptor{}
+	config.Producer.Interceptors = []sarama.ProducerInterceptor{interceptor}
+	k := newKafkaSink([]string{broker.Addr()}, config)
+	for _, tt := range tests {
+		k.publish(tt.data)
+	}
+	k.close()
+	select {
+	case <-k.done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("kafka sink did not finish sending")
+	}
+
+	interceptor.mu.Lock()
+	defer interceptor.mu.Unlock()
+	if len(interceptor.messages) != len(tests) {
+		t.Fatalf("sent %d messages, want %d", len(interceptor.messages), len(tests))
+	}
+	for i, tt := range tests {
+		msg := interceptor.messages[i]
+		if msg.Topic != tt.topic {
+			t.Errorf("%s: topic %q, want %q", tt.name, msg.Topic, tt.topic)
+		}
+		key := ""
+		if msg.Key != nil {
+			data, _ := msg.Key.Encode()
+			key = string(data)
+		}
+		if key != tt.key {
+			t.Errorf("%s: key %q, want %q", tt.name, key, tt.key)
+		}
+	}
+	if stats := k.stats(); stats.Sent != uint64(len(tests)) || stats.Dropped != 0 || stats.Failed != 0 {
+		t.Errorf("stats: %s, want sent %d", stats, len(tests))
+	}
+}
+
+func TestKafkaSinkDropsWhenQueueIsFull(t *testing.T) {
+	tests := []struct {
+		name      string
+		queueSize int
+		published int
+		dropped   uint64
+	}{
+		{"within queue", 4, 3, 0},
+		{"queue full", 2, 5, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 연결할 수 없는 브로커라 큐의 메시지는 프로듀서로 넘어가지 않는다.
+			broker := sarama.NewMockBroker(t, 1)
+			addr := broker.Addr()
+			broker.Close()
+
+			k := newKafkaSink([]string{addr}, testKafkaConfig(tt.queueSize))
+			for i := 0; i < tt.published; i++ {
+				k.publish(ExecveApiData{BasicApiData: BasicApiData{ContainerName: "web"}})
+			}
+			stats := k.stats()
+			k.close()
+			<-k.done
+
+			if stats.Dropped != tt.dropped || stats.Sent != 0 {
+				t.Errorf("stats: %s, want dropped %d", stats, tt.dropped)
+			}
+			if want := tt.published - int(tt.dropped); stats.Queued != want {
+				t.Errorf("queued %d, want %d", stats.Queued, want)
+			}
+		})
+	}
+}
@@ -7,8 +7,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"reflect"
-	"strings"
 	"sync"
 
 	"github.com/klauspost/compress/zstd"
@@ -22,15 +20,6 @@ type DualLogger struct {
 	wg           sync.WaitGroup
 }
 
-// execve -> Systemcall Datasend와 필드 일치를 위해해
-var ToolFields = map[string]string{
-	"Systemcall":      "Time ContainerName Uid Gid Pid Ppid Puid Pgid Filename ProcessName Args",
-	"file_open":            "Time ContainerName Uid Gid Pid Ppid Filename ProcessName",
-	"delete":          "Time ContainerName Uid Gid Pid Ppid Filename ProcessName",
-	"Memory":          "Time ContainerName Uid Gid Pid Ppid  ProcessName Syscall StartAddr EndAddr Size Prottemp Prot MappingType",
-	"Network_traffic": "Time ContainerName SrcIp SrcIpLabel DstIp DstIpLabel Direction Protocol SrcPort DstPort PacketSize",
-}
-
 func NewDualLogger(compressFilename, jsonFilename string) (*DualLogger, error) {
 	compressFile, err := os.OpenFile(LogLocation+compressFilename+".zst", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
@@ -65,66 +54,35 @@ func NewDualLogger(compressFilename, jsonFilename string) (*DualLogger, error) {
 func (l *DualLogger) writeLoop() {
 	defer l.wg.Done()
 	for event := range l.logChan {
-		l.writeCompressLog(event)
-		l.writeJSONLog(event)
-	}
-}
-
-func (l *DualLogger) writeCompressLog(event Event) {
-	v := reflect.ValueOf(event)
-	t := v.Type()
-
-	var jsonFields []string
-	tool := v.Field(0).String()
-	for i := 1; i < v.NumField(); i++ {
-		field := v.Field(i)
-		fieldName := t.Field(i).Name
-		if tool == "network" && (fieldName == "Prot" || fieldName == "Size") {
+		data, err := NewApiData(event)
+		if err != nil {
 			continue
 		}
-		if strings.Contains(ToolFields[tool], fieldName) {
-			fieldValue := field.Interface()
-			jsonValue, _ := json.Marshal(fieldValue)
-			jsonFields = append(jsonFields, fmt.Sprintf(`"%s":%s`, fieldName, string(jsonValue)))
-		}
-	}
-
-	if len(jsonFields) > 0 {
-		jsonString := "{" + strings.Join(jsonFields, ",") + "}\n"
-		_, err := l.jsonEncoder.Write([]byte(jsonString))
+		line, err := json.Marshal(data)
 		if err != nil {
-			fmt.Println("Error writing to JSON log:", err)
+			continue
 		}
-		l.jsonEncoder.Flush()
+		line = append(line, '\n')
+		l.writeCompressLog(line)
+		l.writeJSONLog(line)
 	}
 }
 
-func (l *DualLogger) writeJSONLog(event Event) {
-	v := reflect.ValueOf(event)
-	t := v.Type()
-
-	var jsonFields []string
-	tool := v.Field(0).String()
-	for i := 1; i < v.NumField(); i++ {
-		field := v.Field(i)
-		fieldName := t.Field(i).Name
-		if tool == "network" && (fieldName == "Prot" || fieldName == "Size") {
-			continue
-		}
-		if strings.Contains(ToolFields[tool], fieldName) {
-			fieldValue := field.Interface()
-			jsonValue, _ := json.Marshal(fieldValue)
-			jsonFields = append(jsonFields, fmt.Sprintf(`"%s":%s`, fieldName, string(jsonValue)))
-		}
+// 로그 한 줄은 DataSend로 보내는 ApiData와 같은 JSON이다.
+func (l *DualLogger) writeCompressLog(line []byte) {
+	_, err := l.jsonEncoder.Write(line)
+	if err != nil {
+		fmt.Println("Error writing to JSON log:", err)
 	}
+	l.jsonEncoder.Flush()
+}
 
-	if len(jsonFields) > 0 {
-		jsonString := "{" + strings.Join(jsonFields, ",") + "}\n"
-		l.jsonFile.WriteString(jsonString)
-	}
+func (l *DualLogger) writeJSONLog(line []byte) {
+	l.jsonFile.Write(line)
 }
 
 func (l *DualLogger) Log(event Event) {
+	rememberEvent(event)
 	l.logChan <- event
 }
 
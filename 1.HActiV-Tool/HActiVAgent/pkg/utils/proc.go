@@ -0,0 +1,104 @@
+// Copyright Authors of HActiV
+
+// utils package for helping other package
+package utils
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// /proc/<pid>/<name> 경로
+func ProcPath(pid uint32, name string) string {
+	return fmt.Sprintf("/proc/%d/%s", pid, name)
+}
+
+func ReadProc(pid uint32, name string) ([]byte, error) {
+	return os.ReadFile(ProcPath(pid, name))
+}
+
+// /proc/<pid>/stat에서 읽은 프로세스 정보. Start는 프로세스가 시작한 시각이며 1/100초 단위이다.
+type ProcStat struct {
+	Pid   uint32
+	Ppid  uint32
+	Comm  string
+	Start time.Time
+}
+
+// /proc/<pid>/stat의 starttime 단위(USER_HZ). 리눅스에서는 항상 100이다.
+const clockTicks = 100
+
+var (
+	bootTimeOnce sync.Once
+	bootTime     time.Time
+)
+
+// /proc/stat의 btime. 읽지 못하면 0 시각이다.
+func readBootTime() time.Time {
+	bootTimeOnce.Do(func() {
+		data, err := os.ReadFile("/proc/stat")
+		if err != nil {
+			return
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if value, ok := strings.CutPrefix(line, "btime "); ok {
+				if sec, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64); err == nil {
+					bootTime = time.Unix(sec, 0)
+				}
+				return
+			}
+		}
+	})
+	return bootTime
+}
+
+// comm에는 공백과 괄호가 들어갈 수 있으므로 마지막 ')' 뒤에서 나머지 항목을 읽는다.
+func ReadProcStat(pid uint32) (ProcStat, error) {
+	data, err := ReadProc(pid, "stat")
+	if err != nil {
+		return ProcStat{}, err
+	}
+	stat := string(data)
+	open, end := strings.IndexByte(stat, '('), strings.LastIndexByte(stat, ')')
+	if open < 0 || end < open {
+		return ProcStat{}, fmt.Errorf("%s 형식을 알 수 없습니다", ProcPath(pid, "stat"))
+	}
+	// fields[0]이 3번째 항목(state)이므로 ppid(4번째)는 fields[1], starttime(22번째)은 fields[19]이다.
+	fields := strings.Fields(stat[end+1:])
+	if len(fields) < 20 {
+		return ProcStat{}, fmt.Errorf("%s 형식을 알 수 없습니다", ProcPath(pid, "stat"))
+	}
+	ppid, err := strconv.ParseUint(fields[1], 10, 32)
+	if err != nil {
+		return ProcStat{}, err
+	}
+	ticks, err := strconv.ParseUint(fields[19], 10, 64)
+	if err != nil {
+		return ProcStat{}, err
+	}
+	start := readBootTime().Add(time.Duration(ticks) * time.Second / clockTicks)
+	return ProcStat{Pid: pid, Ppid: uint32(ppid), Comm: stat[open+1 : end], Start: start}, nil
+}
+
+// 현재 실행 중인 모든 프로세스. 읽는 동안 종료된 프로세스는 빠진다.
+func ListProcs() ([]ProcStat, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+	var procs []ProcStat
+	for _, entry := range entries {
+		pid, err := strconv.ParseUint(entry.Name(), 10, 32)
+		if err != nil {
+			continue
+		}
+		if stat, err := ReadProcStat(uint32(pid)); err == nil {
+			procs = append(procs, stat)
+		}
+	}
+	return procs, nil
+}
@@ -0,0 +1,49 @@
+// Copyright Authors of HActiV
+
+// utils package for helping other package
+package utils
+
+import "sync"
+
+// 컨테이너마다 보관하는 최근 이벤트 수. 포렌식 스냅샷에 담는다.
+const RecentEventCount = 200
+
+type eventRing struct {
+	events []Event
+	next   int
+}
+
+var recent = struct {
+	sync.Mutex
+	containers map[string]*eventRing
+}{containers: make(map[string]*eventRing)}
+
+// DualLogger에 기록하는 이벤트를 컨테이너별로 RecentEventCount개까지 보관한다.
+func rememberEvent(event Event) {
+	recent.Lock()
+	defer recent.Unlock()
+	ring, ok := recent.containers[event.ContainerName]
+	if !ok {
+		ring = &eventRing{events: make([]Event, 0, RecentEventCount)}
+		recent.containers[event.ContainerName] = ring
+	}
+	if len(ring.events) < RecentEventCount {
+		ring.events = append(ring.events, event)
+		return
+	}
+	ring.events[ring.next] = event
+	ring.next = (ring.next + 1) % RecentEventCount
+}
+
+// 컨테이너의 최근 이벤트를 오래된 것부터 돌려준다. 모든 모니터의 이벤트가 섞여 있다.
+func RecentEvents(containerName string) []Event {
+	recent.Lock()
+	defer recent.Unlock()
+	ring, ok := recent.containers[containerName]
+	if !ok {
+		return nil
+	}
+	events := make([]Event, 0, len(ring.events))
+	events = append(events, ring.events[ring.next:]...)
+	return append(events, ring.events[:ring.next]...)
+}
@@ -0,0 +1,228 @@
+// Copyright Authors of HActiV
+
+// utils package for helping other package
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// 세그먼트 파일 하나의 최대 크기. 다 보낸 세그먼트는 지운다.
+const spoolSegmentSize = 4 << 20
+
+// 서버에 보내지 못한 이벤트를 보관하는 디스크 큐. 이벤트 하나가 JSON 한 줄이며,
+// 번호가 붙은 세그먼트 파일(0000000000000001.seg ...)에 순서대로 덧붙이고 오래된 것부터 다시 보낸다.
+// 어디까지 보냈는지는 cursor 파일에 남기므로 에이전트를 다시 시작해도 이어서 보낸다.
+// 전체 크기가 maxSize를 넘으면 가장 오래된 세그먼트를 버린다. sender 고루틴에서만 사용한다.
+type spool struct {
+	dir      string
+	maxSize  int64
+	segments []int64 // 오래된 것부터. 첫 세그먼트의 readOff까지는 이미 보냈다.
+	sizes    map[int64]int64
+	total    int64
+	readOff  int64
+	// 마지막 세그먼트에 덧붙이는 파일. nil이면 다음 append에서 새 세그먼트를 만든다.
+	writer *os.File
+
+	// 아직 보내지 않은 바이트 수. 통계를 위해 다른 고루틴에서 읽는다.
+	pendingBytes atomic.Int64
+}
+
+func openSpool(dir string, maxSize int64) (*spool, error) {
+	if !strings.HasSuffix(dir, "/") {
+		dir += "/"
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	s := &spool{dir: dir, maxSize: maxSize, sizes: make(map[int64]int64)}
+	for _, entry := range entries {
+		seq, err := strconv.ParseInt(strings.TrimSuffix(entry.Name(), ".seg"), 10, 64)
+		if err != nil || !strings.HasSuffix(entry.Name(), ".seg") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		s.segments = append(s.segments, seq)
+		s.sizes[seq] = info.Size()
+		s.total += info.Size()
+	}
+	sort.Slice(s.segments, func(i, j int) bool { return s.segments[i] < s.segments[j] })
+
+	var cursorSeq, cursorOff int64
+	if data, err := os.ReadFile(s.cursorPath()); err == nil {
+		fmt.Sscanf(string(data), "%d %d", &cursorSeq, &cursorOff)
+	}
+	for len(s.segments) > 0 && s.segments[0] < cursorSeq {
+		s.removeFirst()
+	}
+	if len(s.segments) > 0 && s.segments[0] == cursorSeq {
+		s.readOff = cursorOff
+	}
+	s.updatePending()
+	return s, nil
+}
+
+func (s *spool) segmentPath(seq int64) string {
+	return fmt.Sprintf("%s%016d.seg", s.dir, seq)
+}
+
+func (s *spool) cursorPath() string {
+	return s.dir + "cursor"
+}
+
+// 다시 보낼 이벤트가 있는지 확인한다.
+func (s *spool) pending() bool {
+	return len(s.segments) > 1 || (len(s.segments) == 1 && s.readOff < s.sizes[s.segments[0]])
+}
+
+// 배치를 마지막 세그먼트에 덧붙인다. 전체 크기가 maxSize를 넘으면 버린 이벤트 수를 돌려준다.
+func (s *spool) append(batch []json.RawMessage) (int, error) {
+	if s.writer == nil {
+		seq := int64(1)
+		if len(s.segments) > 0 {
+			seq = s.segments[len(s.segments)-1] + 1
+		}
+		file, err := os.OpenFile(s.segmentPath(seq), os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return 0, err
+		}
+		s.writer = file
+		s.segments = append(s.segments, seq)
+		s.sizes[seq] = 0
+		if len(s.segments) == 1 {
+			s.readOff = 0
+		}
+	}
+
+	var buf bytes.Buffer
+	for _, payload := range batch {
+		buf.Write(payload)
+		buf.WriteByte('\n')
+	}
+	last := s.segments[len(s.segments)-1]
+	n, err := s.writer.Write(buf.Bytes())
+	s.sizes[last] += int64(n)
+	s.total += int64(n)
+	if err != nil {
+		s.updatePending()
+		return 0, err
+	}
+	if s.sizes[last] >= spoolSegmentSize {
+		s.writer.Close()
+		s.writer = nil
+	}
+	dropped := s.trim()
+	s.updatePending()
+	return dropped, nil
+}
+
+// maxSize를 넘으면 가장 오래된 세그먼트부터 지우고, 보내지 못하고 버린 이벤트 수를 돌려준다.
+func (s *spool) trim() int {
+	dropped := 0
+	for s.total > s.maxSize && len(s.segments) > 1 {
+		if data, err := os.ReadFile(s.segmentPath(s.segments[0])); err == nil && int64(len(data)) > s.readOff {
+			dropped += bytes.Count(data[s.readOff:], []byte{'\n'})
+		}
+		s.removeFirst()
+	}
+	return dropped
+}
+
+// 첫 세그먼트에서 아직 보내지 않은 이벤트를 max개까지 읽는다. next는 읽은 다음 위치이며,
+// eof이면 세그먼트 끝까지 읽은 것이다. 쓰다가 끊긴 마지막 줄은 읽지 않는다.
+func (s *spool) peek(max int) (batch []json.RawMessage, next int64, eof bool, err error) {
+	file, err := os.Open(s.segmentPath(s.segments[0]))
+	if err != nil {
+		return nil, 0, false, err
+	}
+	defer file.Close()
+	if _, err := file.Seek(s.readOff, io.SeekStart); err != nil {
+		return nil, 0, false, err
+	}
+	reader := bufio.NewReader(file)
+	next = s.readOff
+	for len(batch) < max {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			return batch, next, true, nil
+		}
+		next += int64(len(line))
+		if line = bytes.TrimSpace(line); json.Valid(line) {
+			batch = append(batch, json.RawMessage(line))
+		}
+	}
+	_, err = reader.Peek(1)
+	return batch, next, err != nil, nil
+}
+
+// seq 세그먼트를 아직 읽고 있는지 알려 준다. peek과 commit 사이에 trim이 세그먼트를 지웠으면 false이다.
+func (s *spool) reading(seq int64) bool {
+	return len(s.segments) > 0 && s.segments[0] == seq
+}
+
+// peek으로 읽은 이벤트를 보냈다고 기록한다. 끝까지 보낸 세그먼트는 지운다.
+func (s *spool) commit(next int64, eof bool) {
+	writing := s.writer != nil && len(s.segments) == 1
+	if eof && writing && next >= s.sizes[s.segments[0]] {
+		s.writer.Close()
+		s.writer = nil
+		writing = false
+	}
+	if eof && !writing {
+		s.removeFirst()
+	} else {
+		s.readOff = next
+		s.saveCursor()
+	}
+	s.updatePending()
+}
+
+func (s *spool) removeFirst() {
+	seq := s.segments[0]
+	os.Remove(s.segmentPath(seq))
+	s.total -= s.sizes[seq]
+	delete(s.sizes, seq)
+	s.segments = s.segments[1:]
+	s.readOff = 0
+	s.saveCursor()
+}
+
+// cursor 파일에 "세그먼트 번호 위치"를 남긴다. 임시 파일을 쓴 뒤 이름을 바꿔 반쯤 쓴 cursor가 남지 않게 한다.
+func (s *spool) saveCursor() {
+	if len(s.segments) == 0 {
+		os.Remove(s.cursorPath())
+		return
+	}
+	tmp := s.cursorPath() + ".tmp"
+	if err := os.WriteFile(tmp, []byte(fmt.Sprintf("%d %d\n", s.segments[0], s.readOff)), 0600); err == nil {
+		os.Rename(tmp, s.cursorPath())
+	}
+}
+
+func (s *spool) updatePending() {
+	s.pendingBytes.Store(s.total - s.readOff)
+}
+
+func (s *spool) close() {
+	if s.writer != nil {
+		s.writer.Close()
+		s.writer = nil
+	}
+	s.saveCursor()
+}
@@ -0,0 +1,206 @@
+// Copyright Authors of HActiV
+
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+func testEvents(from, to int) []json.RawMessage {
+	var batch []json.RawMessage
+	for i := from; i < to; i++ {
+		batch = append(batch, json.RawMessage(fmt.Sprintf(`{"seq":%d}`, i)))
+	}
+	return batch
+}
+
+func joinEvents(batch []json.RawMessage) string {
+	lines := make([]string, len(batch))
+	for i, payload := range batch {
+		lines[i] = string(payload)
+	}
+	return strings.Join(lines, ",")
+}
+
+// 스풀에 남은 이벤트를 모두 읽고 보낸 것으로 기록한다.
+func drainSpool(t *testing.T, s *spool, max int) []json.RawMessage {
+	t.Helper()
+	var all []json.RawMessage
+	for s.pending() {
+		batch, next, eof, err := s.peek(max)
+		if err != nil {
+			t.Fatalf("peek: %v", err)
+		}
+		all = append(all, batch...)
+		s.commit(next, eof)
+	}
+	return all
+}
+
+func TestSpoolPeekSkipsTornAndInvalidLines(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"complete lines", "{\"seq\":0}\n{\"seq\":1}\n", `{"seq":0},{"seq":1}`},
+		{"torn last line", "{\"seq\":0}\n{\"seq\":1}\n{\"se", `{"seq":0},{"seq":1}`},
+		{"only torn line", "{\"seq\":0", ``},
+		{"invalid line", "{\"seq\":0}\nnot json\n{\"seq\":2}\n", `{"seq":0},{"seq":2}`},
+		{"blank line", "{\"seq\":0}\n\n{\"seq\":2}\n", `{"seq":0},{"seq":2}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir() + "/"
+			if err := os.WriteFile(dir+fmt.Sprintf("%016d.seg", 1), []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			s, err := openSpool(dir, 1<<30)
+			if err != nil {
+				t.Fatal(err)
+			}
+			batch, _, eof, err := s.peek(100)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := joinEvents(batch); got != tt.want {
+				t.Errorf("peek = %s, want %s", got, tt.want)
+			}
+			if !eof {
+				t.Error("peek did not reach the end of the segment")
+			}
+		})
+	}
+}
+
+func TestSpoolCursorSurvivesReopen(t *testing.T) {
+	tests := []struct {
+		name      string
+		appended  int
+		committed int // 다시 열기 전에 보낸 것으로 기록한 이벤트 수
+		want      string
+	}{
+		{"nothing sent", 5, 0, `{"seq":0},{"seq":1},{"seq":2},{"seq":3},{"seq":4}`},
+		{"partly sent", 5, 2, `{"seq":2},{"seq":3},{"seq":4}`},
+		{"all sent", 5, 5, ``},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			s, err := openSpool(dir, 1<<30)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.append(testEvents(0, tt.appended)); err != nil {
+				t.Fatal(err)
+			}
+			if tt.committed > 0 {
+				batch, next, eof, err := s.peek(tt.committed)
+				if err != nil || len(batch) != tt.committed {
+					t.Fatalf("peek = %d events, %v", len(batch), err)
+				}
+				s.commit(next, eof)
+			}
+			s.close()
+
+			reopened, err := openSpool(dir, 1<<30)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := joinEvents(drainSpool(t, reopened, 2)); got != tt.want {
+				t.Errorf("after reopen = %s, want %s", got, tt.want)
+			}
+			if reopened.pendingBytes.Load() != 0 {
+				t.Errorf("pendingBytes = %d after sending everything", reopened.pendingBytes.Load())
+			}
+		})
+	}
+}
+
+func TestSpoolReopenRemovesSentSegments(t *testing.T) {
+	dir := t.TempDir() + "/"
+	for seq, content := range map[int]string{1: "{\"seq\":0}\n", 2: "{\"seq\":1}\n{\"seq\":2}\n", 3: "{\"seq\":3}\n"} {
+		if err := os.WriteFile(fmt.Sprintf("%s%016d.seg", dir, seq), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 세그먼트 2의 첫 이벤트까지 보냈다.
+	if err := os.WriteFile(dir+"cursor", []byte("2 10\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	s, err := openSpool(dir, 1<<30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(fmt.Sprintf("%s%016d.seg", dir, 1)); !os.IsNotExist(err) {
+		t.Errorf("segment before the cursor was not removed: %v", err)
+	}
+	if got, want := joinEvents(drainSpool(t, s, 10)), `{"seq":2},{"seq":3}`; got != want {
+		t.Errorf("replayed %s, want %s", got, want)
+	}
+}
+
+func TestSpoolTrimCountsDroppedEvents(t *testing.T) {
+	// 이벤트 하나가 1MB라 배치 하나(5MB)를 쓰면 세그먼트(4MB)가 닫힌다.
+	payload := func(i int) json.RawMessage {
+		return json.RawMessage(fmt.Sprintf(`{"seq":%d,"pad":"%s"}`, i, strings.Repeat("x", 1<<20)))
+	}
+	batch := func(from int) []json.RawMessage {
+		var events []json.RawMessage
+		for i := from; i < from+5; i++ {
+			events = append(events, payload(i))
+		}
+		return events
+	}
+	tests := []struct {
+		name      string
+		committed int // 첫 세그먼트에서 보낸 것으로 기록한 이벤트 수
+		dropped   int
+		firstSeq  int
+	}{
+		{"nothing sent", 0, 5, 5},
+		{"partly sent", 2, 3, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := openSpool(t.TempDir(), 2*spoolSegmentSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.close()
+			if dropped, err := s.append(batch(0)); err != nil || dropped != 0 {
+				t.Fatalf("first append dropped %d, %v", dropped, err)
+			}
+			if tt.committed > 0 {
+				_, next, eof, err := s.peek(tt.committed)
+				if err != nil {
+					t.Fatal(err)
+				}
+				s.commit(next, eof)
+			}
+			dropped, err := s.append(batch(5))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if dropped != tt.dropped {
+				t.Errorf("dropped = %d, want %d", dropped, tt.dropped)
+			}
+			if s.total > s.maxSize {
+				t.Errorf("total %d exceeds maxSize %d", s.total, s.maxSize)
+			}
+			events, _, _, err := s.peek(1)
+			if err != nil || len(events) != 1 {
+				t.Fatalf("peek = %d events, %v", len(events), err)
+			}
+			var first struct{ Seq int }
+			json.Unmarshal(events[0], &first)
+			if first.Seq != tt.firstSeq {
+				t.Errorf("oldest remaining event = %d, want %d", first.Seq, tt.firstSeq)
+			}
+		})
+	}
+}
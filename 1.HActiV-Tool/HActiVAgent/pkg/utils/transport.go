@@ -0,0 +1,566 @@
+// Copyright Authors of HActiV
+
+// utils package for helping other package
+package utils
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 서버 전송 설정. Setting.json의 Send* 항목으로 바꿀 수 있다.
+type TransportSetting struct {
+	QueueSize     int           // 보내지 못하고 기다리는 이벤트의 최대 수. 넘으면 새 이벤트를 버린다.
+	BatchSize     int           // 한 요청에 담는 최대 이벤트 수
+	BatchInterval time.Duration // 배치가 차지 않아도 이 시간이 지나면 보낸다.
+	Timeout       time.Duration // 요청 하나의 제한 시간
+	Retries       int           // 실패한 요청을 다시 보내는 최대 횟수
+	Gzip          bool
+	// 보내지 못한 이벤트를 LogLocation/spool/ 아래에 보관하는 최대 크기(바이트). 0이면 보관하지 않는다.
+	SpoolMaxSize int64
+	// 이벤트를 보낼 곳. http는 DataUrl, kafka는 KafkaBrokers, both는 둘 다, grpc는 GrpcAddr이다. 알림은 항상 RuleUrl로 보낸다.
+	DataSink     string
+	KafkaBrokers []string
+	// 서버 gRPC 주소. 비어 있지 않으면 DataSink와 관계없이 규칙 업데이트를 받는 스트림을 연다.
+	GrpcAddr string
+	GrpcTLS  bool
+	// 서버 인증서를 확인할 CA 인증서(PEM). 비어 있으면 시스템 CA를 쓴다.
+	GrpcCAFile string
+	// TLS 없이 받은 규칙 업데이트도 적용한다. 규칙 파일은 root 권한으로 쓰므로 신뢰할 수 있는 망에서만 켠다.
+	GrpcInsecureRuleUpdates bool
+}
+
+var defaultTransportSetting = TransportSetting{
+	QueueSize:     10000,
+	BatchSize:     100,
+	BatchInterval: 500 * time.Millisecond,
+	Timeout:       10 * time.Second,
+	Retries:       5,
+	Gzip:          true,
+	SpoolMaxSize:  256 << 20,
+	DataSink:      "http",
+}
+
+const (
+	retryBaseDelay = 500 * time.Millisecond
+	retryMaxDelay  = 30 * time.Second
+	// 버린 이벤트가 있으면 이 주기로 전송 통계를 출력한다.
+	transportReportInterval = time.Minute
+)
+
+// 전송 통계. Dropped는 큐나 스풀이 가득 차 버린 이벤트, Failed는 다시 보내도 실패해 버린 이벤트,
+// Spooled는 서버에 보내지 못해 디스크에 보관한 이벤트 수이다. SpoolBytes는 아직 다시 보내지 않은 크기이다.
+type TransportStats struct {
+	Sent       uint64
+	Dropped    uint64
+	Failed     uint64
+	Spooled    uint64
+	Queued     int
+	SpoolBytes int64
+}
+
+func (s TransportStats) String() string {
+	text := fmt.Sprintf("sent %d, dropped %d, failed %d, queued %d", s.Sent, s.Dropped, s.Failed, s.Queued)
+	if s.Spooled > 0 || s.SpoolBytes > 0 {
+		text += fmt.Sprintf(", spooled %d (%d bytes pending)", s.Spooled, s.SpoolBytes)
+	}
+	return text
+}
+
+// 모니터의 perf 루프를 막지 않도록 이벤트를 큐에 넣고, 백그라운드에서 배치로 묶어 보낸다.
+// 서버가 느리거나 응답하지 않으면 큐가 차고, 가득 찬 뒤의 이벤트는 기다리지 않고 버린다.
+// 스풀이 있으면 보내지 못한 배치를 디스크에 보관하고, 서버가 돌아오면 보관한 순서대로 다시 보낸다.
+type sender struct {
+	name    string
+	url     string
+	setting TransportSetting
+	client  *http.Client
+	queue   chan json.RawMessage
+	stop    chan struct{}
+	done    chan struct{}
+	spool   *spool
+
+	// 스풀을 다시 보내는 데 실패하면 nextReplay까지 기다린다. 실패할 때마다 replayDelay를 두 배로 늘린다.
+	nextReplay  time.Time
+	replayDelay time.Duration
+	replaying   *spooledBatch
+
+	sent    atomic.Uint64
+	dropped atomic.Uint64
+	failed  atomic.Uint64
+	spooled atomic.Uint64
+}
+
+var (
+	dataSender *sender
+	ruleSender *sender
+)
+
+// Setting.json의 Send* 항목을 읽는다. 값이 없거나 올바르지 않으면 기본값을 쓴다.
+func parseTransportSetting(values map[string]string) TransportSetting {
+	setting := defaultTransportSetting
+	positive := func(key string, target *int) {
+		if n, err := strconv.Atoi(strings.TrimSpace(values[key])); err == nil && n > 0 {
+			*target = n
+		}
+	}
+	duration := func(key string, target *time.Duration) {
+		if d, err := time.ParseDuration(strings.TrimSpace(values[key])); err == nil && d > 0 {
+			*target = d
+		}
+	}
+	if n, err := strconv.ParseInt(strings.TrimSpace(values["SpoolMaxSizeMB"]), 10, 64); err == nil && n >= 0 {
+		// 세그먼트 두 개보다 작으면 쓰고 있는 세그먼트만 남아 버릴 수 없다.
+		setting.SpoolMaxSize = n << 20
+		if n > 0 {
+			setting.SpoolMaxSize = max(setting.SpoolMaxSize, 2*spoolSegmentSize)
+		}
+	}
+	positive("SendQueueSize", &setting.QueueSize)
+	positive("SendBatchSize", &setting.BatchSize)
+	duration("SendBatchInterval", &setting.BatchInterval)
+	duration("SendTimeout", &setting.Timeout)
+	if n, err := strconv.Atoi(strings.TrimSpace(values["SendRetries"])); err == nil && n >= 0 {
+		setting.Retries = n
+	}
+	if b, err := strconv.ParseBool(strings.TrimSpace(values["SendGzip"])); err == nil {
+		setting.Gzip = b
+	}
+	for _, broker := range strings.Split(values["KafkaBrokers"], ",") {
+		if broker = strings.TrimSpace(broker); broker != "" {
+			setting.KafkaBrokers = append(setting.KafkaBrokers, broker)
+		}
+	}
+	setting.GrpcAddr = strings.TrimSpace(values["GrpcAddr"])
+	if b, err := strconv.ParseBool(strings.TrimSpace(values["GrpcTLS"])); err == nil {
+		setting.GrpcTLS = b
+	}
+	setting.GrpcCAFile = strings.TrimSpace(values["GrpcCAFile"])
+	if b, err := strconv.ParseBool(strings.TrimSpace(values["GrpcInsecureRuleUpdates"])); err == nil {
+		setting.GrpcInsecureRuleUpdates = b
+	}
+	switch sink := strings.ToLower(strings.TrimSpace(values["DataSink"])); sink {
+	case "":
+	case "http", "kafka", "both":
+		setting.DataSink = sink
+		if sink != "http" && len(setting.KafkaBrokers) == 0 {
+			fmt.Printf("DataSink가 %s이지만 KafkaBrokers가 비어 있어 http를 사용합니다.\n", sink)
+			setting.DataSink = "http"
+		}
+	case "grpc":
+		setting.DataSink = sink
+		if setting.GrpcAddr == "" {
+			fmt.Println("DataSink가 grpc이지만 GrpcAddr이 비어 있어 http를 사용합니다.")
+			setting.DataSink = "http"
+		}
+	default:
+		fmt.Printf("DataSink '%s'이(가) 올바르지 않아 기본값 http를 사용합니다.\n", sink)
+	}
+	return setting
+}
+
+func startTransport(setting TransportSetting) {
+	fmt.Printf("Transport: sink %s, batch %d / %s, queue %d, timeout %s, retries %d, gzip %t, spool %dMB\n",
+		setting.DataSink, setting.BatchSize, setting.BatchInterval, setting.QueueSize, setting.Timeout, setting.Retries, setting.Gzip, setting.SpoolMaxSize>>20)
+	if setting.GrpcAddr != "" {
+		var err error
+		if grpcStream, err = newGrpcSink(setting); err != nil {
+			fmt.Printf("[전송] grpc: %s 연결을 설정할 수 없어 규칙 업데이트를 받지 않습니다: %v\n", setting.GrpcAddr, err)
+			if setting.DataSink == "grpc" {
+				fmt.Println("[전송] grpc: 이벤트는 http로 보냅니다.")
+				setting.DataSink = "http"
+			}
+		}
+	}
+	if setting.DataSink == "http" || setting.DataSink == "both" {
+		dataSender = newSender("data", dataUrl, setting)
+	}
+	if setting.DataSink == "kafka" || setting.DataSink == "both" {
+		dataKafka = newKafkaSink(setting.KafkaBrokers, newKafkaConfig(setting))
+	}
+	ruleSender = newSender("alert", ruleUrl, setting)
+}
+
+func newSender(name, url string, setting TransportSetting) *sender {
+	s := &sender{
+		name:        name,
+		url:         url,
+		setting:     setting,
+		client:      &http.Client{Timeout: setting.Timeout},
+		queue:       make(chan json.RawMessage, setting.QueueSize),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+		replayDelay: retryBaseDelay,
+	}
+	if setting.SpoolMaxSize > 0 {
+		var err error
+		if s.spool, err = openSpool(LogLocation+"spool/"+name, setting.SpoolMaxSize); err != nil {
+			fmt.Printf("[전송] %s: 스풀을 열 수 없어 보내지 못한 이벤트를 보관하지 않습니다: %v\n", name, err)
+		} else if s.spool.pending() {
+			fmt.Printf("[전송] %s: 이전에 보내지 못한 이벤트 %d bytes를 다시 보냅니다.\n", name, s.spool.pendingBytes.Load())
+		}
+	}
+	go s.run()
+	go report(s.name, s.stats, s.done)
+	return s
+}
+
+// 큐가 가득 차 있으면 기다리지 않고 버린다.
+func (s *sender) enqueue(data interface{}) {
+	if s == nil {
+		return
+	}
+	payload, err := json.Marshal(data)
+	if err != nil {
+		fmt.Println("JSON 변환 오류:", err)
+		return
+	}
+	select {
+	case s.queue <- payload:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+func (s *sender) run() {
+	defer close(s.done)
+	batch := make([]json.RawMessage, 0, s.setting.BatchSize)
+	timer := time.NewTimer(s.setting.BatchInterval)
+	defer timer.Stop()
+	for {
+		select {
+		case payload := <-s.queue:
+			batch = append(batch, payload)
+			if len(batch) < s.setting.BatchSize {
+				continue
+			}
+		case <-timer.C:
+		case <-s.stop:
+			s.drain(batch)
+			return
+		}
+		s.flush(batch)
+		batch = batch[:0]
+		s.replay()
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(s.setting.BatchInterval)
+	}
+}
+
+// 종료할 때 큐에 남은 이벤트를 모두 보낸다. 스풀이 있으면 보내지 못한 이벤트는 다음 실행에서 다시 보낸다.
+func (s *sender) drain(batch []json.RawMessage) {
+	for {
+		select {
+		case payload := <-s.queue:
+			batch = append(batch, payload)
+			if len(batch) < s.setting.BatchSize {
+				continue
+			}
+		default:
+			s.flush(batch)
+			return
+		}
+		s.flush(batch)
+		batch = batch[:0]
+	}
+}
+
+// 배치를 JSON 배열 하나로 보낸다. 연결 오류, 429, 5xx는 간격을 두 배씩 늘리며 다시 보낸다.
+// 스풀이 있으면 기다리지 않고 스풀에 넣는다. 스풀에 남은 이벤트가 있으면 순서를 지키도록 보내지 않고 바로 스풀에 넣는다.
+// 서버가 일부만 저장했다고 207로 응답하면 429, 5xx로 실패한 이벤트만 같은 방법으로 다시 보낸다.
+func (s *sender) flush(batch []json.RawMessage) {
+	if len(batch) == 0 {
+		return
+	}
+	if s.spool != nil && s.spool.pending() {
+		s.spoolBatch(batch)
+		return
+	}
+	body, err := s.encode(batch)
+	if err != nil {
+		fmt.Println("전송 데이터 변환 오류:", err)
+		s.failed.Add(uint64(len(batch)))
+		return
+	}
+
+	delay := retryBaseDelay
+	for attempt := 0; ; attempt++ {
+		failures, retryable, err := s.post(body)
+		if err == nil {
+			retry := s.partial(batch, failures)
+			if len(retry) == 0 {
+				return
+			}
+			batch = retry
+			if body, err = s.encode(batch); err != nil {
+				fmt.Println("전송 데이터 변환 오류:", err)
+				s.failed.Add(uint64(len(batch)))
+				return
+			}
+			retryable, err = true, fmt.Errorf("서버가 이벤트 %d개를 저장하지 못했습니다", len(batch))
+		}
+		if retryable && s.spool != nil {
+			fmt.Printf("[전송] %s: 서버에 보내지 못해 디스크에 보관합니다: %v\n", s.name, err)
+			s.spoolBatch(batch)
+			return
+		}
+		if !retryable || attempt >= s.setting.Retries {
+			fmt.Printf("[전송] %s: 이벤트 %d개를 보내지 못했습니다: %v\n", s.name, len(batch), err)
+			s.failed.Add(uint64(len(batch)))
+			return
+		}
+		time.Sleep(delay)
+		delay = min(delay*2, retryMaxDelay)
+	}
+}
+
+func (s *sender) spoolBatch(batch []json.RawMessage) {
+	dropped, err := s.spool.append(batch)
+	if err != nil {
+		fmt.Printf("[전송] %s: 이벤트 %d개를 디스크에 보관하지 못했습니다: %v\n", s.name, len(batch), err)
+		s.failed.Add(uint64(len(batch)))
+		return
+	}
+	s.spooled.Add(uint64(len(batch)))
+	s.dropped.Add(uint64(dropped))
+}
+
+// 스풀에 보관한 이벤트를 오래된 것부터 보낸다. 큐를 오래 멈추지 않도록 한 번에 BatchInterval 동안만 보낸다.
+// 서버가 일부 이벤트만 저장하면 나머지를 같은 자리에서 다시 보내고, 모두 처리한 뒤에 커서를 옮긴다.
+// 그 사이에 에이전트가 멈추면 다음 실행에서 배치 전체를 다시 보내므로 이미 저장된 이벤트가 중복될 수 있다.
+func (s *sender) replay() {
+	if s.spool == nil || !s.spool.pending() || time.Now().Before(s.nextReplay) {
+		return
+	}
+	deadline := time.Now().Add(s.setting.BatchInterval)
+	for s.spool.pending() && time.Now().Before(deadline) {
+		if s.replaying != nil && !s.spool.reading(s.replaying.seq) {
+			// 스풀이 가득 차 trim이 읽던 세그먼트를 지웠다. 남은 이벤트는 버린 것으로 이미 셌다.
+			s.replaying = nil
+		}
+		if s.replaying == nil {
+			seq := s.spool.segments[0]
+			batch, next, eof, err := s.spool.peek(s.setting.BatchSize)
+			if err != nil {
+				fmt.Printf("[전송] %s: 스풀을 읽을 수 없습니다: %v\n", s.name, err)
+				s.nextReplay = time.Now().Add(retryMaxDelay)
+				return
+			}
+			s.replaying = &spooledBatch{events: batch, seq: seq, next: next, eof: eof}
+		}
+		batch := s.replaying.events
+		if len(batch) > 0 {
+			body, err := s.encode(batch)
+			var failures []batchFailure
+			retryable := false
+			if err == nil {
+				failures, retryable, err = s.post(body)
+			}
+			if err == nil && !retryable {
+				if retry := s.partial(batch, failures); len(retry) > 0 {
+					s.replaying.events = retry
+					retryable = true
+				}
+			}
+			if retryable {
+				s.nextReplay = time.Now().Add(s.replayDelay)
+				s.replayDelay = min(s.replayDelay*2, retryMaxDelay)
+				return
+			}
+			if err != nil {
+				fmt.Printf("[전송] %s: 보관한 이벤트 %d개를 보내지 못했습니다: %v\n", s.name, len(batch), err)
+				s.failed.Add(uint64(len(batch)))
+			}
+		}
+		s.spool.commit(s.replaying.next, s.replaying.eof)
+		s.replaying = nil
+		s.replayDelay = retryBaseDelay
+	}
+	if !s.spool.pending() {
+		fmt.Printf("[전송] %s: 보관한 이벤트를 모두 보냈습니다.\n", s.name)
+	}
+}
+
+// peek으로 읽었지만 아직 커서를 옮기지 않은 배치. events는 아직 서버에 저장되지 않은 이벤트이다.
+type spooledBatch struct {
+	events []json.RawMessage
+	seq    int64
+	next   int64
+	eof    bool
+}
+
+// 서버가 207 응답으로 알려 준 이벤트별 실패. index는 요청 배치 안의 위치이다.
+type batchFailure struct {
+	Index   int    `json:"index"`
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+// 저장된 이벤트를 보낸 것으로 세고, 429, 5xx로 실패한 이벤트는 다시 보내도록 돌려준다.
+// 그 밖의 상태로 실패한 이벤트는 다시 보내도 실패하므로 버린다.
+func (s *sender) partial(batch []json.RawMessage, failures []batchFailure) []json.RawMessage {
+	var retry []json.RawMessage
+	rejected := 0
+	for _, failure := range failures {
+		if failure.Index < 0 || failure.Index >= len(batch) {
+			continue
+		}
+		if retryableStatus(failure.Status) {
+			retry = append(retry, batch[failure.Index])
+			continue
+		}
+		if rejected == 0 {
+			fmt.Printf("[전송] %s: 서버가 이벤트를 거부했습니다: %d %s\n", s.name, failure.Status, failure.Message)
+		}
+		rejected++
+	}
+	s.sent.Add(uint64(len(batch) - len(retry) - rejected))
+	s.failed.Add(uint64(rejected))
+	return retry
+}
+
+func retryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+func (s *sender) encode(batch []json.RawMessage) ([]byte, error) {
+	array, err := json.Marshal(batch)
+	if err != nil || !s.setting.Gzip {
+		return array, err
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(array); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// 배치를 보낸다. 서버가 207로 응답하면 저장하지 못한 이벤트 목록을 돌려준다.
+// 오류이면 같은 배치를 다시 보내도 되는지(연결 오류, 429, 5xx)를 함께 돌려준다.
+func (s *sender) post(body []byte) ([]batchFailure, bool, error) {
+	req, err := http.NewRequest("POST", s.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", apiKey)
+	if s.setting.Gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, true, err
+	}
+	defer resp.Body.Close()
+	defer io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode == http.StatusMultiStatus {
+		var result struct {
+			Failures []batchFailure `json:"failures"`
+		}
+		if err := json.NewDecoder(io.LimitReader(resp.Body, 4<<20)).Decode(&result); err != nil {
+			// 어느 이벤트를 저장하지 못했는지 알 수 없으면 잃지 않도록 배치 전체를 다시 보낸다.
+			return nil, true, fmt.Errorf("207 응답을 읽을 수 없습니다: %v", err)
+		}
+		return result.Failures, false, nil
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil, false, nil
+	}
+	return nil, retryableStatus(resp.StatusCode), fmt.Errorf("응답 상태 %s", resp.Status)
+}
+
+func (s *sender) stats() TransportStats {
+	stats := TransportStats{Sent: s.sent.Load(), Dropped: s.dropped.Load(), Failed: s.failed.Load(), Spooled: s.spooled.Load(), Queued: len(s.queue)}
+	if s.spool != nil {
+		stats.SpoolBytes = s.spool.pendingBytes.Load()
+	}
+	return stats
+}
+
+// 버리거나 보내지 못하거나 디스크에 보관한 이벤트가 늘었을 때만 통계를 출력한다.
+func report(name string, current func() TransportStats, done <-chan struct{}) {
+	var last TransportStats
+	ticker := time.NewTicker(transportReportInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-done:
+			return
+		}
+		stats := current()
+		if stats.Dropped != last.Dropped || stats.Failed != last.Failed || stats.Spooled != last.Spooled {
+			fmt.Printf("[전송] %s: %s\n", name, stats)
+		}
+		last = stats
+	}
+}
+
+// 큐에 남은 이벤트를 timeout 동안 보내고 통계를 출력한다. 종료할 때 한 번만 부른다.
+func CloseTransport(timeout time.Duration) {
+	var wg sync.WaitGroup
+	for _, s := range []*sender{dataSender, ruleSender} {
+		if s == nil {
+			continue
+		}
+		close(s.stop)
+		wg.Add(1)
+		go func(s *sender) {
+			defer wg.Done()
+			select {
+			case <-s.done:
+				if s.spool != nil {
+					s.spool.close()
+				}
+			case <-time.After(timeout):
+			}
+			fmt.Printf("[전송] %s: %s\n", s.name, s.stats())
+		}(s)
+	}
+	wait := func(name string, done <-chan struct{}, stats func() TransportStats) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case <-done:
+			case <-time.After(timeout):
+			}
+			fmt.Printf("[전송] %s: %s\n", name, stats())
+		}()
+	}
+	if dataKafka != nil {
+		dataKafka.close()
+		wait("kafka", dataKafka.done, dataKafka.stats)
+	}
+	if grpcStream != nil {
+		grpcStream.close()
+		wait("grpc", grpcStream.done, grpcStream.stats)
+	}
+	wg.Wait()
+	// 스트림 고루틴이 끝난 뒤에만 스풀을 닫는다. 끝나지 않았으면 남은 이벤트는 다음 실행에서 커서부터 다시 보낸다.
+	if grpcStream != nil && grpcStream.spool != nil {
+		select {
+		case <-grpcStream.done:
+			grpcStream.spool.close()
+		default:
+		}
+	}
+}
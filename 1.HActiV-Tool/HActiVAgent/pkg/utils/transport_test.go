@@ -0,0 +1,262 @@
+// Copyright Authors of HActiV
+
+package utils
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 받은 배치를 기록하고 미리 정한 응답을 순서대로 돌려주는 서버. 응답이 모자라면 200을 돌려준다.
+type batchServer struct {
+	mu        sync.Mutex
+	batches   [][]json.RawMessage
+	responses []serverResponse
+	server    *httptest.Server
+}
+
+type serverResponse struct {
+	status int
+	body   string
+}
+
+func newBatchServer(t *testing.T, responses ...serverResponse) *batchServer {
+	b := &batchServer{responses: responses}
+	b.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var reader io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				t.Errorf("gzip: %v", err)
+				return
+			}
+			reader = zr
+		}
+		var batch []json.RawMessage
+		if err := json.NewDecoder(reader).Decode(&batch); err != nil {
+			t.Errorf("decode batch: %v", err)
+		}
+
+		b.mu.Lock()
+		b.batches = append(b.batches, batch)
+		response := serverResponse{status: http.StatusOK}
+		if len(b.responses) > 0 {
+			response, b.responses = b.responses[0], b.responses[1:]
+		}
+		b.mu.Unlock()
+
+		w.WriteHeader(response.status)
+		io.WriteString(w, response.body)
+	}))
+	t.Cleanup(b.server.Close)
+	return b
+}
+
+func (b *batchServer) received() [][]json.RawMessage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([][]json.RawMessage(nil), b.batches...)
+}
+
+func testTransportSetting() TransportSetting {
+	setting := defaultTransportSetting
+	setting.SpoolMaxSize = 0
+	setting.BatchInterval = 10 * time.Millisecond
+	setting.Timeout = time.Second
+	return setting
+}
+
+func TestSenderEnqueueDropsWhenQueueIsFull(t *testing.T) {
+	tests := []struct {
+		name      string
+		queueSize int
+		enqueued  int
+		dropped   uint64
+	}{
+		{"within queue", 5, 5, 0},
+		{"queue full", 2, 5, 3},
+		{"unmarshalable payload", 2, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &sender{queue: make(chan json.RawMessage, tt.queueSize)}
+			for i := 0; i < tt.enqueued; i++ {
+				s.enqueue(map[string]int{"seq": i})
+			}
+			if tt.enqueued == 0 {
+				s.enqueue(make(chan int))
+			}
+			stats := s.stats()
+			if stats.Dropped != tt.dropped {
+				t.Errorf("dropped = %d, want %d", stats.Dropped, tt.dropped)
+			}
+			if want := tt.enqueued - int(tt.dropped); stats.Queued != want {
+				t.Errorf("queued = %d, want %d", stats.Queued, want)
+			}
+		})
+	}
+}
+
+func TestSenderBatchesQueuedEvents(t *testing.T) {
+	tests := []struct {
+		name      string
+		batchSize int
+		events    int
+		gzip      bool
+	}{
+		{"single batch", 10, 4, true},
+		{"several batches", 3, 10, true},
+		{"without gzip", 3, 7, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newBatchServer(t)
+			setting := testTransportSetting()
+			setting.BatchSize = tt.batchSize
+			setting.Gzip = tt.gzip
+			s := newSender("test", server.server.URL, setting)
+			for i := 0; i < tt.events; i++ {
+				s.enqueue(map[string]int{"seq": i})
+			}
+			close(s.stop)
+			<-s.done
+
+			total := 0
+			for _, batch := range server.received() {
+				if len(batch) > tt.batchSize {
+					t.Errorf("batch of %d events exceeds BatchSize %d", len(batch), tt.batchSize)
+				}
+				for _, payload := range batch {
+					var event struct{ Seq int }
+					json.Unmarshal(payload, &event)
+					if event.Seq != total {
+						t.Errorf("event %d arrived at position %d", event.Seq, total)
+					}
+					total++
+				}
+			}
+			if total != tt.events {
+				t.Errorf("server received %d events, want %d", total, tt.events)
+			}
+			if stats := s.stats(); stats.Sent != uint64(tt.events) || stats.Failed != 0 || stats.Dropped != 0 {
+				t.Errorf("stats: %s, want sent %d", stats, tt.events)
+			}
+		})
+	}
+}
+
+func TestSenderFlushRetries(t *testing.T) {
+	partial := serverResponse{http.StatusMultiStatus, `{"failures":[{"index":1,"status":500,"message":"db"},{"index":2,"status":400,"message":"invalid"}]}`}
+	tests := []struct {
+		name      string
+		responses []serverResponse
+		retries   int
+		requests  []int // 요청마다 보낸 이벤트 수
+		sent      uint64
+		failed    uint64
+		minDelay  time.Duration
+	}{
+		{"ok", nil, 1, []int{3}, 3, 0, 0},
+		{"server error then ok", []serverResponse{{status: 500}}, 1, []int{3, 3}, 3, 0, retryBaseDelay},
+		{"too many requests then ok", []serverResponse{{status: 429}}, 1, []int{3, 3}, 3, 0, retryBaseDelay},
+		{"retries exhausted", []serverResponse{{status: 503}, {status: 503}}, 1, []int{3, 3}, 0, 3, retryBaseDelay},
+		{"client error", []serverResponse{{status: 400}}, 1, []int{3}, 0, 3, 0},
+		{"no retries", []serverResponse{{status: 500}}, 0, []int{3}, 0, 3, 0},
+		{"partial failure resends retryable events", []serverResponse{partial}, 1, []int{3, 1}, 2, 1, retryBaseDelay},
+		{"unreadable partial response", []serverResponse{{status: 207, body: "{"}}, 1, []int{3, 3}, 3, 0, retryBaseDelay},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newBatchServer(t, tt.responses...)
+			setting := testTransportSetting()
+			setting.Retries = tt.retries
+			s := &sender{name: "test", url: server.server.URL, setting: setting, client: server.server.Client()}
+
+			start := time.Now()
+			s.flush(testEvents(0, 3))
+			elapsed := time.Since(start)
+
+			received := server.received()
+			if len(received) != len(tt.requests) {
+				t.Fatalf("server received %d requests, want %d", len(received), len(tt.requests))
+			}
+			for i, n := range tt.requests {
+				if len(received[i]) != n {
+					t.Errorf("request %d had %d events, want %d", i, len(received[i]), n)
+				}
+			}
+			if stats := s.stats(); stats.Sent != tt.sent || stats.Failed != tt.failed {
+				t.Errorf("stats: %s, want sent %d, failed %d", stats, tt.sent, tt.failed)
+			}
+			if elapsed < tt.minDelay {
+				t.Errorf("retried after %s, want at least %s", elapsed, tt.minDelay)
+			}
+		})
+	}
+}
+
+func TestSenderSpoolsAndReplays(t *testing.T) {
+	partial := serverResponse{http.StatusMultiStatus, `{"failures":[{"index":1,"status":500,"message":"db"},{"index":2,"status":400,"message":"invalid"}]}`}
+	server := newBatchServer(t, serverResponse{status: 503}, serverResponse{status: 503}, partial)
+	LogLocation = t.TempDir() + "/"
+	setting := testTransportSetting()
+	setting.SpoolMaxSize = 2 * spoolSegmentSize
+	s := newSender("test", server.server.URL, setting)
+	// run 고루틴을 멈추고 flush와 replay를 직접 부른다.
+	close(s.stop)
+	<-s.done
+
+	// 서버가 503이면 기다리지 않고 스풀에 넣고, 스풀에 남은 것이 있으면 다음 배치도 순서대로 스풀에 넣는다.
+	s.flush(testEvents(0, 3))
+	s.flush(testEvents(3, 5))
+	if stats := s.stats(); stats.Spooled != 5 || stats.Sent != 0 || len(server.received()) != 1 {
+		t.Fatalf("after failed flush: %s, %d requests", stats, len(server.received()))
+	}
+
+	// 다시 보내지 못하면 replayDelay만큼 기다리고, 실패할 때마다 두 배로 늘린다.
+	s.replay()
+	if s.replayDelay != 2*retryBaseDelay || !s.nextReplay.After(time.Now()) {
+		t.Errorf("after failed replay: replayDelay %s, nextReplay %s", s.replayDelay, s.nextReplay)
+	}
+	requests := len(server.received())
+	s.replay()
+	if len(server.received()) != requests {
+		t.Error("replay did not wait for nextReplay")
+	}
+
+	// 일부만 저장되면 다시 보낼 이벤트만 같은 자리에서 보내고, 그동안 커서와 backoff를 유지한다.
+	s.nextReplay = time.Time{}
+	s.replay()
+	if s.replayDelay != 4*retryBaseDelay || !s.nextReplay.After(time.Now()) || !s.spool.pending() {
+		t.Errorf("after partial replay: replayDelay %s, nextReplay %s, pending %t", s.replayDelay, s.nextReplay, s.spool.pending())
+	}
+	s.flush(testEvents(5, 6))
+
+	s.nextReplay = time.Time{}
+	s.replay()
+	if s.spool.pending() {
+		t.Error("spool still has events after a successful replay")
+	}
+	if s.replayDelay != retryBaseDelay {
+		t.Errorf("replayDelay = %s after a successful replay, want %s", s.replayDelay, retryBaseDelay)
+	}
+	var replayed []json.RawMessage
+	for _, batch := range server.received()[requests:] {
+		replayed = append(replayed, batch...)
+	}
+	want := append(testEvents(0, 5), testEvents(1, 2)...)
+	want = append(want, testEvents(5, 6)...)
+	if got, want := joinEvents(replayed), joinEvents(want); got != want {
+		t.Errorf("replayed %s, want %s", got, want)
+	}
+	if stats := s.stats(); stats.Sent != 5 || stats.Failed != 1 {
+		t.Errorf("stats: %s, want sent 5, failed 1", stats)
+	}
+	s.spool.close()
+}
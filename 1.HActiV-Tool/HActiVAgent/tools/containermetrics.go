@@ -172,8 +172,7 @@ func DisplayMetrics(cli *client.Client) {
 		fmt.Printf("네트워크 사용량: RX %.2f MB, TX %.2f MB\n", float64(rxBytes)/(1024*1024), float64(txBytes)/(1024*1024))
 		fmt.Println("-----------------------------")
 
-		utils.DataSend(
-			"ContainerMetrics",
+		utils.DataSend(utils.NewContainerMetrics(
 			time.Now().Format(time.RFC3339),
 			info.Name,
 			cpuUsage,
@@ -181,7 +180,7 @@ func DisplayMetrics(cli *client.Client) {
 			0.0,
 			rxBytes,
 			txBytes,
-		)
+		))
 	}
 }
 
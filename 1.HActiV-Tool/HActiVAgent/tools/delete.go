@@ -31,7 +31,7 @@ type DeleteEvent struct {
 }
 
 func DeleteMonitoring() {
-	policies, err := configs.LoadRules("delete")
+	policies, err := configs.RegisterRules("delete")
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "정책 로드 실패: %v\n", err)
 		os.Exit(1)
@@ -106,20 +106,23 @@ func DeleteMonitoring() {
 				filename := string(bytes.TrimRight(event.Filename[:], "\x00"))
 
 				matchevent := utils.Event{
-					Tool:          "delete",
-					Time:          time.Now().Format(time.RFC3339),
-					Uid:           event.Uid,
-					Gid:           event.Gid,
-					Pid:           event.Pid,
-					Filename:      filename,
-					ProcessName:   processName,
-					ContainerName: containerInfo.Name,
+					Tool:            "delete",
+					Time:            time.Now().Format(time.RFC3339),
+					Uid:             event.Uid,
+					Gid:             event.Gid,
+					Pid:             event.Pid,
+					Ppid:            event.PPid,
+					Filename:        filename,
+					ProcessName:     processName,
+					ContainerName:   containerInfo.Name,
+					ContainerImage:  containerInfo.Image,
+					ContainerLabels: containerInfo.Labels,
 				}
 
-				configs.MatchedEvent(policies, matchevent)
+				configs.MatchedEvent(policies.Load(), matchevent)
 				logger.Log(matchevent)
 				if configs.DataSend {
-					utils.DataSend("delete", matchevent.Time, containerInfo.Name, event.Uid, event.Gid, event.Pid, event.PPid, processName, filename)
+					utils.SendEvent(matchevent)
 				}
 			case lostCountData := <-lost:
 				lostCount += lostCountData
@@ -13,7 +13,6 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
-	"strings"
 	"syscall"
 	"time"
 
@@ -34,7 +33,7 @@ type execvevent struct {
 }
 
 func ExecveMonitoring() {
-	policies, err := configs.LoadRules("execve")
+	policies, err := configs.RegisterRules("execve")
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "정책 로드 실패: %v\n", err)
 		os.Exit(1)
@@ -99,24 +98,26 @@ func ExecveMonitoring() {
 
 				//matchevent Tool execve -> Systemcall 수정 Datasend와 일치 시키기 위해
 				matchevent := utils.Event{
-					Tool:          "Systemcall",
-					Time:          time.Now().Format(time.RFC3339),
-					Uid:           event.Uid,
-					Gid:           event.Gid,
-					Pid:           event.Pid,
-					Ppid:          event.Ppid,
-					Puid:          event.Puid,
-					Pgid:          event.Pgid,
-					Filename:      filename,
-					ProcessName:   processName,
-					Args:          args,
-					ContainerName: containerInfo.Name,
+					Tool:            "Systemcall",
+					Time:            time.Now().Format(time.RFC3339),
+					Uid:             event.Uid,
+					Gid:             event.Gid,
+					Pid:             event.Pid,
+					Ppid:            event.Ppid,
+					Puid:            event.Puid,
+					Pgid:            event.Pgid,
+					Filename:        filename,
+					ProcessName:     processName,
+					Args:            args,
+					ContainerName:   containerInfo.Name,
+					ContainerImage:  containerInfo.Image,
+					ContainerLabels: containerInfo.Labels,
 				}
 
-				configs.MatchedEvent(policies, matchevent)
+				configs.MatchedEvent(policies.Load(), matchevent)
 				logger.Log(matchevent)
 				if configs.DataSend {
-					utils.DataSend("Systemcall", matchevent.Time, containerInfo.Name, event.Uid, event.Gid, event.Pid, event.Ppid, filename, processName, strings.Replace(args, "--color=auto", "", 1))
+					utils.SendEvent(matchevent)
 				}
 			case lostCountData := <-lost:
 				lostCount += lostCountData
@@ -45,12 +45,11 @@ func HostMetrics() {
 	fmt.Printf("디스크 사용량: %.2f%%\n", diskStat.UsedPercent)
 	fmt.Println("=============================")
 
-	utils.DataSend(
-		"HostMetrics",
+	utils.DataSend(utils.NewHostMetrics(
 		time.Now().Format(time.RFC3339),
 		cpuPercent[0],
 		vmStat.UsedPercent,
 		diskStat.UsedPercent,
 		cpuCores,
-	)
+	))
 }
@@ -11,7 +11,6 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"os/signal"
 	"strconv"
@@ -75,8 +74,7 @@ func updateMappingCache(pid uint32) {
 		mappingCache.cache[pid] = make(map[uint64]string)
 	}
 
-	mapsPath := fmt.Sprintf("/proc/%d/maps", pid)
-	data, err := ioutil.ReadFile(mapsPath)
+	data, err := utils.ReadProc(pid, "maps")
 	if err != nil {
 		return
 	}
@@ -145,7 +143,7 @@ func getCachedMappingType(pid uint32, addr uint64) string {
 }
 
 func MemoryMonitoring() {
-	policies, err := configs.LoadRules("memory")
+	policies, err := configs.RegisterRules("memory")
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "정책 로드 실패: %v\n", err)
 		os.Exit(1)
@@ -222,43 +220,29 @@ func MemoryMonitoring() {
 				mappingType := getCachedMappingType(event.Pid, event.StartAddr)
 				//matchevent Tool memory -> Memory 수정 Datasend와 일치 시키기 위해
 				matchevent := utils.Event{
-					Tool:          "Memory",
-					Time:          time.Now().Format(time.RFC3339),
-					Uid:           event.Uid,
-					Gid:           event.Gid,
-					Pid:           event.Pid,
-					Ppid:          event.Ppid,
-					ProcessName:   processName,
-					Syscall:       syscallName,
-					StartAddr:     event.StartAddr,
-					EndAddr:       event.EndAddr,
-					Size:          event.Size,
-					Prottemp:      event.Prottemp,
-					Prot:          ProtToString(event.Prottemp),
-					ContainerName: containerInfo.Name,
-					MappingType:   mappingType,
+					Tool:            "Memory",
+					Time:            time.Now().Format(time.RFC3339),
+					Uid:             event.Uid,
+					Gid:             event.Gid,
+					Pid:             event.Pid,
+					Ppid:            event.Ppid,
+					ProcessName:     processName,
+					Syscall:         syscallName,
+					StartAddr:       event.StartAddr,
+					EndAddr:         event.EndAddr,
+					Size:            event.Size,
+					Prottemp:        event.Prottemp,
+					Prot:            ProtToString(event.Prottemp),
+					ContainerName:   containerInfo.Name,
+					ContainerImage:  containerInfo.Image,
+					ContainerLabels: containerInfo.Labels,
+					MappingType:     mappingType,
 				}
 
-				configs.MatchedEvent(policies, matchevent)
+				configs.MatchedEvent(policies.Load(), matchevent)
 
 				if configs.DataSend {
-					utils.DataSend(
-						"Memory",
-						matchevent.Time,
-						matchevent.ContainerName,
-						matchevent.Uid,
-						matchevent.Gid,
-						matchevent.Pid,
-						matchevent.Ppid,
-						matchevent.ProcessName,
-						matchevent.Syscall,
-						matchevent.StartAddr,
-						matchevent.EndAddr,
-						matchevent.Size,
-						matchevent.Prottemp,
-						matchevent.Prot,
-						matchevent.MappingType,
-					)
+					utils.SendEvent(matchevent)
 				}
 
 				logger.Log(matchevent)
@@ -48,7 +48,7 @@ func startHTTPMonitoring() {
 }
 
 func monitorTraffic(ctx context.Context) {
-	policies, err := configs.LoadRules("network")
+	policies, err := configs.RegisterRules("network")
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "정책 로드 실패: %v\n", err)
 		os.Exit(1)
@@ -91,7 +91,7 @@ func monitorTraffic(ctx context.Context) {
 	for {
 		select {
 		case data := <-channel:
-			network.HandleEvent(unsafe.Pointer(&data[0]), policies, logger)
+			network.HandleEvent(unsafe.Pointer(&data[0]), policies.Load(), logger)
 		case <-ctx.Done():
 			log.Println("Stopping traffic monitoring")
 			return
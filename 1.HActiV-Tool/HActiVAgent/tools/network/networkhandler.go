@@ -94,19 +94,23 @@ func HandleEvent(data unsafe.Pointer, policies []configs.Policy, logger *utils.D
 	containerStatsMutex.RUnlock()
 
 	matchevent := utils.Event{
-		Tool:          "Network_traffic",
-		Time:          time.Now().Format(time.RFC3339),
-		ContainerName: containerInfo.Name,
-		SrcIp:         srcIP,
-		SrcIpLabel:    srcType,
-		DstIp:         dstIP,
-		DstIpLabel:    dstType,
-		Protocol:      protocolName,
-		PacketCount:   int(stats.PacketCount),
-		Direction:     direction,
-		PacketSize:    int(event.PacketSize),
-		PathJson:      string(pathJSON),
-		TotalSize:     int(stats.TotalSize),
+		Tool:            "Network_traffic",
+		Time:            time.Now().Format(time.RFC3339),
+		ContainerName:   containerInfo.Name,
+		ContainerImage:  containerInfo.Image,
+		ContainerLabels: containerInfo.Labels,
+		Pid:             event.Pid,
+		SrcIp:           srcIP,
+		SrcIpLabel:      srcType,
+		DstIp:           dstIP,
+		DstIpLabel:      dstType,
+		Protocol:        protocolName,
+		DstPort:         event.DstPort,
+		PacketCount:     int(stats.PacketCount),
+		Direction:       direction,
+		PacketSize:      int(event.PacketSize),
+		PathJson:        string(pathJSON),
+		TotalSize:       int(stats.TotalSize),
 	}
 
 	configs.MatchedEvent(policies, matchevent)
@@ -129,22 +133,7 @@ func HandleEvent(data unsafe.Pointer, policies []configs.Policy, logger *utils.D
 		)
 	*/
 	if configs.DataSend {
-		utils.DataSend(
-			"Network_traffic",
-			matchevent.Time,
-			containerInfo.Name,
-			srcIP,
-			srcType,
-			dstIP,
-			dstType,
-			protocolName,
-			int(event.PacketSize),
-			int(stats.PacketCount),
-			int(stats.TotalSize),
-			string(pathJSON),
-			direction,
-			"", "", "", "",
-		)
+		utils.SendEvent(matchevent)
 	}
 }
 
@@ -190,22 +179,25 @@ func HandleCombinedEvent(httpEvent HTTPEvent, networkEvent *Event, mntNs uint32,
 	}
 	//matchevent Tool network -> Network_traffic 수정 Datasend와 일치 시키기 위해
 	matchevent := utils.Event{
-		Tool:          "Network_traffic",
-		Time:          time.Now().Format(time.RFC3339),
-		ContainerName: containerInfo.Name,
-		SrcIp:         httpEvent.SrcIP,
-		SrcIpLabel:    srcType,
-		DstIp:         httpEvent.DstIP,
-		DstIpLabel:    dstType,
-		Direction:     direction,
-		PacketSize:    int(networkEvent.PacketSize),
-		PacketCount:   int(stats.PacketCount),
-		TotalSize:     int(stats.TotalSize),
-		PathJson:      pathJSON,
-		Method:        httpEvent.Method,
-		Host:          httpEvent.Host,
-		URL:           httpEvent.URL,
-		Parameters:    httpEvent.Parameters,
+		Tool:            "Network_traffic",
+		Time:            formattedTimestamp,
+		ContainerName:   containerInfo.Name,
+		ContainerImage:  containerInfo.Image,
+		ContainerLabels: containerInfo.Labels,
+		SrcIp:           httpEvent.SrcIP,
+		SrcIpLabel:      srcType,
+		DstIp:           httpEvent.DstIP,
+		DstIpLabel:      dstType,
+		Protocol:        GetProtocolName(uint8(networkEvent.Protocol)),
+		Direction:       direction,
+		PacketSize:      int(networkEvent.PacketSize),
+		PacketCount:     int(stats.PacketCount),
+		TotalSize:       int(stats.TotalSize),
+		PathJson:        pathJSON,
+		Method:          httpEvent.Method,
+		Host:            httpEvent.Host,
+		URL:             httpEvent.URL,
+		Parameters:      httpEvent.Parameters,
 	}
 	logger.Log(matchevent)
 
@@ -226,25 +218,7 @@ func HandleCombinedEvent(httpEvent HTTPEvent, networkEvent *Event, mntNs uint32,
 		)
 	*/
 	if configs.DataSend {
-		utils.DataSend(
-			"Network_traffic",
-			formattedTimestamp,
-			containerName,
-			httpEvent.SrcIP,
-			srcType,
-			httpEvent.DstIP,
-			dstType,
-			GetProtocolName(uint8(networkEvent.Protocol)),
-			int(networkEvent.PacketSize),
-			int(stats.PacketCount),
-			int(stats.TotalSize),
-			pathJSON,
-			direction,
-			httpEvent.Method,
-			httpEvent.Host,
-			httpEvent.URL,
-			httpEvent.Parameters,
-		)
+		utils.SendEvent(matchevent)
 	}
 }
 
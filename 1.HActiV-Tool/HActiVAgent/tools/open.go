@@ -32,7 +32,7 @@ type openEvent struct {
 }
 
 func OpenMonitoring() {
-	policies, err := configs.LoadRules("open")
+	policies, err := configs.RegisterRules("open")
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "정책 로드 실패: %s\n", err)
 		os.Exit(1)
@@ -94,35 +94,26 @@ func OpenMonitoring() {
 				}
 				//matchevent Tool open -> file_open 수정 Datasend와 일치 시키기 위해
 				matchevent := utils.Event{
-					Tool:          "file_open",
-					Time:          time.Now().Format(time.RFC3339),
-					ContainerName: containerInfo.Name,
-					Uid:           event.Uid,
-					Gid:           event.Gid,
-					Pid:           event.Pid,
-					Ppid:          event.PPid,
-					Filename:      filename,
-					ProcessName:   processName,
+					Tool:            "file_open",
+					Time:            time.Now().Format(time.RFC3339),
+					ContainerName:   containerInfo.Name,
+					ContainerImage:  containerInfo.Image,
+					ContainerLabels: containerInfo.Labels,
+					Uid:             event.Uid,
+					Gid:             event.Gid,
+					Pid:             event.Pid,
+					Ppid:            event.PPid,
+					Filename:        filename,
+					ProcessName:     processName,
+					ReturnValue:     event.ReturnValue,
 				}
 
-				configs.MatchedEvent(policies, matchevent)
+				configs.MatchedEvent(policies.Load(), matchevent)
 
 				logger.Log(matchevent)
 
 				if configs.DataSend {
-					utils.DataSend(
-						"file_open",
-						matchevent.Time,
-						containerInfo.Name,
-						event.Uid,
-						event.Gid,
-						event.Pid,
-						event.PPid,
-						"open",
-						filename,
-						event.ReturnValue,
-						processName,
-					)
+					utils.SendEvent(matchevent)
 				}
 			case lostCountData := <-lost:
 				Losts += lostCountData
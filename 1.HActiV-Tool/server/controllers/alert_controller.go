@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"encoding/json"
+	"server/models"
+	"strings"
+	"time"
+
+	"github.com/beego/beego/v2/core/logs"
+	beego "github.com/beego/beego/v2/server/web"
+)
+
+type AlertController struct {
+	beego.Controller
+}
+
+func (c *AlertController) Post() {
+	items, err := readEvents(c.Ctx.Request)
+	if err != nil {
+		c.handleError(400, "Failed to read request body", err)
+		return
+	}
+
+	result := batchResult{}
+	for _, item := range items {
+		status, message, err := saveAlert(item)
+		result.add(status, message, err)
+	}
+	status, message, err := result.status()
+	if status >= 400 {
+		c.handleError(status, message, err)
+		return
+	}
+
+	c.Ctx.Output.SetStatus(status)
+	c.Data["json"] = result.response("Alert received and saved successfully")
+	c.ServeJSON()
+}
+
+// saveAlert stores one alert and returns the HTTP status and error message when it fails
+func saveAlert(body []byte) (int, string, error) {
+	var alert models.AlertData
+	if err := json.Unmarshal(body, &alert); err != nil {
+		return 400, "Invalid alert data", err
+	}
+	if alert.PolicyName == "" {
+		return 400, "Missing policy_name", nil
+	}
+	alert.Severity = strings.ToLower(alert.Severity)
+
+	if err := models.SaveAlert(&alert, body); err != nil {
+		return 500, "Failed to save alert", err
+	}
+	logs.Info("Alert received: %s (%s) from %s", alert.PolicyName, alert.Severity, alert.ContainerName)
+	return 200, "", nil
+}
+
+// Get returns alerts filtered by query parameters:
+// severity (comma separated), min_severity, tag, tactic, technique, policy_name, container_name, start_time, end_time
+// Without start_time and end_time it returns the alerts of the last 24 hours.
+func (c *AlertController) Get() {
+	filter := models.AlertFilter{
+		MinSeverity:   strings.ToLower(c.GetString("min_severity")),
+		Tag:           c.GetString("tag"),
+		Tactic:        strings.ToUpper(c.GetString("tactic")),
+		Technique:     strings.ToUpper(c.GetString("technique")),
+		PolicyName:    c.GetString("policy_name"),
+		ContainerName: c.GetString("container_name"),
+	}
+	if severity := c.GetString("severity"); severity != "" {
+		for _, s := range strings.Split(severity, ",") {
+			s = strings.ToLower(strings.TrimSpace(s))
+			if !models.ValidSeverity(s) {
+				c.handleError(400, "Invalid severity: "+s, nil)
+				return
+			}
+			filter.Severities = append(filter.Severities, s)
+		}
+	}
+	if filter.MinSeverity != "" && !models.ValidSeverity(filter.MinSeverity) {
+		c.handleError(400, "Invalid min_severity: "+filter.MinSeverity, nil)
+		return
+	}
+
+	var err error
+	if startTimeStr := c.GetString("start_time"); startTimeStr != "" {
+		if filter.StartTime, err = time.Parse(time.RFC3339, startTimeStr); err != nil {
+			c.handleError(400, "Invalid start_time format", err)
+			return
+		}
+	}
+	if endTimeStr := c.GetString("end_time"); endTimeStr != "" {
+		if filter.EndTime, err = time.Parse(time.RFC3339, endTimeStr); err != nil {
+			c.handleError(400, "Invalid end_time format", err)
+			return
+		}
+	}
+
+	data, err := models.GetAlerts(filter)
+	if err != nil {
+		c.handleError(500, "Failed to retrieve alerts", err)
+		return
+	}
+	c.Data["json"] = data
+	c.ServeJSON()
+}
+
+func (c *AlertController) handleError(status int, message string, err error) {
+	details := ""
+	if err != nil {
+		logs.Error("%s: %v", message, err)
+		details = err.Error()
+	} else {
+		logs.Error("%s", message)
+	}
+	c.Ctx.Output.SetStatus(status)
+	c.Data["json"] = map[string]string{"error": message, "details": details}
+	c.ServeJSON()
+}
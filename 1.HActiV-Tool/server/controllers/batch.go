@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/beego/beego/v2/core/logs"
+)
+
+// maxBodySize limits the decompressed request body
+const maxBodySize = 32 << 20
+
+// readEvents reads a request body sent by the agent. The body is a single JSON object or,
+// from the batching transport, a JSON array of objects, optionally gzip-compressed.
+func readEvents(r *http.Request) ([]json.RawMessage, error) {
+	var reader io.Reader = r.Body
+	if strings.EqualFold(r.Header.Get("Content-Encoding"), "gzip") {
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		reader = zr
+	}
+	body, err := io.ReadAll(io.LimitReader(reader, maxBodySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxBodySize {
+		return nil, fmt.Errorf("request body exceeds %d bytes", maxBodySize)
+	}
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return nil, fmt.Errorf("empty request body")
+	}
+	if body[0] != '[' {
+		return []json.RawMessage{body}, nil
+	}
+	var items []json.RawMessage
+	if err := json.Unmarshal(body, &items); err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("empty event batch")
+	}
+	return items, nil
+}
+
+// batchFailure is an event that could not be stored, identified by its position in the request
+type batchFailure struct {
+	Index   int    `json:"index"`
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+// batchResult collects the outcome of each event in a request
+type batchResult struct {
+	saved     int
+	failed    int
+	failures  []batchFailure
+	errStatus int
+	message   string
+	err       error
+}
+
+func (b *batchResult) add(status int, message string, err error) {
+	if status == 200 {
+		b.saved++
+		return
+	}
+	failure := batchFailure{Index: b.saved + b.failed, Status: status, Message: message}
+	if err != nil {
+		failure.Message += ": " + err.Error()
+	}
+	b.failures = append(b.failures, failure)
+	b.failed++
+	// Keep the first failure, preferring a server error so the agent retries a batch that could not be stored.
+	if b.errStatus == 0 || (status >= 500 && b.errStatus < 500) {
+		b.errStatus, b.message, b.err = status, message, err
+	}
+}
+
+// status reports an error for the whole request only when no event was saved and every event failed
+// with the same status. Otherwise failures are reported per event with 207 Multi-Status, so the agent
+// resends only the events that failed and does not resend the ones that were already stored.
+func (b *batchResult) status() (int, string, error) {
+	if b.failed == 0 {
+		return 200, "", nil
+	}
+	if b.saved == 0 && b.sameStatus() {
+		return b.errStatus, b.message, b.err
+	}
+	logs.Warning("Saved %d of %d events, first failure: %s: %v", b.saved, b.saved+b.failed, b.message, b.err)
+	return 207, "", nil
+}
+
+func (b *batchResult) sameStatus() bool {
+	for _, failure := range b.failures {
+		if failure.Status != b.failures[0].Status {
+			return false
+		}
+	}
+	return true
+}
+
+// response is the JSON body for a request that was stored completely or partially
+func (b *batchResult) response(message string) map[string]interface{} {
+	response := map[string]interface{}{"message": message, "received": b.saved, "failed": b.failed}
+	if len(b.failures) > 0 {
+		response["failures"] = b.failures
+	}
+	return response
+}
@@ -3,7 +3,6 @@ package controllers
 import (
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"net/http"
 	"server/models"
 	"strings"
@@ -80,53 +79,54 @@ func (c *DashboardController) WebSocketHandler() {
 }
 
 func (c *DashboardController) Post() {
-	body, err := ioutil.ReadAll(c.Ctx.Request.Body)
+	items, err := readEvents(c.Ctx.Request)
 	if err != nil {
-		logs.Error("Failed to read request body: %v", err)
 		c.handleError(400, "Failed to read request body", err)
 		return
 	}
 
-	logs.Info("Raw POST body: %s", string(body))
-
-	if len(body) == 0 {
-		logs.Error("Empty request body")
-		c.handleError(400, "Empty request body", nil)
+	result := batchResult{}
+	for _, item := range items {
+		status, message, err := saveDashboardEvent(item)
+		result.add(status, message, err)
+	}
+	status, message, err := result.status()
+	if status >= 400 {
+		c.handleError(status, message, err)
 		return
 	}
 
+	c.Ctx.Output.SetStatus(status)
+	c.Data["json"] = result.response("Data received and saved successfully")
+	c.ServeJSON()
+}
+
+// saveDashboardEvent stores one event and returns the HTTP status and error message when it fails
+func saveDashboardEvent(body []byte) (int, string, error) {
 	var inputData map[string]interface{}
 	if err := json.Unmarshal(body, &inputData); err != nil {
-		logs.Error("Failed to parse JSON: %v", err)
-		c.handleError(400, "Invalid JSON data", err)
-		return
+		return 400, "Invalid JSON data", err
 	}
 
-	logs.Info("Parsed data: %+v", inputData)
-
 	eventType, ok := inputData["event_type"].(string)
 	if !ok {
-		c.handleError(400, "Missing or invalid event_type", nil)
-		return
+		return 400, "Missing or invalid event_type", nil
 	}
 
 	switch eventType {
 	case "Systemcall":
 		var data models.ExecveApiData
 		if err := mapToStruct(inputData, &data); err != nil {
-			c.handleError(400, "Invalid data for Systemcall event", err)
-			return
+			return 400, "Invalid data for Systemcall event", err
 		}
 		if err := models.SaveExecveData(&data); err != nil {
-			c.handleError(500, "Failed to save execve data", err)
-			return
+			return 500, "Failed to save execve data", err
 		}
 
 	case "file_open", "delete":
 		var data models.OpenApiData
 		if err := mapToStruct(inputData, &data); err != nil {
-			c.handleError(400, "Invalid data for file event", err)
-			return
+			return 400, "Invalid data for file event", err
 		}
 
 		// 파일 이름에 'log'가 포함되어 있는지 확인
@@ -139,62 +139,49 @@ func (c *DashboardController) Post() {
 		}
 
 		if err := models.SaveOpenData(&data); err != nil {
-			c.handleError(500, "Failed to save file event data", err)
-			return
+			return 500, "Failed to save file event data", err
 		}
 
 	case "Network_traffic":
 		var data models.NetworkApiData
 		if err := mapToStruct(inputData, &data); err != nil {
-			c.handleError(400, "Invalid data for Network_traffic event", err)
-			return
+			return 400, "Invalid data for Network_traffic event", err
 		}
 		if err := models.SaveNetworkData(&data); err != nil {
-			c.handleError(500, "Failed to save network data", err)
-			return
+			return 500, "Failed to save network data", err
 		}
 
 	case "Memory":
 		var data models.MemoryApiData
 		if err := mapToStruct(inputData, &data); err != nil {
-			c.handleError(400, "Invalid data for Memory event", err)
-			return
+			return 400, "Invalid data for Memory event", err
 		}
 		if err := models.SaveMemoryData(&data); err != nil {
-			c.handleError(500, "Failed to save memory data", err)
-			return
+			return 500, "Failed to save memory data", err
 		}
 
-
 	case "ContainerMetrics":
 		var data models.ContainerMetricsApiData
 		if err := mapToStruct(inputData, &data); err != nil {
-			c.handleError(400, "Invalid data for ContainerMetrics event", err)
-			return
+			return 400, "Invalid data for ContainerMetrics event", err
 		}
 		if err := models.SaveContainerMetricsData(&data); err != nil {
-			c.handleError(500, "Failed to save container metrics data", err)
-			return
+			return 500, "Failed to save container metrics data", err
 		}
 
 	case "HostMetrics":
 		var data models.HostMetricsApiData
 		if err := mapToStruct(inputData, &data); err != nil {
-			c.handleError(400, "Invalid data for HostMetrics event", err)
-			return
+			return 400, "Invalid data for HostMetrics event", err
 		}
 		if err := models.SaveHostMetricsData(&data); err != nil {
-			c.handleError(500, "Failed to save host metrics data", err)
-			return
+			return 500, "Failed to save host metrics data", err
 		}
 
 	default:
-		c.handleError(400, fmt.Sprintf("Unsupported event type: %s", eventType), nil)
-		return
+		return 400, fmt.Sprintf("Unsupported event type: %s", eventType), nil
 	}
-
-	c.Data["json"] = map[string]string{"message": "Data received and saved successfully"}
-	c.ServeJSON()
+	return 200, "", nil
 }
 
 func (c *DashboardController) Get() {
@@ -355,13 +342,15 @@ func (c *DashboardController) SaveContainerData() {
 }
 
 func (c *DashboardController) handleError(status int, message string, err error) {
+	details := ""
 	if err != nil {
 		logs.Error("%s: %v", message, err)
+		details = err.Error()
 	} else {
 		logs.Error("%s", message)
 	}
 	c.Ctx.Output.SetStatus(status)
-	c.Data["json"] = map[string]string{"error": message, "details": err.Error()}
+	c.Data["json"] = map[string]string{"error": message, "details": details}
 	c.ServeJSON()
 }
 
@@ -0,0 +1,349 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"sync"
+
+	"server/eventpb"
+
+	"github.com/beego/beego/v2/core/logs"
+	beego "github.com/beego/beego/v2/server/web"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	_ "google.golang.org/grpc/encoding/gzip" // accept gzip-compressed streams from the agent
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// IngestService receives agent event streams over gRPC (eventpb.Ingest). Each event batch is
+// stored through the same path as DashboardController.Post and acknowledged on the stream.
+// Rule updates posted to AgentRuleController are pushed back to every connected agent.
+type IngestService struct {
+	eventpb.UnimplementedIngestServer
+}
+
+// agentStream is one connected agent
+type agentStream struct {
+	name         string
+	rulesVersion string
+	lastError    string
+	updates      chan *eventpb.RuleUpdate
+}
+
+var agents = struct {
+	sync.Mutex
+	streams map[*agentStream]struct{}
+	// latest is sent to agents that connect later with a different rules version
+	latest *eventpb.RuleUpdate
+}{streams: make(map[*agentStream]struct{})}
+
+// validAPIKey reports whether key matches api_key in app.conf. Nothing is accepted while api_key is unset.
+func validAPIKey(key string) bool {
+	expected := beego.AppConfig.DefaultString("api_key", "")
+	return expected != "" && subtle.ConstantTimeCompare([]byte(key), []byte(expected)) == 1
+}
+
+// requireAPIKey rejects streams whose x-api-key metadata does not match api_key
+func requireAPIKey(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	if keys := md.Get("x-api-key"); len(keys) != 1 || !validAPIKey(keys[0]) {
+		return status.Error(codes.Unauthenticated, "invalid API key")
+	}
+	return handler(srv, stream)
+}
+
+// ServeIngest listens for agent streams on addr. With certFile and keyFile the stream uses TLS;
+// agents accept rule updates only over TLS unless GrpcInsecureRuleUpdates is set on the agent.
+func ServeIngest(addr, certFile, keyFile string) error {
+	if beego.AppConfig.DefaultString("api_key", "") == "" {
+		return fmt.Errorf("api_key is not set")
+	}
+	opts := []grpc.ServerOption{grpc.StreamInterceptor(requireAPIKey)}
+	if certFile != "" || keyFile != "" {
+		creds, err := credentials.NewServerTLSFromFile(certFile, keyFile)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, grpc.Creds(creds))
+	} else {
+		logs.Warning("gRPC ingest is running without TLS; agents will refuse rule updates")
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	server := grpc.NewServer(opts...)
+	eventpb.RegisterIngestServer(server, &IngestService{})
+	logs.Info("gRPC ingest listening on %s", addr)
+	return server.Serve(listener)
+}
+
+func (s *IngestService) Stream(stream eventpb.Ingest_StreamServer) error {
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	hello := first.GetHello()
+	if hello == nil {
+		return fmt.Errorf("first message must be hello")
+	}
+	agent := registerAgent(hello)
+	defer unregisterAgent(agent)
+	logs.Info("Agent %s connected (rules version %q)", agent.name, hello.RulesVersion)
+
+	// grpc allows only one goroutine to call Send, so received messages are handled in the loop below.
+	incoming := make(chan *eventpb.AgentMessage)
+	recvErr := make(chan error, 1)
+	go func() {
+		for {
+			msg, err := stream.Recv()
+			if err != nil {
+				recvErr <- err
+				return
+			}
+			select {
+			case incoming <- msg:
+			case <-stream.Context().Done():
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case msg := <-incoming:
+			switch body := msg.Body.(type) {
+			case *eventpb.AgentMessage_Events:
+				ack := saveEventBatch(body.Events)
+				if err := stream.Send(&eventpb.ServerMessage{Body: &eventpb.ServerMessage_Ack{Ack: ack}}); err != nil {
+					return err
+				}
+			case *eventpb.AgentMessage_RuleResult:
+				agentRuleResult(agent, body.RuleResult)
+			}
+		case update := <-agent.updates:
+			if err := stream.Send(&eventpb.ServerMessage{Body: &eventpb.ServerMessage_RuleUpdate{RuleUpdate: update}}); err != nil {
+				return err
+			}
+		case err := <-recvErr:
+			if err == io.EOF {
+				logs.Info("Agent %s disconnected", agent.name)
+				return nil
+			}
+			logs.Warning("Agent %s stream closed: %v", agent.name, err)
+			return err
+		}
+	}
+}
+
+func registerAgent(hello *eventpb.Hello) *agentStream {
+	agent := &agentStream{name: hello.Agent, rulesVersion: hello.RulesVersion, updates: make(chan *eventpb.RuleUpdate, 1)}
+	agents.Lock()
+	defer agents.Unlock()
+	agents.streams[agent] = struct{}{}
+	if agents.latest != nil && agents.latest.Version != hello.RulesVersion {
+		agent.updates <- agents.latest
+	}
+	return agent
+}
+
+func unregisterAgent(agent *agentStream) {
+	agents.Lock()
+	delete(agents.streams, agent)
+	agents.Unlock()
+}
+
+func agentRuleResult(agent *agentStream, result *eventpb.RuleUpdateResult) {
+	agents.Lock()
+	defer agents.Unlock()
+	agent.lastError = result.Error
+	if result.Error != "" {
+		logs.Error("Agent %s failed to apply rules version %s: %s", agent.name, result.Version, result.Error)
+		return
+	}
+	agent.rulesVersion = result.Version
+	logs.Info("Agent %s applied rules version %s", agent.name, result.Version)
+}
+
+// pushRuleUpdate sends the update to every connected agent and returns how many received it.
+// An agent that still has an earlier update queued gets the newer one instead.
+func pushRuleUpdate(update *eventpb.RuleUpdate) int {
+	agents.Lock()
+	defer agents.Unlock()
+	agents.latest = update
+	for agent := range agents.streams {
+		select {
+		case <-agent.updates:
+		default:
+		}
+		agent.updates <- update
+	}
+	return len(agents.streams)
+}
+
+// saveEventBatch stores each event with saveDashboardEvent and reports the result for the batch
+func saveEventBatch(batch *eventpb.EventBatch) *eventpb.Ack {
+	result := batchResult{}
+	for _, event := range batch.Events {
+		body, err := eventJSON(event)
+		if err != nil {
+			result.add(400, "Invalid event", err)
+			continue
+		}
+		result.add(saveDashboardEvent(body))
+	}
+	ack := &eventpb.Ack{Seq: batch.Seq, Saved: uint32(result.saved), Failed: uint32(result.failed)}
+	for _, failure := range result.failures {
+		ack.Failures = append(ack.Failures, &eventpb.EventFailure{Index: uint32(failure.Index), Status: uint32(failure.Status), Message: failure.Message})
+	}
+	if result.failed > 0 {
+		ack.Error = result.message
+		if result.err != nil {
+			ack.Error += ": " + result.err.Error()
+		}
+		logs.Warning("Saved %d of %d streamed events, first failure: %s", result.saved, result.saved+result.failed, ack.Error)
+	}
+	return ack
+}
+
+// eventJSON converts a streamed event to the JSON object the agent sends over HTTP
+func eventJSON(event *eventpb.Event) ([]byte, error) {
+	data := map[string]interface{}{
+		"event_type":     event.EventType,
+		"timestamp":      event.Timestamp,
+		"container_name": event.ContainerName,
+	}
+	process := func(p *eventpb.Process) {
+		data["uid"], data["gid"], data["pid"], data["ppid"] = p.GetUid(), p.GetGid(), p.GetPid(), p.GetPpid()
+	}
+	switch body := event.Data.(type) {
+	case *eventpb.Event_Execve:
+		e := body.Execve
+		process(e.Process)
+		data["command"], data["process_name"], data["arguments"] = e.Command, e.ProcessName, e.Arguments
+	case *eventpb.Event_Open:
+		e := body.Open
+		process(e.Process)
+		data["command"], data["filename"], data["status"], data["process_name"] = e.Command, e.Filename, e.Status, e.ProcessName
+	case *eventpb.Event_Delete:
+		e := body.Delete
+		process(e.Process)
+		data["process_name"], data["filename"] = e.ProcessName, e.Filename
+	case *eventpb.Event_Memory:
+		e := body.Memory
+		process(e.Process)
+		data["process_name"], data["syscall"], data["prot"], data["prottemp"], data["mapping_type"] = e.ProcessName, e.Syscall, e.Prot, e.Prottemp, e.MappingType
+		data["start_address"], data["end_address"], data["size"] = e.StartAddress, e.EndAddress, e.Size
+	case *eventpb.Event_LogAccess:
+		e := body.LogAccess
+		process(e.Process)
+		data["process_name"], data["filename"], data["file_size"], data["mount_status"] = e.ProcessName, e.Filename, e.FileSize, e.MountStatus
+	case *eventpb.Event_Network:
+		e := body.Network
+		path, err := json.Marshal(map[string]interface{}{
+			"nodes": []map[string]string{{"id": e.SrcIp, "type": e.SrcIpLabel}, {"id": e.DstIp, "type": e.DstIpLabel}},
+			"links": []map[string]string{{"source": e.SrcIp, "target": e.DstIp}},
+		})
+		if err != nil {
+			return nil, err
+		}
+		data["src_ip"], data["src_ip_label"], data["dst_ip"], data["dst_ip_label"] = e.SrcIp, e.SrcIpLabel, e.DstIp, e.DstIpLabel
+		data["protocol"], data["packet_size"], data["total_packets"], data["total_size"] = e.Protocol, e.PacketSize, e.TotalPackets, e.TotalSize
+		data["path"], data["direction"] = string(path), e.Direction
+		for key, value := range map[string]string{"http_method": e.HttpMethod, "http_host": e.HttpHost, "http_url": e.HttpUrl, "http_parameters": e.HttpParameters} {
+			if value != "" {
+				data[key] = value
+			}
+		}
+	case *eventpb.Event_ContainerMetrics:
+		e := body.ContainerMetrics
+		data["cpu_usage"], data["memory_usage"], data["disk_usage"], data["rx_bytes"], data["tx_bytes"] = e.CpuUsage, e.MemoryUsage, e.DiskUsage, e.RxBytes, e.TxBytes
+	case *eventpb.Event_HostMetrics:
+		e := body.HostMetrics
+		delete(data, "container_name")
+		data["cpu_usage"], data["memory_usage"], data["disk_usage"], data["cpu_cores"] = e.CpuUsage, e.MemoryUsage, e.DiskUsage, e.CpuCores
+	default:
+		return nil, fmt.Errorf("event %s has no data", event.EventType)
+	}
+	return json.Marshal(data)
+}
+
+// AgentRuleController pushes rule files to agents connected over the gRPC stream.
+// Requests must carry the X-API-Key header that matches api_key.
+type AgentRuleController struct {
+	beego.Controller
+}
+
+func (c *AgentRuleController) Prepare() {
+	if !validAPIKey(c.Ctx.Input.Header("X-API-Key")) {
+		c.handleError(401, "Invalid API key", nil)
+		c.StopRun()
+	}
+}
+
+type ruleUpdateRequest struct {
+	Version string            `json:"version"`
+	Files   map[string]string `json:"files"`
+}
+
+// Post sends {"version": "...", "files": {"name": "content"}} to every connected agent.
+// Agents write the files into their rule directory and reload them.
+func (c *AgentRuleController) Post() {
+	var req ruleUpdateRequest
+	if err := json.NewDecoder(io.LimitReader(c.Ctx.Request.Body, maxBodySize)).Decode(&req); err != nil {
+		c.handleError(400, "Invalid rule update", err)
+		return
+	}
+	if req.Version == "" || len(req.Files) == 0 {
+		c.handleError(400, "Missing version or files", nil)
+		return
+	}
+
+	update := &eventpb.RuleUpdate{Version: req.Version}
+	names := make([]string, 0, len(req.Files))
+	for name := range req.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		update.Files = append(update.Files, &eventpb.RuleFile{Name: name, Content: []byte(req.Files[name])})
+	}
+	sent := pushRuleUpdate(update)
+	logs.Info("Rules version %s pushed to %d agents", req.Version, sent)
+
+	c.Data["json"] = map[string]interface{}{"message": "Rule update sent", "version": req.Version, "agents": sent}
+	c.ServeJSON()
+}
+
+// Get lists connected agents with the rules version they last applied
+func (c *AgentRuleController) Get() {
+	agents.Lock()
+	list := make([]map[string]string, 0, len(agents.streams))
+	for agent := range agents.streams {
+		list = append(list, map[string]string{"agent": agent.name, "rules_version": agent.rulesVersion, "error": agent.lastError})
+	}
+	agents.Unlock()
+	sort.Slice(list, func(i, j int) bool { return list[i]["agent"] < list[j]["agent"] })
+
+	c.Data["json"] = list
+	c.ServeJSON()
+}
+
+func (c *AgentRuleController) handleError(status int, message string, err error) {
+	details := ""
+	if err != nil {
+		logs.Error("%s: %v", message, err)
+		details = err.Error()
+	} else {
+		logs.Error("%s", message)
+	}
+	c.Ctx.Output.SetStatus(status)
+	c.Data["json"] = map[string]string{"error": message, "details": details}
+	c.ServeJSON()
+}
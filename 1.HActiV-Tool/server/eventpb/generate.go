@@ -0,0 +1,6 @@
+// Package eventpb is generated from the agent's protocol definition,
+// HActiVAgent/pkg/utils/eventpb/event.proto. Edit that file and run go generate here
+// and in the agent package; the M options only change the Go import path.
+package eventpb
+
+//go:generate protoc -I ../../HActiVAgent/pkg/utils/eventpb --go_out=. --go_opt=paths=source_relative --go_opt=Mevent.proto=server/eventpb --go-grpc_out=. --go-grpc_opt=paths=source_relative --go-grpc_opt=Mevent.proto=server/eventpb event.proto
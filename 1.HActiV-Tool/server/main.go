@@ -6,6 +6,7 @@ import (
 	"os"
 	"time"
 
+	"server/controllers"
 	"server/kafka"
 	"server/models"
 	_ "server/routers"
@@ -119,6 +120,16 @@ func main() {
 	logs.EnableFuncCallDepth(true)
 	logs.Async()
 
+	// 에이전트 gRPC 스트림 수신
+	grpcPort := beego.AppConfig.DefaultString("grpcport", "9090")
+	grpcCert := beego.AppConfig.DefaultString("grpc_tls_cert", "")
+	grpcKey := beego.AppConfig.DefaultString("grpc_tls_key", "")
+	go func() {
+		if err := controllers.ServeIngest(":"+grpcPort, grpcCert, grpcKey); err != nil {
+			logs.Error("Failed to serve gRPC ingest: %v", err)
+		}
+	}()
+
 	// Beego 애플리케이션 실행
 	defer kafka.CloseKafka()
 	beego.Run()
@@ -0,0 +1,288 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/beego/beego/v2/core/logs"
+)
+
+const maxRecentAlerts = 1000
+
+// defaultAlertWindow is the time range queried when a request has neither start_time nor end_time
+const defaultAlertWindow = 24 * time.Hour
+
+// AlertData contains the rule classification fields common to every alert sent by the agent
+type AlertData struct {
+	PolicyName      string    `json:"policy_name"`
+	PrintFormat     string    `json:"print_format"`
+	EventType       string    `json:"event_type"`
+	Time            time.Time `json:"timestamp"`
+	ContainerName   string    `json:"container_name"`
+	Severity        string    `json:"severity,omitempty"`
+	Tags            []string  `json:"tags,omitempty"`
+	MitreTactics    []string  `json:"mitre_tactics,omitempty"`
+	MitreTechniques []string  `json:"mitre_techniques,omitempty"`
+}
+
+// AlertFilter narrows alerts by classification. Empty fields are ignored.
+type AlertFilter struct {
+	Severities    []string
+	MinSeverity   string
+	Tag           string
+	Tactic        string
+	Technique     string
+	PolicyName    string
+	ContainerName string
+	StartTime     time.Time
+	EndTime       time.Time
+}
+
+type storedAlert struct {
+	alert AlertData
+	raw   map[string]interface{}
+}
+
+// alertRing keeps the most recent alerts in a fixed-size circular buffer
+type alertRing struct {
+	items []storedAlert
+	next  int
+}
+
+func (r *alertRing) add(alert storedAlert) {
+	if len(r.items) < maxRecentAlerts {
+		r.items = append(r.items, alert)
+		return
+	}
+	r.items[r.next] = alert
+	r.next = (r.next + 1) % maxRecentAlerts
+}
+
+// newestFirst calls fn for each stored alert, starting with the most recent one
+func (r *alertRing) newestFirst(fn func(*storedAlert)) {
+	for i := len(r.items) - 1; i >= 0; i-- {
+		fn(&r.items[(r.next+i)%len(r.items)])
+	}
+}
+
+var (
+	recentAlerts alertRing
+	alertMutex   sync.RWMutex
+
+	severityRank = map[string]int{"info": 0, "low": 1, "medium": 2, "high": 3, "critical": 4}
+)
+
+func ValidSeverity(severity string) bool {
+	_, ok := severityRank[severity]
+	return ok
+}
+
+func createAlertTable() {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS alerts (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			policy_name VARCHAR(255),
+			event_type VARCHAR(50),
+			container_name VARCHAR(255),
+			severity VARCHAR(16),
+			tags JSON,
+			mitre_tactics JSON,
+			mitre_techniques JSON,
+			data JSON,
+			timestamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			INDEX idx_alerts_severity (severity),
+			INDEX idx_alerts_timestamp (timestamp)
+		)
+	`)
+	if err != nil {
+		logs.Error("Failed to create alerts table: %v", err)
+	}
+}
+
+func jsonList(values []string) string {
+	if values == nil {
+		values = []string{}
+	}
+	data, _ := json.Marshal(values)
+	return string(data)
+}
+
+func SaveAlert(alert *AlertData, body []byte) error {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return fmt.Errorf("failed to parse alert: %v", err)
+	}
+
+	alertMutex.Lock()
+	recentAlerts.add(storedAlert{alert: *alert, raw: raw})
+	alertMutex.Unlock()
+
+	if db == nil {
+		return nil
+	}
+	// Store the time the agent detected the event so time range queries match the alert, not its arrival.
+	timestamp := alert.Time
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	_, err := db.Exec(`INSERT INTO alerts (policy_name, event_type, container_name, severity, tags, mitre_tactics, mitre_techniques, data, timestamp)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		alert.PolicyName, alert.EventType, alert.ContainerName, alert.Severity,
+		jsonList(alert.Tags), jsonList(alert.MitreTactics), jsonList(alert.MitreTechniques), string(body), timestamp)
+	if err != nil {
+		return fmt.Errorf("failed to insert alert: %v", err)
+	}
+	return nil
+}
+
+func (f AlertFilter) matches(alert *AlertData) bool {
+	if len(f.Severities) > 0 && !containsString(f.Severities, alert.Severity) {
+		return false
+	}
+	if f.MinSeverity != "" && severityRank[alert.Severity] < severityRank[f.MinSeverity] {
+		return false
+	}
+	if f.Tag != "" && !containsString(alert.Tags, f.Tag) {
+		return false
+	}
+	if f.Tactic != "" && !containsString(alert.MitreTactics, f.Tactic) {
+		return false
+	}
+	if f.Technique != "" && !matchesTechnique(alert.MitreTechniques, f.Technique) {
+		return false
+	}
+	if f.PolicyName != "" && alert.PolicyName != f.PolicyName {
+		return false
+	}
+	if f.ContainerName != "" && alert.ContainerName != f.ContainerName {
+		return false
+	}
+	return true
+}
+
+func containsString(values []string, item string) bool {
+	for _, value := range values {
+		if value == item {
+			return true
+		}
+	}
+	return false
+}
+
+// T1059 필터는 하위 기법(T1059.004)도 포함한다.
+func matchesTechnique(techniques []string, technique string) bool {
+	for _, t := range techniques {
+		if t == technique || strings.HasPrefix(t, technique+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// recentAlertsMatching filters the alerts kept in memory, newest first
+func recentAlertsMatching(filter AlertFilter) []interface{} {
+	alertMutex.RLock()
+	defer alertMutex.RUnlock()
+
+	result := make([]interface{}, 0)
+	recentAlerts.newestFirst(func(stored *storedAlert) {
+		if filter.matches(&stored.alert) {
+			result = append(result, stored.raw)
+		}
+	})
+	return result
+}
+
+// GetAlerts queries MySQL. Without a time range it returns the alerts of the last defaultAlertWindow,
+// and only uses the alerts kept in memory when MySQL is not configured or the query fails.
+func GetAlerts(filter AlertFilter) ([]interface{}, error) {
+	noRange := filter.StartTime.IsZero() && filter.EndTime.IsZero()
+	if noRange {
+		if db == nil {
+			return recentAlertsMatching(filter), nil
+		}
+		filter.StartTime = time.Now().Add(-defaultAlertWindow)
+	}
+
+	// MySQL에서 알림 조회
+	query := "SELECT data FROM alerts WHERE timestamp BETWEEN ? AND ?"
+	endTime := filter.EndTime
+	if endTime.IsZero() {
+		endTime = time.Now()
+	}
+	args := []interface{}{filter.StartTime, endTime}
+
+	if len(filter.Severities) > 0 {
+		query += " AND severity IN (?" + strings.Repeat(", ?", len(filter.Severities)-1) + ")"
+		for _, severity := range filter.Severities {
+			args = append(args, severity)
+		}
+	}
+	if filter.MinSeverity != "" {
+		var allowed []string
+		for severity, rank := range severityRank {
+			if rank >= severityRank[filter.MinSeverity] {
+				allowed = append(allowed, severity)
+			}
+		}
+		query += " AND severity IN (?" + strings.Repeat(", ?", len(allowed)-1) + ")"
+		for _, severity := range allowed {
+			args = append(args, severity)
+		}
+	}
+	if filter.Tag != "" {
+		query += " AND JSON_CONTAINS(tags, JSON_QUOTE(?))"
+		args = append(args, filter.Tag)
+	}
+	if filter.Tactic != "" {
+		query += " AND JSON_CONTAINS(mitre_tactics, JSON_QUOTE(?))"
+		args = append(args, filter.Tactic)
+	}
+	if filter.Technique != "" {
+		query += " AND (JSON_CONTAINS(mitre_techniques, JSON_QUOTE(?)) OR JSON_SEARCH(mitre_techniques, 'one', ?) IS NOT NULL)"
+		args = append(args, filter.Technique, filter.Technique+".%")
+	}
+	if filter.PolicyName != "" {
+		query += " AND policy_name = ?"
+		args = append(args, filter.PolicyName)
+	}
+	if filter.ContainerName != "" {
+		query += " AND container_name = ?"
+		args = append(args, filter.ContainerName)
+	}
+	query += " ORDER BY timestamp DESC LIMIT 1000"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		if noRange {
+			logs.Warning("Failed to query alerts, returning recent alerts from memory: %v", err)
+			return recentAlertsMatching(filter), nil
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]interface{}, 0)
+	for rows.Next() {
+		var dataStr string
+		if err := rows.Scan(&dataStr); err != nil {
+			return nil, err
+		}
+		var data interface{}
+		if err := json.Unmarshal([]byte(dataStr), &data); err != nil {
+			return nil, err
+		}
+		result = append(result, data)
+	}
+	return result, nil
+}
+
+func cleanupOldAlerts(retentionPeriod time.Time) {
+	_, err := db.Exec("DELETE FROM alerts WHERE timestamp < ?", retentionPeriod)
+	if err != nil {
+		logs.Error("Failed to cleanup old alerts: %v", err)
+	}
+}
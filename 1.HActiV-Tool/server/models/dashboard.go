@@ -204,6 +204,8 @@ func createTables() {
 	if err != nil {
 		logs.Error("Failed to create events table: %v", err)
 	}
+
+	createAlertTable()
 }
 
 func SaveNetworkData(data *NetworkApiData) error {
@@ -645,6 +647,7 @@ func cleanupOldData() {
 	if err != nil {
 		logs.Error("Failed to cleanup old data: %v", err)
 	}
+	cleanupOldAlerts(retentionPeriod)
 }
 
 func UpdateUserSettings(retentionDays int) error {
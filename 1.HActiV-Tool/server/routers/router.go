@@ -9,6 +9,12 @@ func init() {
 	// Dashboard 관련 API 라우팅
 	beego.Router("/api/dashboard", &controllers.DashboardController{}, "get:Get;post:Post")
 
+	// 탐지 규칙 알림 수신 및 조회 (severity, tag, tactic, technique 필터)
+	beego.Router("/api/alert", &controllers.AlertController{}, "get:Get;post:Post")
+
+	// gRPC 스트림으로 연결된 에이전트 조회와 규칙 배포
+	beego.Router("/api/agents/rules", &controllers.AgentRuleController{}, "get:Get;post:Post")
+
 	// WebSocket 연결을 위한 라우트 추가
 	beego.Router("/ws", &controllers.DashboardController{}, "get:WebSocketHandler")
 